@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
 	"github.com/kapilpokhrel/goNN/pkg/layer"
 	"github.com/kapilpokhrel/goNN/pkg/loss"
 	"github.com/kapilpokhrel/goNN/pkg/network"
@@ -23,18 +24,7 @@ func arange(start, stop, step float64) []float64 {
 }
 
 func XOR_net() {
-	// make training inputs & outputs
-	inputs := make([]*mat.Dense, 4)
-	inputs[0] = mat.NewDense(1, 2, []float64{0, 0})
-	inputs[1] = mat.NewDense(1, 2, []float64{0, 1})
-	inputs[2] = mat.NewDense(1, 2, []float64{1, 0})
-	inputs[3] = mat.NewDense(1, 2, []float64{1, 1})
-
-	outputs := make([]*mat.Dense, 4)
-	outputs[0] = mat.NewDense(1, 1, []float64{0})
-	outputs[1] = mat.NewDense(1, 1, []float64{1})
-	outputs[2] = mat.NewDense(1, 1, []float64{1})
-	outputs[3] = mat.NewDense(1, 1, []float64{0})
+	training := datasets.XOR()
 
 	var xor_network network.Network
 	if _, err := os.Stat("examples/xor/xor_trained.json"); errors.Is(err, os.ErrNotExist) {
@@ -51,12 +41,17 @@ func XOR_net() {
 			LossPrime: loss.MSE_Prime,
 		}
 
-		xor_network.Train(inputs, outputs, 1000, 0.01)
+		xor_network.Train(training.Inputs, training.Targets, 1000, 0.01)
 
-		xor_network.Save("examples/xor/xor_trained.json")
+		// readable weights keep the checked in model reviewable
+		if err := xor_network.Save("examples/xor/xor_trained.json", network.ReadableWeights()); err != nil {
+			panic(err)
+		}
 		fmt.Println("Training Finished!!")
 	} else {
-		xor_network.Load("examples/xor/xor_trained.json")
+		if err := xor_network.Load("examples/xor/xor_trained.json"); err != nil {
+			panic(err)
+		}
 	}
 
 	f, err := os.Create("./examples/xor/xor-boundry.csv")
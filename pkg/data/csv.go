@@ -0,0 +1,478 @@
+package data
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Column selects a CSV column by its header name or its index.
+type Column struct {
+	name  string
+	index int
+}
+
+func Col(name string) Column { return Column{name: name, index: -1} }
+
+// ColAt selects a column by its zero based index.
+func ColAt(index int) Column { return Column{index: index} }
+
+func (column Column) String() string {
+	if column.index < 0 {
+		return strconv.Quote(column.name)
+	}
+	return fmt.Sprintf("column %d", column.index)
+}
+
+type MissingPolicy int
+
+const (
+	DropMissing  MissingPolicy = iota // skip rows with a missing value
+	FillMean                          // use the mean of the column
+	FillConstant                      // use CSVConfig.FillValue
+)
+
+type CSVConfig struct {
+	NoHeader bool // the first row is data, columns can only be selected by index
+	Comma    rune // ',' if 0
+
+	Features []Column // every column that isn't a target if empty
+	Targets  []Column
+
+	// Categorical columns are one-hot encoded, with one value per category
+	// in sorted order. They can be features or targets.
+	Categorical []Column
+
+	Missing   MissingPolicy
+	FillValue float64
+	// Values treated as missing, besides the empty string. "NA", "N/A",
+	// "NaN" and "null" if nil.
+	MissingValues []string
+}
+
+var defaultMissingValues = []string{"NA", "N/A", "NaN", "null"}
+
+// csvColumn is one selected column and how it is encoded.
+type csvColumn struct {
+	index      int
+	name       string
+	categories []string // sorted, nil for numeric columns
+	sum        float64
+	count      int
+}
+
+func (column *csvColumn) width() int {
+	if column.categories != nil {
+		return len(column.categories)
+	}
+	return 1
+}
+
+// csvSchema turns records into samples.
+type csvSchema struct {
+	config   CSVConfig
+	features []*csvColumn
+	targets  []*csvColumn
+	missing  map[string]bool
+}
+
+func newCSVSchema(header []string, config CSVConfig) (*csvSchema, error) {
+	if len(config.Targets) == 0 {
+		return nil, errors.New("csv: no target columns")
+	}
+	schema := &csvSchema{config: config, missing: map[string]bool{"": true}}
+	missing_values := config.MissingValues
+	if missing_values == nil {
+		missing_values = defaultMissingValues
+	}
+	for _, value := range missing_values {
+		schema.missing[value] = true
+	}
+
+	resolve := func(column Column) (int, error) {
+		if column.index >= 0 {
+			if column.index >= len(header) {
+				return 0, fmt.Errorf("csv: %v doesn't exist, the file has %d columns", column, len(header))
+			}
+			return column.index, nil
+		}
+		if config.NoHeader {
+			return 0, fmt.Errorf("csv: can't select %v by name without a header", column)
+		}
+		index := slices.Index(header, column.name)
+		if index < 0 {
+			return 0, fmt.Errorf("csv: no column named %v", column)
+		}
+		return index, nil
+	}
+
+	categorical := make(map[int]bool)
+	for _, column := range config.Categorical {
+		index, err := resolve(column)
+		if err != nil {
+			return nil, err
+		}
+		categorical[index] = true
+	}
+	used := make(map[int]bool)
+	add := func(columns []*csvColumn, index int) ([]*csvColumn, error) {
+		if used[index] {
+			return nil, fmt.Errorf("csv: column %q is selected twice", header[index])
+		}
+		used[index] = true
+		column := &csvColumn{index: index, name: header[index]}
+		if categorical[index] {
+			column.categories = []string{}
+		}
+		return append(columns, column), nil
+	}
+
+	for _, target := range config.Targets {
+		index, err := resolve(target)
+		if err != nil {
+			return nil, err
+		}
+		if schema.targets, err = add(schema.targets, index); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.Features) == 0 {
+		for index := range header {
+			if !used[index] {
+				schema.features, _ = add(schema.features, index)
+			}
+		}
+	}
+	for _, feature := range config.Features {
+		index, err := resolve(feature)
+		if err != nil {
+			return nil, err
+		}
+		if schema.features, err = add(schema.features, index); err != nil {
+			return nil, err
+		}
+	}
+	if len(schema.features) == 0 {
+		return nil, errors.New("csv: no feature columns")
+	}
+	return schema, nil
+}
+
+func (schema *csvSchema) columns() []*csvColumn {
+	return append(slices.Clone(schema.features), schema.targets...)
+}
+
+// needsScan reports whether the data has to be read once before encoding,
+// to find the categories and means.
+func (schema *csvSchema) needsScan() bool {
+	return len(schema.config.Categorical) > 0 || schema.config.Missing == FillMean
+}
+
+// scan gathers the categories and means from one record.
+func (schema *csvSchema) scan(record []string, line int) error {
+	for _, column := range schema.columns() {
+		value := strings.TrimSpace(record[column.index])
+		if schema.missing[value] {
+			continue
+		}
+		if column.categories != nil {
+			if i, found := slices.BinarySearch(column.categories, value); !found {
+				column.categories = slices.Insert(column.categories, i, value)
+			}
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("csv: line %d: column %q: %w", line, column.name, err)
+		}
+		column.sum += v
+		column.count++
+	}
+	return nil
+}
+
+// encode returns false for rows dropped because of missing values.
+func (schema *csvSchema) encode(record []string, line int) (Sample, bool, error) {
+	encode := func(columns []*csvColumn) (*mat.Dense, bool, error) {
+		width := 0
+		for _, column := range columns {
+			width += column.width()
+		}
+		row := make([]float64, width)
+		offset := 0
+		for _, column := range columns {
+			value := strings.TrimSpace(record[column.index])
+			missing := schema.missing[value]
+			if missing && schema.config.Missing == DropMissing {
+				return nil, false, nil
+			}
+
+			switch {
+			case column.categories != nil:
+				// missing categories are encoded as all zeros
+				if !missing {
+					i, found := slices.BinarySearch(column.categories, value)
+					if !found {
+						return nil, false, fmt.Errorf("csv: line %d: column %q: unknown category %q", line, column.name, value)
+					}
+					row[offset+i] = 1
+				}
+			case missing && schema.config.Missing == FillMean:
+				if column.count > 0 {
+					row[offset] = column.sum / float64(column.count)
+				}
+			case missing:
+				row[offset] = schema.config.FillValue
+			default:
+				v, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, false, fmt.Errorf("csv: line %d: column %q: %w", line, column.name, err)
+				}
+				row[offset] = v
+			}
+			offset += column.width()
+		}
+		return mat.NewDense(1, width, row), true, nil
+	}
+
+	input, ok, err := encode(schema.features)
+	if !ok || err != nil {
+		return Sample{}, false, err
+	}
+	target, ok, err := encode(schema.targets)
+	if !ok || err != nil {
+		return Sample{}, false, err
+	}
+	return Sample{Input: input, Target: target}, true, nil
+}
+
+// names returns the name of every encoded value, categories as
+// "column=category".
+func names(columns []*csvColumn) []string {
+	result := make([]string, 0)
+	for _, column := range columns {
+		if column.categories == nil {
+			result = append(result, column.name)
+			continue
+		}
+		for _, category := range column.categories {
+			result = append(result, column.name+"="+category)
+		}
+	}
+	return result
+}
+
+func (schema *csvSchema) FeatureNames() []string { return names(schema.features) }
+func (schema *csvSchema) TargetNames() []string  { return names(schema.targets) }
+
+func newCSVReader(r io.Reader, config CSVConfig) *csv.Reader {
+	reader := csv.NewReader(r)
+	if config.Comma != 0 {
+		reader.Comma = config.Comma
+	}
+	reader.ReuseRecord = true
+	return reader
+}
+
+// readHeader reads the header, or makes up names for files without one.
+// It returns the first data record of files without a header.
+func readHeader(reader *csv.Reader, config CSVConfig) ([]string, []string, error) {
+	record, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("csv: file is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("csv: %w", err)
+	}
+	record = slices.Clone(record)
+	if !config.NoHeader {
+		return record, nil, nil
+	}
+	header := make([]string, len(record))
+	for i := range header {
+		header[i] = strconv.Itoa(i)
+	}
+	return header, record, nil
+}
+
+// CSVDataset holds a whole CSV file in memory.
+type CSVDataset struct {
+	SliceDataset
+	*csvSchema
+}
+
+// ReadCSV reads every row into memory. The Inputs and Targets of the
+// result can be passed to Network.Train directly.
+func ReadCSV(r io.Reader, config CSVConfig) (*CSVDataset, error) {
+	reader := newCSVReader(r, config)
+	reader.ReuseRecord = false
+	header, first, err := readHeader(reader, config)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := newCSVSchema(header, config)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("csv: %w", err)
+	}
+	if first != nil {
+		records = append([][]string{first}, records...)
+	}
+	// line numbers in errors count the header
+	offset := 2
+	if first != nil {
+		offset = 1
+	}
+
+	if schema.needsScan() {
+		for i, record := range records {
+			if err := schema.scan(record, i+offset); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	dataset := &CSVDataset{csvSchema: schema}
+	for i, record := range records {
+		sample, ok, err := schema.encode(record, i+offset)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			dataset.Inputs = append(dataset.Inputs, sample.Input)
+			dataset.Targets = append(dataset.Targets, sample.Target)
+		}
+	}
+	return dataset, nil
+}
+
+func LoadCSV(fpath string, config CSVConfig) (*CSVDataset, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCSV(f, config)
+}
+
+// CSVStream reads a CSV file row by row every epoch, for files too large
+// to hold in memory.
+type CSVStream struct {
+	*csvSchema
+	fpath string
+}
+
+// OpenCSV reads the header, and the whole file once if categories or means
+// are needed, and returns a Stream over the file.
+func OpenCSV(fpath string, config CSVConfig) (*CSVStream, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := newCSVReader(f, config)
+	header, first, err := readHeader(reader, config)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := newCSVSchema(header, config)
+	if err != nil {
+		return nil, err
+	}
+	stream := &CSVStream{csvSchema: schema, fpath: fpath}
+
+	if schema.needsScan() {
+		iterator := &csvIterator{schema: schema, file: f, reader: reader, pending: first, line: 1}
+		if first == nil {
+			iterator.line = 2
+		}
+		if err := iterator.each(schema.scan); err != nil {
+			return nil, err
+		}
+	}
+	return stream, nil
+}
+
+func (stream *CSVStream) Open() (Iterator, error) {
+	f, err := os.Open(stream.fpath)
+	if err != nil {
+		return nil, err
+	}
+	reader := newCSVReader(f, stream.config)
+	line := 1
+	if !stream.config.NoHeader {
+		if _, err := reader.Read(); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		line = 2
+	}
+	return &csvIterator{schema: stream.csvSchema, file: f, reader: reader, line: line}, nil
+}
+
+type csvIterator struct {
+	schema  *csvSchema
+	file    *os.File
+	reader  *csv.Reader
+	pending []string // first record of a file without header
+	line    int
+}
+
+func (iterator *csvIterator) read() ([]string, error) {
+	if record := iterator.pending; record != nil {
+		iterator.pending = nil
+		return record, nil
+	}
+	record, err := iterator.reader.Read()
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("csv: %w", err)
+	}
+	return record, err
+}
+
+func (iterator *csvIterator) each(f func(record []string, line int) error) error {
+	for {
+		record, err := iterator.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(record, iterator.line); err != nil {
+			return err
+		}
+		iterator.line++
+	}
+}
+
+func (iterator *csvIterator) Next() (Sample, error) {
+	for {
+		record, err := iterator.read()
+		if err != nil {
+			return Sample{}, err
+		}
+		line := iterator.line
+		iterator.line++
+		sample, ok, err := iterator.schema.encode(record, line)
+		if ok || err != nil {
+			return sample, err
+		}
+	}
+}
+
+func (iterator *csvIterator) Close() error {
+	return iterator.file.Close()
+}
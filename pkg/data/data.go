@@ -0,0 +1,112 @@
+// Package data feeds training samples to networks: datasets that hold or
+// stream the samples and a DataLoader that batches them.
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Sample is one training example. Input and Target are usually single
+// rows.
+type Sample struct {
+	Input  *mat.Dense
+	Target *mat.Dense
+}
+
+// Dataset is a collection of samples that can be read in any order.
+type Dataset interface {
+	Len() int
+	Get(i int) (Sample, error)
+}
+
+// Stream is a dataset that can only be read front to back, for data that
+// doesn't fit in memory or is generated on the fly.
+type Stream interface {
+	// Open starts a new pass over the data.
+	Open() (Iterator, error)
+}
+
+// Iterator reads the samples of one pass over a Stream.
+type Iterator interface {
+	// Next returns io.EOF after the last sample.
+	Next() (Sample, error)
+	Close() error
+}
+
+type SliceDataset struct {
+	Inputs  []*mat.Dense
+	Targets []*mat.Dense
+}
+
+// FromSlices checks that inputs and targets pair up and have the same
+// shapes throughout.
+func FromSlices(inputs []*mat.Dense, targets []*mat.Dense) (*SliceDataset, error) {
+	if len(inputs) != len(targets) {
+		return nil, fmt.Errorf("got %d inputs but %d targets", len(inputs), len(targets))
+	}
+	for i := range inputs {
+		if inputs[i] == nil || targets[i] == nil {
+			return nil, fmt.Errorf("sample %d is nil", i)
+		}
+		if !sameDims(inputs[i], inputs[0]) || !sameDims(targets[i], targets[0]) {
+			return nil, fmt.Errorf("sample %d has a different shape than sample 0", i)
+		}
+	}
+	return &SliceDataset{Inputs: inputs, Targets: targets}, nil
+}
+
+func (dataset *SliceDataset) Len() int {
+	return len(dataset.Inputs)
+}
+
+func (dataset *SliceDataset) Get(i int) (Sample, error) {
+	if i < 0 || i >= dataset.Len() {
+		return Sample{}, fmt.Errorf("sample %d out of range [0, %d)", i, dataset.Len())
+	}
+	return Sample{Input: dataset.Inputs[i], Target: dataset.Targets[i]}, nil
+}
+
+// StreamFunc turns a function that starts a pass over the data, such as
+// opening a file, into a Stream.
+type StreamFunc func() (Iterator, error)
+
+func (open StreamFunc) Open() (Iterator, error) {
+	return open()
+}
+
+// IteratorFunc turns a function returning the next sample into an
+// Iterator with nothing to close.
+type IteratorFunc func() (Sample, error)
+
+func (next IteratorFunc) Next() (Sample, error) { return next() }
+func (next IteratorFunc) Close() error          { return nil }
+
+// ChanStream streams samples sent on a channel by a producer goroutine.
+// The channel is read until it is closed, so it can only be passed over
+// once.
+func ChanStream(samples <-chan Sample) Stream {
+	var opened atomic.Bool
+	return StreamFunc(func() (Iterator, error) {
+		if !opened.CompareAndSwap(false, true) {
+			return nil, errors.New("channel stream can only be read once")
+		}
+		return IteratorFunc(func() (Sample, error) {
+			sample, ok := <-samples
+			if !ok {
+				return Sample{}, io.EOF
+			}
+			return sample, nil
+		}), nil
+	})
+}
+
+func sameDims(a, b mat.Matrix) bool {
+	ar, ac := a.Dims()
+	br, bc := b.Dims()
+	return ar == br && ac == bc
+}
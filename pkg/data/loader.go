@@ -0,0 +1,295 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sync"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Batch holds several samples stacked row by row.
+type Batch struct {
+	Inputs  *mat.Dense
+	Targets *mat.Dense
+	Size    int // number of samples
+}
+
+// DataLoader splits a Dataset or a Stream into batches for training.
+// Exactly one of Dataset and Stream has to be set.
+type DataLoader struct {
+	Dataset Dataset
+	Stream  Stream
+
+	BatchSize int  // samples per batch, 1 if 0
+	Shuffle   bool // new order every epoch, only for a Dataset
+	DropLast  bool // skip the last batch if it isn't full
+
+	// Prefetch is the number of batches prepared ahead by background
+	// goroutines while the network trains. With 0, batches are loaded
+	// when they are needed.
+	Prefetch int
+	// Workers is the number of goroutines reading a Dataset when
+	// prefetching, 1 if 0. Batches are still returned in order.
+	Workers int
+}
+
+// Batches is one pass over the data, see DataLoader.Epoch.
+type Batches struct {
+	next  func() (Batch, error)
+	close func() error
+}
+
+// Next returns io.EOF after the last batch.
+func (batches *Batches) Next() (Batch, error) {
+	return batches.next()
+}
+
+// Close stops any prefetching goroutines. It has to be called even if the
+// pass wasn't finished.
+func (batches *Batches) Close() error {
+	return batches.close()
+}
+
+// Epoch starts a pass over the data. rng shuffles the samples if Shuffle
+// is set, so runs seeded the same way see the same order.
+func (loader *DataLoader) Epoch(rng rand.Source) (*Batches, error) {
+	switch {
+	case (loader.Dataset == nil) == (loader.Stream == nil):
+		return nil, errors.New("data loader needs either a Dataset or a Stream")
+	case loader.BatchSize < 0 || loader.Prefetch < 0 || loader.Workers < 0:
+		return nil, errors.New("data loader sizes can't be negative")
+	case loader.Shuffle && loader.Stream != nil:
+		return nil, errors.New("streams can't be shuffled")
+	case loader.Shuffle && rng == nil:
+		return nil, errors.New("shuffling needs a random source")
+	}
+	if loader.Stream != nil {
+		return loader.streamEpoch()
+	}
+	return loader.datasetEpoch(rng), nil
+}
+
+func (loader *DataLoader) batchSize() int {
+	return max(loader.BatchSize, 1)
+}
+
+func (loader *DataLoader) datasetEpoch(rng rand.Source) *Batches {
+	n := loader.Dataset.Len()
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	if loader.Shuffle {
+		rand.New(rng).Shuffle(n, func(i, j int) { order[i], order[j] = order[j], order[i] })
+	}
+
+	size := loader.batchSize()
+	count := n / size
+	if n%size != 0 && !loader.DropLast {
+		count++
+	}
+
+	load := func(k int) (Batch, error) {
+		indices := order[k*size : min((k+1)*size, n)]
+		samples := make([]Sample, len(indices))
+		for i, index := range indices {
+			var err error
+			if samples[i], err = loader.Dataset.Get(index); err != nil {
+				return Batch{}, err
+			}
+		}
+		return stack(samples)
+	}
+
+	if loader.Prefetch == 0 {
+		k := 0
+		return &Batches{
+			next: func() (Batch, error) {
+				if k == count {
+					return Batch{}, io.EOF
+				}
+				k++
+				return load(k - 1)
+			},
+			close: func() error { return nil },
+		}
+	}
+
+	/*
+		Worker w loads batches w, w + workers, w + 2 * workers, ... into
+		its own channel, so reading the channels in turn gives the batches
+		back in order.
+	*/
+	type result struct {
+		batch Batch
+		err   error
+	}
+	workers := max(loader.Workers, 1)
+	queues := make([]chan result, workers)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := range queues {
+		queues[w] = make(chan result, max(loader.Prefetch/workers, 1))
+		wg.Add(1)
+		go func(queue chan<- result, first int) {
+			defer wg.Done()
+			defer close(queue)
+			for k := first; k < count; k += workers {
+				batch, err := load(k)
+				select {
+				case queue <- result{batch, err}:
+				case <-done:
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(queues[w], w)
+	}
+
+	k := 0
+	var once sync.Once
+	return &Batches{
+		next: func() (Batch, error) {
+			if k == count {
+				return Batch{}, io.EOF
+			}
+			r, ok := <-queues[k%workers]
+			if !ok {
+				return Batch{}, errors.New("data loader was closed")
+			}
+			k++
+			return r.batch, r.err
+		},
+		close: func() error {
+			once.Do(func() {
+				close(done)
+				wg.Wait()
+			})
+			return nil
+		},
+	}
+}
+
+func (loader *DataLoader) streamEpoch() (*Batches, error) {
+	iterator, err := loader.Stream.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	size := loader.batchSize()
+	eof := false
+	load := func() (Batch, error) {
+		if eof {
+			return Batch{}, io.EOF
+		}
+		samples := make([]Sample, 0, size)
+		for len(samples) < size {
+			sample, err := iterator.Next()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return Batch{}, err
+			}
+			samples = append(samples, sample)
+		}
+		if len(samples) == 0 || (len(samples) < size && loader.DropLast) {
+			return Batch{}, io.EOF
+		}
+		return stack(samples)
+	}
+
+	if loader.Prefetch == 0 {
+		return &Batches{next: load, close: iterator.Close}, nil
+	}
+
+	// A stream has to be read in order, so a single goroutine reads ahead.
+	type result struct {
+		batch Batch
+		err   error
+	}
+	queue := make(chan result, loader.Prefetch)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(queue)
+		for {
+			batch, err := load()
+			select {
+			case queue <- result{batch, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	var close_err error
+	return &Batches{
+		next: func() (Batch, error) {
+			r, ok := <-queue
+			if !ok {
+				return Batch{}, io.EOF
+			}
+			return r.batch, r.err
+		},
+		close: func() error {
+			once.Do(func() {
+				close(done)
+				wg.Wait()
+				close_err = iterator.Close()
+			})
+			return close_err
+		},
+	}, nil
+}
+
+// stack puts the samples below each other. A single sample is used as is.
+// Every sample needs as many target rows as input rows.
+func stack(samples []Sample) (Batch, error) {
+	for i, sample := range samples {
+		input_rows, _ := sample.Input.Dims()
+		if target_rows, _ := sample.Target.Dims(); target_rows != input_rows {
+			return Batch{}, fmt.Errorf("sample %d of the batch has %d input rows but %d target rows", i, input_rows, target_rows)
+		}
+	}
+	if len(samples) == 1 {
+		rows, _ := samples[0].Input.Dims()
+		return Batch{Inputs: samples[0].Input, Targets: samples[0].Target, Size: rows}, nil
+	}
+
+	rows, in_cols := samples[0].Input.Dims()
+	_, target_cols := samples[0].Target.Dims()
+	for i, sample := range samples[1:] {
+		r, c := sample.Input.Dims()
+		if _, target_c := sample.Target.Dims(); c != in_cols || target_c != target_cols {
+			return Batch{}, fmt.Errorf("sample %d of the batch has a different shape", i+1)
+		}
+		rows += r
+	}
+
+	inputs := mat.NewDense(rows, in_cols, nil)
+	targets := mat.NewDense(rows, target_cols, nil)
+	row := 0
+	for _, sample := range samples {
+		r, _ := sample.Input.Dims()
+		// row by row, as Slice would allocate a matrix per sample
+		for i := 0; i < r; i++ {
+			copy(inputs.RawRowView(row+i), sample.Input.RawRowView(i))
+			copy(targets.RawRowView(row+i), sample.Target.RawRowView(i))
+		}
+		row += r
+	}
+	return Batch{Inputs: inputs, Targets: targets, Size: rows}, nil
+}
@@ -0,0 +1,116 @@
+// Package datasets generates small synthetic datasets and benchmark tasks,
+// and reads MNIST style IDX files, so layers and training setups can be
+// compared on the same data.
+//
+// Every generator takes a seed and returns the same samples for the same
+// seed. Classification targets are 0 or 1 for two classes and one-hot for
+// more. Sequence tasks lay their time steps out one after the other in a
+// single row.
+//
+// Generators panic on invalid arguments, like a negative sample count.
+package datasets
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"gonum.org/v1/gonum/mat"
+)
+
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, 0))
+}
+
+func check(ok bool, format string, args ...any) {
+	if !ok {
+		panic(fmt.Sprintf("datasets: "+format, args...))
+	}
+}
+
+// build makes a dataset of n samples from a function filling one input and
+// target row.
+func build(n, input_size, target_size int, sample func(i int, input, target []float64)) *data.SliceDataset {
+	check(n >= 0, "negative sample count %d", n)
+	dataset := &data.SliceDataset{
+		Inputs:  make([]*mat.Dense, n),
+		Targets: make([]*mat.Dense, n),
+	}
+	for i := 0; i < n; i++ {
+		input := make([]float64, input_size)
+		target := make([]float64, target_size)
+		sample(i, input, target)
+		dataset.Inputs[i] = mat.NewDense(1, input_size, input)
+		dataset.Targets[i] = mat.NewDense(1, target_size, target)
+	}
+	return dataset
+}
+
+// XOR returns the four points of the XOR truth table.
+func XOR() *data.SliceDataset {
+	return build(4, 2, 1, func(i int, input, target []float64) {
+		a, b := i>>1, i&1
+		input[0], input[1] = float64(a), float64(b)
+		target[0] = float64(a ^ b)
+	})
+}
+
+// Moons returns two interleaving half circles. Samples alternate between
+// the upper moon, class 0, and the lower one, class 1. noise is the
+// standard deviation of gaussian noise added to every point.
+func Moons(n int, noise float64, seed uint64) *data.SliceDataset {
+	rng := newRand(seed)
+	return build(n, 2, 1, func(i int, input, target []float64) {
+		angle := math.Pi * rng.Float64()
+		if i%2 == 0 {
+			input[0], input[1] = math.Cos(angle), math.Sin(angle)
+		} else {
+			input[0], input[1] = 1-math.Cos(angle), 0.5-math.Sin(angle)
+			target[0] = 1
+		}
+		input[0] += noise * rng.NormFloat64()
+		input[1] += noise * rng.NormFloat64()
+	})
+}
+
+// Circles returns a circle of radius 1, class 0, around a smaller one of
+// radius factor, class 1.
+func Circles(n int, noise, factor float64, seed uint64) *data.SliceDataset {
+	check(factor > 0 && factor < 1, "circle factor %v isn't between 0 and 1", factor)
+	rng := newRand(seed)
+	return build(n, 2, 1, func(i int, input, target []float64) {
+		angle := 2 * math.Pi * rng.Float64()
+		radius := 1.0
+		if i%2 == 1 {
+			radius = factor
+			target[0] = 1
+		}
+		input[0] = radius*math.Cos(angle) + noise*rng.NormFloat64()
+		input[1] = radius*math.Sin(angle) + noise*rng.NormFloat64()
+	})
+}
+
+// Spirals returns classes spiral arms winding out from the origin, with a
+// one-hot target. Samples go through the classes in turn.
+func Spirals(n, classes int, noise float64, seed uint64) *data.SliceDataset {
+	check(classes >= 2, "spirals need at least 2 classes, got %d", classes)
+	rng := newRand(seed)
+	return build(n, 2, classes, func(i int, input, target []float64) {
+		class := i % classes
+		radius := rng.Float64()
+		angle := float64(class)*2*math.Pi/float64(classes) + 4*radius + noise*rng.NormFloat64()
+		input[0], input[1] = radius*math.Sin(angle), radius*math.Cos(angle)
+		target[class] = 1
+	})
+}
+
+// Sine returns x uniform in [-pi, pi] with target sin(x) plus gaussian
+// noise.
+func Sine(n int, noise float64, seed uint64) *data.SliceDataset {
+	rng := newRand(seed)
+	return build(n, 1, 1, func(i int, input, target []float64) {
+		input[0] = math.Pi * (2*rng.Float64() - 1)
+		target[0] = math.Sin(input[0]) + noise*rng.NormFloat64()
+	})
+}
@@ -0,0 +1,187 @@
+package datasets
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"gonum.org/v1/gonum/mat"
+)
+
+// IDX is an array read from an IDX file, the format MNIST is distributed
+// in. Data holds the values in row-major order.
+type IDX struct {
+	Dims []int
+	Data []float64
+}
+
+// IDX element types, the third byte of the magic number.
+const (
+	idxUint8   = 0x08
+	idxInt8    = 0x09
+	idxInt16   = 0x0B
+	idxInt32   = 0x0C
+	idxFloat32 = 0x0D
+	idxFloat64 = 0x0E
+)
+
+// ReadIDX reads an IDX file, gzip compressed or not.
+func ReadIDX(r io.Reader) (*IDX, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		unzipped, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("idx: %w", err)
+		}
+		defer unzipped.Close()
+		buffered = bufio.NewReader(unzipped)
+	}
+
+	var magic [4]byte
+	if _, err := io.ReadFull(buffered, magic[:]); err != nil {
+		return nil, fmt.Errorf("idx: reading magic number: %w", err)
+	}
+	if magic[0] != 0 || magic[1] != 0 {
+		return nil, errors.New("idx: not an IDX file")
+	}
+	var size int
+	switch magic[2] {
+	case idxUint8, idxInt8:
+		size = 1
+	case idxInt16:
+		size = 2
+	case idxInt32, idxFloat32:
+		size = 4
+	case idxFloat64:
+		size = 8
+	default:
+		return nil, fmt.Errorf("idx: unknown element type 0x%02x", magic[2])
+	}
+
+	idx := &IDX{Dims: make([]int, magic[3])}
+	count := 1
+	for i := range idx.Dims {
+		var dim uint32
+		if err := binary.Read(buffered, binary.BigEndian, &dim); err != nil {
+			return nil, fmt.Errorf("idx: reading dimensions: %w", err)
+		}
+		idx.Dims[i] = int(dim)
+		count *= int(dim)
+		if count > math.MaxInt32 {
+			return nil, errors.New("idx: array is too large")
+		}
+	}
+
+	raw, err := readData(buffered, count*size)
+	if err != nil {
+		return nil, fmt.Errorf("idx: file is truncated: %w", err)
+	}
+	idx.Data = make([]float64, count)
+	for i := range idx.Data {
+		b := raw[i*size:]
+		switch magic[2] {
+		case idxUint8:
+			idx.Data[i] = float64(b[0])
+		case idxInt8:
+			idx.Data[i] = float64(int8(b[0]))
+		case idxInt16:
+			idx.Data[i] = float64(int16(binary.BigEndian.Uint16(b)))
+		case idxInt32:
+			idx.Data[i] = float64(int32(binary.BigEndian.Uint32(b)))
+		case idxFloat32:
+			idx.Data[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		case idxFloat64:
+			idx.Data[i] = math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	}
+	return idx, nil
+}
+
+// readChunk is the most data read at once, see readData.
+const readChunk = 1 << 20
+
+// readData reads n bytes from r. Its buffer grows as the data arrives, so
+// dimensions claiming more data than the file holds fail without
+// allocating it. Compressed files can't be measured up front.
+func readData(r io.Reader, n int) ([]byte, error) {
+	data := make([]byte, 0, min(n, readChunk))
+	for len(data) < n {
+		step := min(n-len(data), readChunk)
+		data = slices.Grow(data, step)
+		read, err := io.ReadFull(r, data[len(data):len(data)+step])
+		data = data[:len(data)+read]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func LoadIDX(fpath string) (*IDX, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIDX(f)
+}
+
+// FashionMNISTClasses names the labels of Fashion-MNIST.
+var FashionMNISTClasses = []string{
+	"T-shirt/top", "Trouser", "Pullover", "Dress", "Coat",
+	"Sandal", "Shirt", "Sneaker", "Bag", "Ankle boot",
+}
+
+// MNIST pairs an images and a labels IDX file, as distributed for MNIST and
+// Fashion-MNIST. Every image becomes one row of pixels scaled to [0, 1],
+// every label a one-hot row of 10 classes.
+func MNIST(images, labels *IDX) (*data.SliceDataset, error) {
+	if len(images.Dims) != 3 {
+		return nil, fmt.Errorf("mnist: images have %d dimensions, expected 3", len(images.Dims))
+	}
+	if len(labels.Dims) != 1 {
+		return nil, fmt.Errorf("mnist: labels have %d dimensions, expected 1", len(labels.Dims))
+	}
+	n, pixels := images.Dims[0], images.Dims[1]*images.Dims[2]
+	if labels.Dims[0] != n {
+		return nil, fmt.Errorf("mnist: %d images but %d labels", n, labels.Dims[0])
+	}
+
+	dataset := &data.SliceDataset{
+		Inputs:  make([]*mat.Dense, n),
+		Targets: make([]*mat.Dense, n),
+	}
+	for i := 0; i < n; i++ {
+		label := labels.Data[i]
+		if label != math.Trunc(label) || label < 0 || label > 9 {
+			return nil, fmt.Errorf("mnist: label %d is %v, expected 0 to 9", i, label)
+		}
+		input := mat.NewDense(1, pixels, nil)
+		input.Scale(1.0/255, mat.NewDense(1, pixels, images.Data[i*pixels:(i+1)*pixels]))
+		target := mat.NewDense(1, 10, nil)
+		target.Set(0, int(label), 1)
+		dataset.Inputs[i], dataset.Targets[i] = input, target
+	}
+	return dataset, nil
+}
+
+// LoadMNIST reads a pair of files such as train-images-idx3-ubyte.gz and
+// train-labels-idx1-ubyte.gz.
+func LoadMNIST(images_path, labels_path string) (*data.SliceDataset, error) {
+	images, err := LoadIDX(images_path)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := LoadIDX(labels_path)
+	if err != nil {
+		return nil, err
+	}
+	return MNIST(images, labels)
+}
@@ -0,0 +1,101 @@
+package datasets
+
+import (
+	"github.com/kapilpokhrel/goNN/pkg/data"
+)
+
+// Values of the distracted sequence recall task.
+const (
+	DSRTarget1     = 0.1
+	DSRTarget2     = 0.2
+	DSRDistractor1 = 0.3
+	DSRDistractor2 = 0.4
+	DSRPrompt1     = 0.5
+	DSRPrompt2     = 0.6
+)
+
+// DSR returns the distracted sequence recall task: 2 targets and 6
+// distractors in random order, followed by the 2 prompts. The target is 0
+// everywhere except at the prompts, where it is the first and second target
+// in the order they appeared. Inputs and targets have 10 steps.
+func DSR(n int, seed uint64) *data.SliceDataset {
+	rng := newRand(seed)
+	targets := []float64{DSRTarget1, DSRTarget2}
+	distractors := []float64{DSRDistractor1, DSRDistractor2}
+	return build(n, 10, 10, func(i int, input, target []float64) {
+		for j := 0; j < 8; j++ {
+			if j < 2 {
+				input[j] = targets[rng.IntN(2)]
+			} else {
+				input[j] = distractors[rng.IntN(2)]
+			}
+		}
+		rng.Shuffle(8, func(a, b int) { input[a], input[b] = input[b], input[a] })
+		input[8], input[9] = DSRPrompt1, DSRPrompt2
+
+		found := 8
+		for _, value := range input[:8] {
+			if value == DSRTarget1 || value == DSRTarget2 {
+				target[found] = value
+				found++
+			}
+		}
+	})
+}
+
+// Adding returns the adding problem: length steps of a (value, marker)
+// pair, values uniform in [0, 1) and exactly two steps marked with 1. The
+// target is the sum of the two marked values. Inputs have 2*length values,
+// value and marker of step 0 first.
+func Adding(n, length int, seed uint64) *data.SliceDataset {
+	check(length >= 2, "adding problem needs at least 2 steps, got %d", length)
+	rng := newRand(seed)
+	return build(n, 2*length, 1, func(i int, input, target []float64) {
+		for step := 0; step < length; step++ {
+			input[2*step] = rng.Float64()
+		}
+		first := rng.IntN(length)
+		second := rng.IntN(length - 1)
+		if second >= first {
+			second++
+		}
+		for _, step := range []int{first, second} {
+			input[2*step+1] = 1
+			target[0] += input[2*step]
+		}
+	})
+}
+
+// CopyMemory returns the copy memory task with symbols symbols, one-hot
+// encoded over symbols+2 values per step: 0 is blank, 1 to symbols are the
+// symbols and symbols+1 is the delimiter.
+//
+// The input is length random symbols, delay blanks, the delimiter and
+// length-1 more blanks. The target is blank for the first length+delay
+// steps and then repeats the symbols. Both have length*2+delay steps of
+// symbols+2 values.
+func CopyMemory(n, length, delay, symbols int, seed uint64) *data.SliceDataset {
+	check(length >= 1 && delay >= 0, "invalid copy memory length %d or delay %d", length, delay)
+	check(symbols >= 1, "copy memory needs at least 1 symbol, got %d", symbols)
+	rng := newRand(seed)
+	width := symbols + 2
+	steps := 2*length + delay
+	return build(n, steps*width, steps*width, func(i int, input, target []float64) {
+		set := func(row []float64, step, value int) { row[step*width+value] = 1 }
+		for step := 0; step < steps; step++ {
+			switch {
+			case step < length:
+				symbol := 1 + rng.IntN(symbols)
+				set(input, step, symbol)
+				set(target, step+length+delay, symbol)
+			case step == length+delay:
+				set(input, step, symbols+1)
+			default:
+				set(input, step, 0)
+			}
+			if step < length+delay {
+				set(target, step, 0)
+			}
+		}
+	})
+}
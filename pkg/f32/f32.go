@@ -0,0 +1,82 @@
+// Package f32 is a small float32 matrix type for inference. gonum only
+// has float64 dense matrices, float32 halves the memory and bandwidth
+// needed for weights and activations.
+package f32
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Matrix is a row-major float32 matrix.
+type Matrix struct {
+	Rows, Cols int
+	Data       []float32
+}
+
+// New returns a rows x cols matrix backed by data, or by a new zeroed slice
+// if data is nil.
+func New(rows, cols int, data []float32) *Matrix {
+	if data == nil {
+		data = make([]float32, rows*cols)
+	}
+	if len(data) != rows*cols {
+		panic(fmt.Sprintf("f32: %d values for a %dx%d matrix", len(data), rows, cols))
+	}
+	return &Matrix{Rows: rows, Cols: cols, Data: data}
+}
+
+// FromDense converts m to float32, rounding every value to the nearest
+// float32.
+func FromDense(m mat.Matrix) *Matrix {
+	rows, cols := m.Dims()
+	result := New(rows, cols, nil)
+	if dense, ok := m.(*mat.Dense); ok {
+		raw := dense.RawMatrix()
+		for i := 0; i < rows; i++ {
+			row := raw.Data[i*raw.Stride : i*raw.Stride+cols]
+			for j, v := range row {
+				result.Data[i*cols+j] = float32(v)
+			}
+		}
+		return result
+	}
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			result.Data[i*cols+j] = float32(m.At(i, j))
+		}
+	}
+	return result
+}
+
+// Dense converts m back to float64.
+func (m *Matrix) Dense() *mat.Dense {
+	data := make([]float64, len(m.Data))
+	for i, v := range m.Data {
+		data[i] = float64(v)
+	}
+	return mat.NewDense(m.Rows, m.Cols, data)
+}
+
+func (m *Matrix) At(i, j int) float32 {
+	return m.Data[i*m.Cols+j]
+}
+
+// Row returns row i, sharing m's storage.
+func (m *Matrix) Row(i int) []float32 {
+	return m.Data[i*m.Cols : (i+1)*m.Cols]
+}
+
+// AddRow adds row to every row of m.
+func (m *Matrix) AddRow(row []float32) {
+	if len(row) != m.Cols {
+		panic(fmt.Sprintf("f32: adding a row of %d values to a %dx%d matrix", len(row), m.Rows, m.Cols))
+	}
+	for i := 0; i < m.Rows; i++ {
+		current := m.Row(i)
+		for j, v := range row {
+			current[j] += v
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package f32
+
+import "fmt"
+
+// block is the tile size of Mul. Three 64x64 float32 tiles take 48KiB and
+// fit in the L2 cache of current CPUs.
+const block = 64
+
+// Mul returns a*b.
+func Mul(a, b *Matrix) *Matrix {
+	c := New(a.Rows, b.Cols, nil)
+	MulAdd(c, a, b)
+	return c
+}
+
+// MulAdd adds a*b to c. c must not share storage with a or b.
+//
+// The product is computed tile by tile, so the rows of b a tile needs stay
+// in cache while every row of a's tile uses them. Within a tile, four rows
+// of c are updated together: each value loaded from a row of b is used four
+// times, and every loop reads and writes memory in order.
+func MulAdd(c, a, b *Matrix) {
+	if a.Cols != b.Rows || c.Rows != a.Rows || c.Cols != b.Cols {
+		panic(fmt.Sprintf("f32: can't add %dx%d * %dx%d to %dx%d", a.Rows, a.Cols, b.Rows, b.Cols, c.Rows, c.Cols))
+	}
+	m, n, k := a.Rows, b.Cols, a.Cols
+
+	for i0 := 0; i0 < m; i0 += block {
+		i1 := min(i0+block, m)
+		for p0 := 0; p0 < k; p0 += block {
+			p1 := min(p0+block, k)
+			for j0 := 0; j0 < n; j0 += block {
+				j1 := min(j0+block, n)
+				i := i0
+				for ; i+4 <= i1; i += 4 {
+					kernel4(c, a, b, i, p0, p1, j0, j1)
+				}
+				for ; i < i1; i++ {
+					kernel1(c, a, b, i, p0, p1, j0, j1)
+				}
+			}
+		}
+	}
+}
+
+// kernel4 adds a[i:i+4, p0:p1] * b[p0:p1, j0:j1] to c.
+func kernel4(c, a, b *Matrix, i, p0, p1, j0, j1 int) {
+	n, k := c.Cols, a.Cols
+	c0 := c.Data[i*n+j0 : i*n+j1]
+	c1 := c.Data[(i+1)*n+j0 : (i+1)*n+j1]
+	c2 := c.Data[(i+2)*n+j0 : (i+2)*n+j1]
+	c3 := c.Data[(i+3)*n+j0 : (i+3)*n+j1]
+	c1, c2, c3 = c1[:len(c0)], c2[:len(c0)], c3[:len(c0)]
+	for p := p0; p < p1; p++ {
+		a0, a1, a2, a3 := a.Data[i*k+p], a.Data[(i+1)*k+p], a.Data[(i+2)*k+p], a.Data[(i+3)*k+p]
+		b_row := b.Data[p*n+j0 : p*n+j1]
+		b_row = b_row[:len(c0)]
+		for j, v := range b_row {
+			c0[j] += a0 * v
+			c1[j] += a1 * v
+			c2[j] += a2 * v
+			c3[j] += a3 * v
+		}
+	}
+}
+
+// kernel1 adds a[i, p0:p1] * b[p0:p1, j0:j1] to c.
+func kernel1(c, a, b *Matrix, i, p0, p1, j0, j1 int) {
+	n, k := c.Cols, a.Cols
+	c_row := c.Data[i*n+j0 : i*n+j1]
+	for p := p0; p < p1; p++ {
+		scale := a.Data[i*k+p]
+		if scale == 0 {
+			continue
+		}
+		b_row := b.Data[p*n+j0 : p*n+j1]
+		b_row = b_row[:len(c_row)]
+		for j, v := range b_row {
+			c_row[j] += scale * v
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package layer
 
 import (
+	"encoding/json"
 	"errors"
 	"math/rand"
 
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -11,6 +16,14 @@ type DenseLayer struct {
 	Weights *mat.Dense
 	Biases  *mat.Dense
 	Input   *mat.Dense
+
+	// Mask, if set, has a 0 for every pruned weight and a 1 for the others.
+	// Backward keeps pruned weights at 0, see package prune.
+	Mask *mat.Dense
+
+	// reused by every Forward and Backward, sized for the last batch
+	output     mat.Dense
+	input_grad mat.Dense
 }
 
 func Dense(insize, outsize int) *DenseLayer {
@@ -31,7 +44,40 @@ func Dense(insize, outsize int) *DenseLayer {
 	return &layer
 }
 
-func (layer *DenseLayer) Forward(input *mat.Dense) (*mat.Dense, error) {
+// Forward returns a tensor owned by the layer, which the next Forward
+// overwrites, see ForwardRows.
+func (layer *DenseLayer) Forward(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return forwardRows(layer, input)
+}
+
+func (layer *DenseLayer) Backward(out_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	return backwardRows(layer, out_grad, rate)
+}
+
+func (layer *DenseLayer) Infer(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return inferRows(layer, input)
+}
+
+// ForwardRows returns a matrix owned by the layer, which the next Forward
+// overwrites. Copy it to keep it longer.
+func (layer *DenseLayer) ForwardRows(input *mat.Dense) (*mat.Dense, error) {
+	if err := layer.InferInto(&layer.output, input); err != nil {
+		return nil, err
+	}
+	// kept for the weight gradients in Backward
+	layer.Input = input
+	return &layer.output, nil
+}
+
+func (layer *DenseLayer) InferRows(input *mat.Dense) (*mat.Dense, error) {
+	var output mat.Dense
+	if err := layer.InferInto(&output, input); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+func (layer *DenseLayer) InferInto(output, input *mat.Dense) error {
 	/*
 		This is the method to handle forward progation through the layer.
 		This applies the corresponding weights and biases to its inputs and
@@ -50,23 +96,26 @@ func (layer *DenseLayer) Forward(input *mat.Dense) (*mat.Dense, error) {
 		[y1 y2 y3] = [x1 x2]*[w1 w2 w3] + [b1 b2 b3]
 							 [w4 w5 w6]
 
+		A batch has one sample per row, the same biases are added to
+		every row.
 	*/
-	in_w, in_c := input.Dims()
-	w_r, _ := layer.Weights.Dims()
+	in_r, in_c := input.Dims()
+	w_r, w_c := layer.Weights.Dims()
 
-	if in_c != w_r || in_w != 1 {
-		return nil, errors.New("input size is not compataible with this layer")
+	if in_c != w_r {
+		return errors.New("input size is not compataible with this layer")
 	}
 
-	layer.Input = input
-
-	var output mat.Dense
-	output.Mul(layer.Input, layer.Weights)
-	output.Add(&output, layer.Biases)
-	return &output, nil
+	resize(output, in_r, w_c)
+	output.Mul(input, layer.Weights)
+	biases := layer.Biases.RawRowView(0)
+	for i := 0; i < in_r; i++ {
+		floats.Add(output.RawRowView(i), biases)
+	}
+	return nil
 }
 
-func (layer *DenseLayer) Backward(output_grad *mat.Dense, rate float64) *mat.Dense {
+func (layer *DenseLayer) BackwardRows(output_grad *mat.Dense, rate float64) *mat.Dense {
 	/*
 		This is the method to handle backward propagation through the layer.
 		This receives output gradient (gradient of Loss with respect to the output of layer)
@@ -101,6 +150,10 @@ func (layer *DenseLayer) Backward(output_grad *mat.Dense, rate float64) *mat.Den
 
 		Same deduction can be done to find dL/db,
 
+		For a batch, X has one row per sample and X^T * dL/dy sums the
+		weight gradients of all samples. The bias gradients are summed
+		over the rows of dL/dy the same way.
+
 		For dL/dx,
 		we can expand it using chain rule as,
 		dL/dx1 = dL/dy1 * dy1/dx1 + dL/dy2 * dy2/dx1 + dL/dy3 * dy3/dx1
@@ -120,21 +173,125 @@ func (layer *DenseLayer) Backward(output_grad *mat.Dense, rate float64) *mat.Den
 
 	*/
 
-	var weights_grad mat.Dense
-	weights_grad.Mul(layer.Input.T(), output_grad)
+	/*
+		The products go straight to BLAS, as Mul with a transposed operand
+		would allocate the mat.Transpose. The returned gradient is owned by
+		the layer, like the output of Forward.
+
+		The input gradient needs the weights before the update, so it comes
+		first. The weights are then updated in place by the same product
+		that gives their gradient: with alpha = -rate and beta = 1, Gemm
+		computes weights - rate * X^T * dL/dy in one pass, without storing
+		the gradient.
+	*/
+	in_r, in_c := layer.Input.Dims()
 
-	var input_grad mat.Dense
-	input_grad.Mul(output_grad, layer.Weights.T())
+	input_grad := &layer.input_grad
+	resize(input_grad, in_r, in_c)
+	blas64.Gemm(blas.NoTrans, blas.Trans, 1, output_grad.RawMatrix(), layer.Weights.RawMatrix(), 0, input_grad.RawMatrix())
 
-	// weights -= rate * weights_grad
-	layer.Weights.Apply(func(i, j int, v float64) float64 {
-		return v - rate*weights_grad.At(i, j)
-	}, layer.Weights)
+	blas64.Gemm(blas.Trans, blas.NoTrans, -rate, layer.Input.RawMatrix(), output_grad.RawMatrix(), 1, layer.Weights.RawMatrix())
+	if layer.Mask != nil {
+		for i := 0; i < in_c; i++ {
+			floats.Mul(layer.Weights.RawRowView(i), layer.Mask.RawRowView(i))
+		}
+	}
+
+	// biases -= rate * output_grad, summed over the batch
+	subtractRows(layer.Biases, rate, output_grad)
+
+	return input_grad
+}
 
-	// biases -= rate * output_grad
-	layer.Biases.Apply(func(i, j int, v float64) float64 {
-		return v - rate*output_grad.At(i, j)
-	}, layer.Biases)
+// subtractRows does biases -= rate * grad for every row of grad, which sums
+// the bias gradients of a batch into the update.
+func subtractRows(biases *mat.Dense, rate float64, grad *mat.Dense) {
+	rows, _ := grad.Dims()
+	row := biases.RawRowView(0)
+	for i := 0; i < rows; i++ {
+		floats.AddScaled(row, -rate, grad.RawRowView(i))
+	}
+}
+
+// resize makes m an r×c matrix, keeping its storage when it is large
+// enough. The elements are only zeroed when the size changes.
+func resize(m *mat.Dense, r, c int) {
+	if rows, cols := m.Dims(); rows != r || cols != c {
+		m.Reset()
+		m.ReuseAs(r, c)
+	}
+}
+
+type denseConfig struct {
+	InSize  int  `json:"insize"`
+	OutSize int  `json:"outsize"`
+	Masked  bool `json:"masked,omitempty"`
+}
+
+func (layer *DenseLayer) MarshalConfig() ([]byte, error) {
+	insize, outsize := layer.Weights.Dims()
+	return json.Marshal(denseConfig{InSize: insize, OutSize: outsize, Masked: layer.Mask != nil})
+}
+
+// size checks the sizes of c and returns the number of parameter elements
+// they allocate.
+func (c denseConfig) size() (int, error) {
+	if c.InSize <= 0 || c.OutSize <= 0 {
+		return 0, errors.New("dense layer sizes must be positive")
+	}
+	if err := CheckSize(c.InSize, c.OutSize); err != nil {
+		return 0, err
+	}
+	size := c.InSize*c.OutSize + c.OutSize
+	if c.Masked {
+		size += c.InSize * c.OutSize
+	}
+	return size, nil
+}
+
+func (layer *DenseLayer) ParamSize(config []byte) (int, error) {
+	var c denseConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return 0, err
+	}
+	return c.size()
+}
+
+func (layer *DenseLayer) UnmarshalConfig(config []byte) error {
+	var c denseConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if _, err := c.size(); err != nil {
+		return err
+	}
+	layer.Weights = mat.NewDense(c.InSize, c.OutSize, nil)
+	layer.Biases = mat.NewDense(1, c.OutSize, nil)
+	layer.Input = mat.NewDense(1, c.InSize, nil)
+	layer.Mask = nil
+	if c.Masked {
+		layer.Mask = mat.NewDense(c.InSize, c.OutSize, nil)
+	}
+	return nil
+}
+
+func (layer *DenseLayer) Params() []Param {
+	params := []Param{
+		{Name: "weights", Value: layer.Weights},
+		{Name: "biases", Value: layer.Biases},
+	}
+	if layer.Mask != nil {
+		params = append(params, Param{Name: "mask", Value: layer.Mask})
+	}
+	return params
+}
+
+func (layer *DenseLayer) InputSize() int {
+	insize, _ := layer.Weights.Dims()
+	return insize
+}
 
-	return &input_grad
+func (layer *DenseLayer) OutputSize() int {
+	_, outsize := layer.Weights.Dims()
+	return outsize
 }
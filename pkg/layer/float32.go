@@ -0,0 +1,90 @@
+package layer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kapilpokhrel/goNN/pkg/f32"
+)
+
+// Layer32 is a layer converted to float32 for inference, see package f32.
+// Like Infer, Infer32 only reads the layer.
+type Layer32 interface {
+	Infer32(input *f32.Matrix) (*f32.Matrix, error)
+}
+
+// Converter32 is implemented by layers that can run in float32. Convert32
+// copies the current weights, later changes to the layer aren't seen by the
+// converted one.
+type Converter32 interface {
+	Convert32() (Layer32, error)
+}
+
+// To32 converts l, or fails if it has no float32 version.
+func To32(l Layer) (Layer32, error) {
+	converter, ok := l.(Converter32)
+	if !ok {
+		return nil, fmt.Errorf("layer %T can't run in float32", l)
+	}
+	return converter.Convert32()
+}
+
+type dense32 struct {
+	weights *f32.Matrix
+	biases  []float32
+}
+
+func (layer *DenseLayer) Convert32() (Layer32, error) {
+	return &dense32{
+		weights: f32.FromDense(layer.Weights),
+		biases:  f32.FromDense(layer.Biases).Data,
+	}, nil
+}
+
+func (layer *dense32) Infer32(input *f32.Matrix) (*f32.Matrix, error) {
+	if input.Cols != layer.weights.Rows {
+		return nil, fmt.Errorf("input size %d is not compataible with this layer", input.Cols)
+	}
+	output := f32.Mul(input, layer.weights)
+	output.AddRow(layer.biases)
+	return output, nil
+}
+
+type tanh32 struct{}
+
+func (layer *TanhLayer) Convert32() (Layer32, error) {
+	return tanh32{}, nil
+}
+
+func (tanh32) Infer32(input *f32.Matrix) (*f32.Matrix, error) {
+	result := f32.New(input.Rows, input.Cols, nil)
+	for i, v := range input.Data {
+		result.Data[i] = float32(math.Tanh(float64(v)))
+	}
+	return result, nil
+}
+
+type sequential32 []Layer32
+
+// Convert32 fails if one of the layers can't run in float32.
+func (layer *SequentialLayer) Convert32() (Layer32, error) {
+	converted := make(sequential32, len(layer.Layers))
+	for i, child := range layer.Layers {
+		var err error
+		if converted[i], err = To32(child); err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+func (layers sequential32) Infer32(input *f32.Matrix) (*f32.Matrix, error) {
+	result := input
+	for _, child := range layers {
+		var err error
+		if result, err = child.Infer32(result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -1,10 +1,133 @@
 package layer
 
 import (
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
 	"gonum.org/v1/gonum/mat"
 )
 
+// Layer is one step of a network. The first dimension of its input is the
+// batch. Layers working on vectors of features, like Dense and Tanh, use
+// the last dimension and apply to every element of the others, so a
+// [batch, steps, features] sequence is handled one step at a time.
+//
+// The tensors returned by Forward and Backward may be owned by the layer,
+// to be overwritten by its next call, like the result of Network.Forward.
+// Clone them to keep them longer.
 type Layer interface {
+	Forward(input *tensor.Tensor) (*tensor.Tensor, error)
+	Backward(out_grad *tensor.Tensor, rate float64) *tensor.Tensor
+}
+
+// Inferer is implemented by layers that can compute their output without
+// keeping anything for Backward. Infer only reads the layer, so unlike
+// Forward it can be called from several goroutines at once.
+type Inferer interface {
+	Infer(input *tensor.Tensor) (*tensor.Tensor, error)
+}
+
+// RowLayer is implemented by layers working on vectors of features. They
+// also run on matrices with one vector per row, which is how networks made
+// only of them are trained and run, without converting between layers.
+// ForwardRows and BackwardRows follow the same ownership rules as Forward
+// and Backward.
+type RowLayer interface {
+	ForwardRows(input *mat.Dense) (*mat.Dense, error)
+	BackwardRows(out_grad *mat.Dense, rate float64) *mat.Dense
+}
+
+// RowInferer is the Inferer counterpart for RowLayers.
+type RowInferer interface {
+	InferRows(input *mat.Dense) (*mat.Dense, error)
+}
+
+// InfererInto is implemented by RowInferers that can write their output to
+// a matrix given by the caller, so that it can be reused between calls.
+// The matrix is resized to fit, its storage is kept when large enough.
+type InfererInto interface {
+	InferInto(output, input *mat.Dense) error
+}
+
+// MatrixLayer is the Layer interface of earlier versions, over matrices
+// with one sample per row. FromMatrix turns one into a Layer.
+type MatrixLayer interface {
 	Forward(input *mat.Dense) (*mat.Dense, error)
 	Backward(out_grad *mat.Dense, rate float64) *mat.Dense
 }
+
+// MatrixInferer is the Inferer counterpart for MatrixLayers.
+type MatrixInferer interface {
+	Infer(input *mat.Dense) (*mat.Dense, error)
+}
+
+// MergeInferer is the Inferer counterpart for merge layers.
+type MergeInferer interface {
+	Infer(inputs []*tensor.Tensor) (*tensor.Tensor, error)
+}
+
+// CanInfer reports whether l, and every layer inside a Sequential l,
+// implements Inferer or MergeInferer. A layer wrapped by FromMatrix can
+// infer if it implements MatrixInferer.
+func CanInfer(l any) bool {
+	switch l := l.(type) {
+	case *SequentialLayer:
+		for _, child := range l.Layers {
+			if !CanInfer(child) {
+				return false
+			}
+		}
+		return true
+	case *MatrixAdapter:
+		_, ok := l.Layer.(MatrixInferer)
+		return ok
+	case Inferer, MergeInferer:
+		return true
+	}
+	return false
+}
+
+// Rowwise reports whether l, and every layer inside a Sequential l,
+// implements RowLayer.
+func Rowwise(l any) bool {
+	if sequential, ok := l.(*SequentialLayer); ok {
+		for _, child := range sequential.Layers {
+			if !Rowwise(child) {
+				return false
+			}
+		}
+		return true
+	}
+	_, ok := l.(RowLayer)
+	return ok
+}
+
+// TrainingReleaser is implemented by layers that keep data only Backward
+// needs, built on its first call. Network and Graph call ReleaseTraining
+// when training returns, so layers only used for inference afterwards
+// don't hold on to it.
+type TrainingReleaser interface {
+	ReleaseTraining()
+}
+
+// ReleaseTraining calls ReleaseTraining on l if it implements
+// TrainingReleaser.
+func ReleaseTraining(l any) {
+	if releaser, ok := l.(TrainingReleaser); ok {
+		releaser.ReleaseTraining()
+	}
+}
+
+// Shaped is implemented by layers that know the size of the last
+// dimension of their input and output. A size of 0 means any size is
+// accepted.
+type Shaped interface {
+	InputSize() int
+	OutputSize() int
+}
+
+// Sizes returns the input and output sizes of l, or 0 when unknown.
+func Sizes(l any) (int, int) {
+	if shaped, ok := l.(Shaped); ok {
+		return shaped.InputSize(), shaped.OutputSize()
+	}
+	return 0, 0
+}
@@ -0,0 +1,138 @@
+package layer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+// MatrixAdapter runs a MatrixLayer as a Layer, so layers written for the
+// matrix interface of earlier versions keep working. Like Dense, it passes
+// the last dimension of its input as the columns and every other one as
+// rows.
+//
+// It is registered as "Matrix" and saved with the spec of the wrapped
+// layer, which has to be registered too for the network to be saved,
+// replicated for parallel training or quantized.
+type MatrixAdapter struct {
+	Layer MatrixLayer
+}
+
+func FromMatrix(l MatrixLayer) *MatrixAdapter {
+	return &MatrixAdapter{Layer: l}
+}
+
+// AsLayer returns l as a Layer, wrapping it with FromMatrix if it is a
+// MatrixLayer, or false if it is neither. Models saved with matrix layers
+// by earlier versions are loaded this way.
+func AsLayer(l any) (Layer, bool) {
+	switch l := l.(type) {
+	case Layer:
+		return l, true
+	case MatrixLayer:
+		return FromMatrix(l), true
+	}
+	return nil, false
+}
+
+// Forward returns a tensor sharing the matrix returned by the wrapped
+// layer's Forward. When that matrix is owned by the layer, so is the
+// tensor: the next Forward overwrites it. Clone it to keep it longer.
+func (adapter *MatrixAdapter) Forward(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return forwardRows(adapter, input)
+}
+
+// Backward returns a tensor sharing the wrapped layer's gradient, see
+// Forward.
+func (adapter *MatrixAdapter) Backward(out_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	return backwardRows(adapter, out_grad, rate)
+}
+
+// Infer fails if the wrapped layer isn't a MatrixInferer, see CanInfer.
+func (adapter *MatrixAdapter) Infer(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return inferRows(adapter, input)
+}
+
+func (adapter *MatrixAdapter) ForwardRows(input *mat.Dense) (*mat.Dense, error) {
+	return adapter.Layer.Forward(input)
+}
+
+func (adapter *MatrixAdapter) BackwardRows(out_grad *mat.Dense, rate float64) *mat.Dense {
+	return adapter.Layer.Backward(out_grad, rate)
+}
+
+func (adapter *MatrixAdapter) InferRows(input *mat.Dense) (*mat.Dense, error) {
+	inferer, ok := adapter.Layer.(MatrixInferer)
+	if !ok {
+		return nil, fmt.Errorf("layer %T can't infer without storing its input", adapter.Layer)
+	}
+	return inferer.Infer(input)
+}
+
+func (adapter *MatrixAdapter) ReleaseTraining() {
+	ReleaseTraining(adapter.Layer)
+}
+
+func (adapter *MatrixAdapter) MarshalConfig() ([]byte, error) {
+	spec, err := Describe(adapter.Layer)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(spec)
+}
+
+// ParamSize is the parameter size of the wrapped layer.
+func (adapter *MatrixAdapter) ParamSize(config []byte) (int, error) {
+	var spec Spec
+	if err := json.Unmarshal(config, &spec); err != nil {
+		return 0, err
+	}
+	return ParamSize(spec)
+}
+
+func (adapter *MatrixAdapter) UnmarshalConfig(config []byte) error {
+	var spec Spec
+	if err := json.Unmarshal(config, &spec); err != nil {
+		return err
+	}
+	l, err := Build(spec)
+	if err != nil {
+		return err
+	}
+	matrix, ok := l.(MatrixLayer)
+	if !ok {
+		return fmt.Errorf("%s is not a matrix layer", spec.Type)
+	}
+	adapter.Layer = matrix
+	return nil
+}
+
+// Params returns the parameters of the wrapped layer, under their own
+// names.
+func (adapter *MatrixAdapter) Params() []Param {
+	if serializable, ok := adapter.Layer.(Serializable); ok {
+		return serializable.Params()
+	}
+	return nil
+}
+
+// Convert32 fails if the wrapped layer can't run in float32.
+func (adapter *MatrixAdapter) Convert32() (Layer32, error) {
+	converter, ok := adapter.Layer.(Converter32)
+	if !ok {
+		return nil, fmt.Errorf("layer %T can't run in float32", adapter.Layer)
+	}
+	return converter.Convert32()
+}
+
+func (adapter *MatrixAdapter) InputSize() int {
+	insize, _ := Sizes(adapter.Layer)
+	return insize
+}
+
+func (adapter *MatrixAdapter) OutputSize() int {
+	_, outsize := Sizes(adapter.Layer)
+	return outsize
+}
@@ -0,0 +1,225 @@
+package layer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+)
+
+// MergeLayer combines the outputs of several layers into one.
+// It is used by the graph models in the network package.
+type MergeLayer interface {
+	Forward(inputs []*tensor.Tensor) (*tensor.Tensor, error)
+	Backward(out_grad *tensor.Tensor, rate float64) []*tensor.Tensor
+}
+
+// MergeShaped is the Shaped counterpart for merge layers. It returns the
+// output size for the given input sizes, where 0 means unknown.
+type MergeShaped interface {
+	MergedSize(insizes []int) (int, error)
+}
+
+type AddLayer struct {
+	Inputs []*tensor.Tensor
+}
+
+func Add() *AddLayer {
+	return &AddLayer{}
+}
+
+func (layer *AddLayer) Forward(inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	output, err := layer.Infer(inputs)
+	if err != nil {
+		return nil, err
+	}
+	layer.Inputs = inputs
+	return output, nil
+}
+
+func (layer *AddLayer) Infer(inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	if err := checkSameShape(inputs); err != nil {
+		return nil, err
+	}
+
+	// y = x1 + x2 + ... + xn
+	output := inputs[0]
+	for _, input := range inputs[1:] {
+		var err error
+		if output, err = tensor.Add(output, input); err != nil {
+			return nil, err
+		}
+	}
+	if len(inputs) == 1 {
+		output = output.Clone()
+	}
+	return output, nil
+}
+
+func (layer *AddLayer) Backward(output_grad *tensor.Tensor, rate float64) []*tensor.Tensor {
+	/*
+		y = x1 + x2 + ... + xn
+		dy/dxi = 1, so every input receives the output gradient unchanged.
+	*/
+	input_grads := make([]*tensor.Tensor, len(layer.Inputs))
+	for i := range input_grads {
+		input_grads[i] = output_grad.Clone()
+	}
+	return input_grads
+}
+
+type MultiplyLayer struct {
+	Inputs []*tensor.Tensor
+}
+
+func Multiply() *MultiplyLayer {
+	return &MultiplyLayer{}
+}
+
+func (layer *MultiplyLayer) Forward(inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	output, err := layer.Infer(inputs)
+	if err != nil {
+		return nil, err
+	}
+	layer.Inputs = inputs
+	return output, nil
+}
+
+func (layer *MultiplyLayer) Infer(inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	if err := checkSameShape(inputs); err != nil {
+		return nil, err
+	}
+
+	// y = x1 * x2 * ... * xn ; elementwise
+	output := inputs[0]
+	for _, input := range inputs[1:] {
+		var err error
+		if output, err = tensor.Mul(output, input); err != nil {
+			return nil, err
+		}
+	}
+	if len(inputs) == 1 {
+		output = output.Clone()
+	}
+	return output, nil
+}
+
+func (layer *MultiplyLayer) Backward(output_grad *tensor.Tensor, rate float64) []*tensor.Tensor {
+	/*
+		y = x1 * x2 * ... * xn
+		dy/dxi = product of every input except xi
+
+		dL/dxi = dL/dy * (x1 * .. * x(i-1) * x(i+1) * .. * xn)
+	*/
+	input_grads := make([]*tensor.Tensor, len(layer.Inputs))
+	for i := range layer.Inputs {
+		grad := output_grad.Clone()
+		for j, input := range layer.Inputs {
+			if j != i {
+				// the shapes were checked by Forward
+				grad, _ = tensor.Mul(grad, input)
+			}
+		}
+		input_grads[i] = grad
+	}
+	return input_grads
+}
+
+// ConcatLayer joins its inputs along their last dimension, the features.
+type ConcatLayer struct {
+	Inputs []*tensor.Tensor
+}
+
+func Concat() *ConcatLayer {
+	return &ConcatLayer{}
+}
+
+func (layer *ConcatLayer) Forward(inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	output, err := layer.Infer(inputs)
+	if err != nil {
+		return nil, err
+	}
+	layer.Inputs = inputs
+	return output, nil
+}
+
+func (layer *ConcatLayer) Infer(inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("merge layer needs at least one input")
+	}
+	// [y] = [x1 | x2 | ... | xn]
+	output, err := tensor.Concat(-1, inputs...)
+	if err != nil {
+		return nil, fmt.Errorf("inputs to concat must only differ in their last dimension: %w", err)
+	}
+	return output, nil
+}
+
+func (layer *ConcatLayer) Backward(output_grad *tensor.Tensor, rate float64) []*tensor.Tensor {
+	/*
+		Each input only affects its own block of features in the output,
+		so its gradient is the matching slice of the output gradient.
+	*/
+	input_grads := make([]*tensor.Tensor, len(layer.Inputs))
+	offset := 0
+	for i, input := range layer.Inputs {
+		size := input.Dim(-1)
+		input_grads[i] = output_grad.Slice(-1, offset, offset+size).Clone()
+		offset += size
+	}
+	return input_grads
+}
+
+func checkSameShape(inputs []*tensor.Tensor) error {
+	if len(inputs) == 0 {
+		return errors.New("merge layer needs at least one input")
+	}
+	shape := inputs[0].Shape()
+	for _, input := range inputs[1:] {
+		if !slices.Equal(input.Shape(), shape) {
+			return errors.New("inputs to merge layer must have the same size")
+		}
+	}
+	return nil
+}
+
+func (layer *AddLayer) MarshalConfig() ([]byte, error)      { return nil, nil }
+func (layer *AddLayer) UnmarshalConfig(config []byte) error { return nil }
+func (layer *AddLayer) Params() []Param                     { return nil }
+
+func (layer *MultiplyLayer) MarshalConfig() ([]byte, error)      { return nil, nil }
+func (layer *MultiplyLayer) UnmarshalConfig(config []byte) error { return nil }
+func (layer *MultiplyLayer) Params() []Param                     { return nil }
+
+func (layer *ConcatLayer) MarshalConfig() ([]byte, error)      { return nil, nil }
+func (layer *ConcatLayer) UnmarshalConfig(config []byte) error { return nil }
+func (layer *ConcatLayer) Params() []Param                     { return nil }
+
+func (layer *AddLayer) MergedSize(insizes []int) (int, error)      { return sameSize(insizes) }
+func (layer *MultiplyLayer) MergedSize(insizes []int) (int, error) { return sameSize(insizes) }
+
+func (layer *ConcatLayer) MergedSize(insizes []int) (int, error) {
+	total := 0
+	for _, size := range insizes {
+		if size == 0 {
+			return 0, nil
+		}
+		total += size
+	}
+	return total, nil
+}
+
+func sameSize(insizes []int) (int, error) {
+	size := 0
+	for _, insize := range insizes {
+		if insize == 0 {
+			continue
+		}
+		if size != 0 && insize != size {
+			return 0, errors.New("inputs to merge layer must have the same size")
+		}
+		size = insize
+	}
+	return size, nil
+}
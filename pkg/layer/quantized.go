@@ -0,0 +1,428 @@
+package layer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/mat"
+)
+
+// QuantParams maps an int8 value q to the real value Scale * (q - ZeroPoint).
+type QuantParams struct {
+	Scale     float64
+	ZeroPoint int
+}
+
+// QuantRange returns the parameters spreading the 256 int8 values over
+// [min, max]. The range is widened to include 0, so that 0 is exact.
+func QuantRange(min, max float64) QuantParams {
+	min, max = math.Min(min, 0), math.Max(max, 0)
+	if min == max {
+		return QuantParams{Scale: 1}
+	}
+	scale := (max - min) / 255
+	return QuantParams{Scale: scale, ZeroPoint: int(math.Round(-128 - min/scale))}
+}
+
+// Quantize rounds v to the nearest int8, values out of range are clamped.
+func (p QuantParams) Quantize(v float64) int8 {
+	q := math.Round(v/p.Scale) + float64(p.ZeroPoint)
+	return int8(math.Max(-128, math.Min(127, q)))
+}
+
+func (p QuantParams) Dequantize(q int8) float64 {
+	return p.Scale * float64(int(q)-p.ZeroPoint)
+}
+
+// Int8Matrix is a row-major matrix of int8 values.
+type Int8Matrix struct {
+	Rows, Cols int
+	Data       []int8
+}
+
+// NewInt8Matrix returns a rows×cols matrix using data, or a zeroed one if
+// data is nil, like mat.NewDense.
+func NewInt8Matrix(rows, cols int, data []int8) *Int8Matrix {
+	if data == nil {
+		data = make([]int8, rows*cols)
+	}
+	if len(data) != rows*cols {
+		panic(mat.ErrShape)
+	}
+	return &Int8Matrix{Rows: rows, Cols: cols, Data: data}
+}
+
+func (m *Int8Matrix) Dims() (int, int)     { return m.Rows, m.Cols }
+func (m *Int8Matrix) At(i, j int) int8     { return m.Data[i*m.Cols+j] }
+func (m *Int8Matrix) Set(i, j int, v int8) { m.Data[i*m.Cols+j] = v }
+
+// Dense returns the values of m as float64.
+func (m *Int8Matrix) Dense() *mat.Dense {
+	values := make([]float64, len(m.Data))
+	for i, v := range m.Data {
+		values[i] = float64(v)
+	}
+	return mat.NewDense(m.Rows, m.Cols, values)
+}
+
+// CopyFrom copies value into m. value must have the shape of m and hold
+// integers in the int8 range, otherwise m is left unchanged.
+func (m *Int8Matrix) CopyFrom(value mat.Matrix) error {
+	if rows, cols := value.Dims(); rows != m.Rows || cols != m.Cols {
+		return fmt.Errorf("shape %dx%d doesn't match the int8 matrix (%dx%d)", rows, cols, m.Rows, m.Cols)
+	}
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			if v := value.At(i, j); v != math.Trunc(v) || v < math.MinInt8 || v > math.MaxInt8 {
+				return fmt.Errorf("value %v at %d, %d isn't an int8", v, i, j)
+			}
+		}
+	}
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			m.Set(i, j, int8(value.At(i, j)))
+		}
+	}
+	return nil
+}
+
+// QuantizedDenseLayer is a DenseLayer with int8 weights. Its input is
+// quantized with the range calibrated for it, multiplied with the weights in
+// int32 and converted back to float64 for the next layer.
+//
+// Weights holds the int8 values, a byte per weight in memory and in saved
+// files, and Scales and ZeroPoints their quantization parameters, a single
+// one for the whole matrix or one per output column. The weights are
+// frozen: Backward passes gradients on to the previous layers but doesn't
+// change them, and they must not be changed after the first Forward or
+// Infer.
+type QuantizedDenseLayer struct {
+	Weights    *Int8Matrix
+	Scales     *mat.Dense // 1x1 or 1 x outsize
+	ZeroPoints *mat.Dense
+	Biases     *mat.Dense
+	InputQuant QuantParams
+	Input      *mat.Dense
+
+	pack   sync.Once
+	packed *packedWeights
+
+	dequantized *mat.Dense // built by BackwardRows, see ReleaseTraining
+
+	// reused by every Forward and Backward, sized for the last batch
+	output     mat.Dense
+	input_grad mat.Dense
+	scratch    quantScratch
+}
+
+// quantScratch holds the int32 rows of the integer path.
+type quantScratch struct {
+	quantized, acc []int32
+}
+
+// rows returns the scratch rows for insize inputs and outsize outputs.
+func (s *quantScratch) rows(insize, outsize int) ([]int32, []int32) {
+	if len(s.quantized) != insize || len(s.acc) != outsize {
+		s.quantized, s.acc = make([]int32, insize), make([]int32, outsize)
+	}
+	return s.quantized, s.acc
+}
+
+// packedWeights is what the integer path runs on, with the weights.
+type packedWeights struct {
+	biases []int32
+	scales []float64 // input scale * weight scale, per column
+	zeros  []int32
+}
+
+// QuantizeDense quantizes the weights of dense, per output column if
+// per_channel is set. input is the quantization of the layer's input,
+// calibrated on sample data.
+func QuantizeDense(dense *DenseLayer, input QuantParams, per_channel bool) *QuantizedDenseLayer {
+	insize, outsize := dense.Weights.Dims()
+	channels := 1
+	if per_channel {
+		channels = outsize
+	}
+	layer := &QuantizedDenseLayer{
+		Weights:    NewInt8Matrix(insize, outsize, nil),
+		Scales:     mat.NewDense(1, channels, nil),
+		ZeroPoints: mat.NewDense(1, channels, nil),
+		Biases:     mat.DenseCopyOf(dense.Biases),
+		InputQuant: input,
+		Input:      mat.NewDense(1, insize, nil),
+	}
+
+	for c := 0; c < channels; c++ {
+		columns := dense.Weights
+		if per_channel {
+			columns = dense.Weights.Slice(0, insize, c, c+1).(*mat.Dense)
+		}
+		params := QuantRange(mat.Min(columns), mat.Max(columns))
+		layer.Scales.Set(0, c, params.Scale)
+		layer.ZeroPoints.Set(0, c, float64(params.ZeroPoint))
+	}
+	for i := 0; i < insize; i++ {
+		for j := 0; j < outsize; j++ {
+			layer.Weights.Set(i, j, layer.weightQuant(j).Quantize(dense.Weights.At(i, j)))
+		}
+	}
+	return layer
+}
+
+// weightQuant returns the quantization parameters of column j.
+func (layer *QuantizedDenseLayer) weightQuant(j int) QuantParams {
+	if _, channels := layer.Scales.Dims(); channels == 1 {
+		j = 0
+	}
+	return QuantParams{Scale: layer.Scales.At(0, j), ZeroPoint: int(layer.ZeroPoints.At(0, j))}
+}
+
+func (layer *QuantizedDenseLayer) packWeights() *packedWeights {
+	layer.pack.Do(func() {
+		_, outsize := layer.Weights.Dims()
+		packed := &packedWeights{
+			biases: make([]int32, outsize),
+			scales: make([]float64, outsize),
+			zeros:  make([]int32, outsize),
+		}
+		for j := 0; j < outsize; j++ {
+			weights := layer.weightQuant(j)
+			packed.scales[j] = layer.InputQuant.Scale * weights.Scale
+			packed.zeros[j] = int32(weights.ZeroPoint)
+			// biases are added to the accumulator, at its scale
+			packed.biases[j] = int32(math.Round(layer.Biases.At(0, j) / packed.scales[j]))
+		}
+		layer.packed = packed
+	})
+	return layer.packed
+}
+
+// Forward returns a tensor owned by the layer, which the next Forward
+// overwrites, see ForwardRows.
+func (layer *QuantizedDenseLayer) Forward(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return forwardRows(layer, input)
+}
+
+func (layer *QuantizedDenseLayer) Backward(out_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	return backwardRows(layer, out_grad, rate)
+}
+
+func (layer *QuantizedDenseLayer) Infer(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return inferRows(layer, input)
+}
+
+// ForwardRows returns a matrix owned by the layer, which the next Forward
+// overwrites. Copy it to keep it longer.
+func (layer *QuantizedDenseLayer) ForwardRows(input *mat.Dense) (*mat.Dense, error) {
+	if err := layer.infer(&layer.output, input, &layer.scratch); err != nil {
+		return nil, err
+	}
+	layer.Input = input
+	return &layer.output, nil
+}
+
+func (layer *QuantizedDenseLayer) InferRows(input *mat.Dense) (*mat.Dense, error) {
+	var output mat.Dense
+	if err := layer.InferInto(&output, input); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// InferInto allocates the int32 rows of the integer path, as it may run on
+// several goroutines at once. ForwardRows reuses the layer's.
+func (layer *QuantizedDenseLayer) InferInto(output, input *mat.Dense) error {
+	return layer.infer(output, input, &quantScratch{})
+}
+
+func (layer *QuantizedDenseLayer) infer(output, input *mat.Dense, scratch *quantScratch) error {
+	/*
+		With x = sx * (qx - zx) and w = sw * (qw - zw) for every column,
+
+		y_j = sum_i x_i * w_ij + b_j
+		    = sx * sw_j * (sum_i (qx_i - zx) * (qw_ij - zw_j) + b_j / (sx * sw_j))
+
+		The sum is computed in int32 as
+		sum_i (qx_i - zx) * qw_ij - zw_j * sum_i (qx_i - zx)
+		so the weights stay int8.
+	*/
+	rows, in_c := input.Dims()
+	w_r, outsize := layer.Weights.Dims()
+	if in_c != w_r {
+		return errors.New("input size is not compataible with this layer")
+	}
+
+	packed := layer.packWeights()
+	zero := int32(layer.InputQuant.ZeroPoint)
+	resize(output, rows, outsize)
+	quantized, acc := scratch.rows(in_c, outsize)
+	for r := 0; r < rows; r++ {
+		sum := int32(0)
+		for i := range quantized {
+			quantized[i] = int32(layer.InputQuant.Quantize(input.At(r, i))) - zero
+			sum += quantized[i]
+		}
+
+		copy(acc, packed.biases)
+		for i, x := range quantized {
+			if x == 0 {
+				continue
+			}
+			weights := layer.Weights.Data[i*outsize : (i+1)*outsize]
+			for j, w := range weights {
+				acc[j] += x * int32(w)
+			}
+		}
+
+		row := output.RawRowView(r)
+		for j := range row {
+			row[j] = float64(acc[j]-packed.zeros[j]*sum) * packed.scales[j]
+		}
+	}
+	return nil
+}
+
+// Dequantized returns the weights as float64, as the layer sees them.
+func (layer *QuantizedDenseLayer) Dequantized() *mat.Dense {
+	insize, outsize := layer.Weights.Dims()
+	weights := mat.NewDense(insize, outsize, nil)
+	for i := 0; i < insize; i++ {
+		for j := 0; j < outsize; j++ {
+			weights.Set(i, j, layer.weightQuant(j).Dequantize(layer.Weights.At(i, j)))
+		}
+	}
+	return weights
+}
+
+// Dense converts the layer to a DenseLayer with the dequantized weights and
+// the biases rounded the way the integer path adds them. Only the
+// quantization of the input is lost.
+func (layer *QuantizedDenseLayer) Dense() *DenseLayer {
+	insize, outsize := layer.Weights.Dims()
+	packed := layer.packWeights()
+	biases := mat.NewDense(1, outsize, nil)
+	for j := 0; j < outsize; j++ {
+		biases.Set(0, j, float64(packed.biases[j])*packed.scales[j])
+	}
+	return &DenseLayer{
+		Weights: layer.Dequantized(),
+		Biases:  biases,
+		Input:   mat.NewDense(1, insize, nil),
+	}
+}
+
+// BackwardRows returns the gradient with respect to the input, computed with
+// the dequantized weights, without updating the layer. The dequantized
+// weights are built by the first call and kept until ReleaseTraining.
+func (layer *QuantizedDenseLayer) BackwardRows(output_grad *mat.Dense, rate float64) *mat.Dense {
+	rows, _ := output_grad.Dims()
+	insize, _ := layer.Weights.Dims()
+	// the gradient is owned by the layer, like the output of Forward
+	input_grad := &layer.input_grad
+	resize(input_grad, rows, insize)
+	if layer.dequantized == nil {
+		layer.dequantized = layer.Dequantized()
+	}
+	blas64.Gemm(blas.NoTrans, blas.Trans, 1, output_grad.RawMatrix(), layer.dequantized.RawMatrix(), 0, input_grad.RawMatrix())
+	return input_grad
+}
+
+// ReleaseTraining drops the dequantized weights of BackwardRows, so that a
+// layer used for inference only keeps its int8 weights.
+func (layer *QuantizedDenseLayer) ReleaseTraining() {
+	layer.dequantized = nil
+}
+
+type quantizedDenseConfig struct {
+	InSize         int     `json:"insize"`
+	OutSize        int     `json:"outsize"`
+	PerChannel     bool    `json:"per_channel"`
+	InputScale     float64 `json:"input_scale"`
+	InputZeroPoint int     `json:"input_zero_point"`
+}
+
+func (layer *QuantizedDenseLayer) MarshalConfig() ([]byte, error) {
+	insize, outsize := layer.Weights.Dims()
+	_, channels := layer.Scales.Dims()
+	return json.Marshal(quantizedDenseConfig{
+		InSize:         insize,
+		OutSize:        outsize,
+		PerChannel:     channels != 1,
+		InputScale:     layer.InputQuant.Scale,
+		InputZeroPoint: layer.InputQuant.ZeroPoint,
+	})
+}
+
+// channels returns the number of quantization channels of c.
+func (c quantizedDenseConfig) channels() int {
+	if c.PerChannel {
+		return c.OutSize
+	}
+	return 1
+}
+
+// size checks c and returns the number of parameter elements it allocates.
+func (c quantizedDenseConfig) size() (int, error) {
+	if c.InSize <= 0 || c.OutSize <= 0 {
+		return 0, errors.New("quantized dense layer sizes must be positive")
+	}
+	if err := CheckSize(c.InSize, c.OutSize); err != nil {
+		return 0, err
+	}
+	if c.InputScale <= 0 || c.InputZeroPoint < -128 || c.InputZeroPoint > 127 {
+		return 0, fmt.Errorf("invalid input quantization, scale %v and zero point %d", c.InputScale, c.InputZeroPoint)
+	}
+	return c.InSize*c.OutSize + 2*c.channels() + c.OutSize, nil
+}
+
+func (layer *QuantizedDenseLayer) ParamSize(config []byte) (int, error) {
+	var c quantizedDenseConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return 0, err
+	}
+	return c.size()
+}
+
+func (layer *QuantizedDenseLayer) UnmarshalConfig(config []byte) error {
+	var c quantizedDenseConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if _, err := c.size(); err != nil {
+		return err
+	}
+	channels := c.channels()
+	layer.Weights = NewInt8Matrix(c.InSize, c.OutSize, nil)
+	layer.Scales = mat.NewDense(1, channels, nil)
+	layer.ZeroPoints = mat.NewDense(1, channels, nil)
+	layer.Biases = mat.NewDense(1, c.OutSize, nil)
+	layer.InputQuant = QuantParams{Scale: c.InputScale, ZeroPoint: c.InputZeroPoint}
+	layer.Input = mat.NewDense(1, c.InSize, nil)
+	return nil
+}
+
+func (layer *QuantizedDenseLayer) Params() []Param {
+	return []Param{
+		{Name: "weights", Int8: layer.Weights},
+		{Name: "scales", Value: layer.Scales},
+		{Name: "zero_points", Value: layer.ZeroPoints},
+		{Name: "biases", Value: layer.Biases},
+	}
+}
+
+func (layer *QuantizedDenseLayer) InputSize() int {
+	insize, _ := layer.Weights.Dims()
+	return insize
+}
+
+func (layer *QuantizedDenseLayer) OutputSize() int {
+	_, outsize := layer.Weights.Dims()
+	return outsize
+}
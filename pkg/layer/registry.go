@@ -0,0 +1,168 @@
+package layer
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Param is a named weight matrix owned by a layer. Value points at the
+// layer's own matrix, so loading a model copies the saved values into it.
+// Int8 parameters, like the weights of a QuantizedDenseLayer, are in Int8
+// instead, Value is nil for them. They aren't trained.
+type Param struct {
+	Name  string
+	Value *mat.Dense
+	Int8  *Int8Matrix
+}
+
+// Serializable is implemented by every layer that can be saved and loaded.
+//
+// MarshalConfig describes everything needed to rebuild the layer except its
+// weights (sizes, options, children). UnmarshalConfig is called on the
+// value returned by the registered factory and must allocate every matrix
+// returned by Params with its final shape.
+type Serializable interface {
+	MarshalConfig() ([]byte, error)
+	UnmarshalConfig(config []byte) error
+	Params() []Param
+}
+
+// Sizer is implemented by layers whose UnmarshalConfig allocates
+// parameters. ParamSize validates config like UnmarshalConfig and returns
+// the number of parameter elements it would allocate, without allocating
+// them, so that configs read from files can be checked against the data
+// backing them first.
+type Sizer interface {
+	ParamSize(config []byte) (int, error)
+}
+
+// MaxParamSize is the most elements a config may allocate for a single
+// parameter, 2 GiB of float64. Configs are read from model files, larger
+// sizes are rejected as corrupt instead of running out of memory.
+var MaxParamSize = 1 << 28
+
+// CheckSize returns an error unless a rows×cols parameter has a positive
+// shape and at most MaxParamSize elements.
+func CheckSize(rows, cols int) error {
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("parameter shape %dx%d must be positive", rows, cols)
+	}
+	if rows > MaxParamSize/cols {
+		return fmt.Errorf("parameter shape %dx%d is larger than MaxParamSize (%d)", rows, cols, MaxParamSize)
+	}
+	return nil
+}
+
+// Spec identifies a layer type and its configuration.
+type Spec struct {
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]func() Serializable)
+	names      = make(map[reflect.Type]string)
+)
+
+func init() {
+	Register("Dense", func() Serializable { return &DenseLayer{} })
+	Register("QuantizedDense", func() Serializable { return &QuantizedDenseLayer{} })
+	Register("SparseDense", func() Serializable { return &SparseDenseLayer{} })
+	Register("Tanh", func() Serializable { return &TanhLayer{} })
+	Register("Sequential", func() Serializable { return &SequentialLayer{} })
+	Register("Add", func() Serializable { return Add() })
+	Register("Multiply", func() Serializable { return Multiply() })
+	Register("Concat", func() Serializable { return Concat() })
+	Register("Matrix", func() Serializable { return &MatrixAdapter{} })
+}
+
+// Register makes a layer type available to Describe and Build under the
+// given name. The factory must return a new, empty layer each time.
+// Register panics if the name is already taken, like database/sql.Register.
+func Register(name string, factory func() Serializable) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("layer: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("layer: Register called twice for " + name)
+	}
+	factories[name] = factory
+	names[reflect.TypeOf(factory())] = name
+}
+
+// NameOf returns the name a layer's type was registered with.
+func NameOf(l any) (string, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	name, ok := names[reflect.TypeOf(l)]
+	if !ok {
+		return "", fmt.Errorf("layer type %T is not registered", l)
+	}
+	return name, nil
+}
+
+// New returns an empty layer of the registered type name.
+func New(name string) (Serializable, error) {
+	registryMu.RLock()
+	factory, ok := factories[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown layer type %q", name)
+	}
+	return factory(), nil
+}
+
+func Describe(l any) (Spec, error) {
+	name, err := NameOf(l)
+	if err != nil {
+		return Spec{}, err
+	}
+	serializable, ok := l.(Serializable)
+	if !ok {
+		return Spec{}, fmt.Errorf("layer type %T does not implement layer.Serializable", l)
+	}
+	config, err := serializable.MarshalConfig()
+	if err != nil {
+		return Spec{}, err
+	}
+	return Spec{Type: name, Config: config}, nil
+}
+
+// ParamSize returns the number of parameter elements Build allocates for
+// spec, 0 for layer types that don't implement Sizer.
+func ParamSize(spec Spec) (int, error) {
+	l, err := New(spec.Type)
+	if err != nil {
+		return 0, err
+	}
+	sizer, ok := l.(Sizer)
+	if !ok {
+		return 0, nil
+	}
+	size, err := sizer.ParamSize(spec.Config)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", spec.Type, err)
+	}
+	return size, nil
+}
+
+func Build(spec Spec) (Serializable, error) {
+	l, err := New(spec.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.UnmarshalConfig(spec.Config); err != nil {
+		return nil, fmt.Errorf("%s: %w", spec.Type, err)
+	}
+	return l, nil
+}
@@ -0,0 +1,46 @@
+package layer
+
+import (
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+)
+
+/*
+	The layers working on vectors of features implement Forward, Backward
+	and Infer with the matrix versions: every dimension but the last is
+	flattened into the rows of a matrix, which is shaped back afterwards.
+	Both conversions are views when the tensor is contiguous, so the result
+	shares the matrix returned by ForwardRows, owned by the layer.
+*/
+
+func forwardRows(l RowLayer, input *tensor.Tensor) (*tensor.Tensor, error) {
+	rows, err := input.Rows()
+	if err != nil {
+		return nil, err
+	}
+	output, err := l.ForwardRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	return tensor.FromRows(output, input.Shape()), nil
+}
+
+// backwardRows panics if out_grad is a scalar, as Backward can't fail.
+func backwardRows(l RowLayer, out_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	rows, err := out_grad.Rows()
+	if err != nil {
+		panic(err)
+	}
+	return tensor.FromRows(l.BackwardRows(rows, rate), out_grad.Shape())
+}
+
+func inferRows(l RowInferer, input *tensor.Tensor) (*tensor.Tensor, error) {
+	rows, err := input.Rows()
+	if err != nil {
+		return nil, err
+	}
+	output, err := l.InferRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	return tensor.FromRows(output, input.Shape()), nil
+}
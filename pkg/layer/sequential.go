@@ -0,0 +1,189 @@
+package layer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SequentialLayer runs its layers one after another, so a whole block
+// (or a pretrained model) can be used as a single layer.
+type SequentialLayer struct {
+	Layers []Layer
+}
+
+func Sequential(layers ...Layer) *SequentialLayer {
+	return &SequentialLayer{Layers: layers}
+}
+
+func (layer *SequentialLayer) Forward(input *tensor.Tensor) (*tensor.Tensor, error) {
+	result := input
+	for _, child := range layer.Layers {
+		var err error
+		result, err = child.Forward(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Infer fails if one of the layers isn't an Inferer, see CanInfer.
+func (layer *SequentialLayer) Infer(input *tensor.Tensor) (*tensor.Tensor, error) {
+	result := input
+	for _, child := range layer.Layers {
+		inferer, ok := child.(Inferer)
+		if !ok {
+			return nil, fmt.Errorf("layer %T can't infer without storing its input", child)
+		}
+		var err error
+		result, err = inferer.Infer(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (layer *SequentialLayer) Backward(output_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	in_grad := output_grad
+	for i := len(layer.Layers) - 1; i >= 0; i-- {
+		in_grad = layer.Layers[i].Backward(in_grad, rate)
+	}
+	return in_grad
+}
+
+// ForwardRows fails if one of the layers isn't a RowLayer, see Rowwise.
+func (layer *SequentialLayer) ForwardRows(input *mat.Dense) (*mat.Dense, error) {
+	result := input
+	for _, child := range layer.Layers {
+		rows, ok := child.(RowLayer)
+		if !ok {
+			return nil, fmt.Errorf("layer %T doesn't work on rows", child)
+		}
+		var err error
+		result, err = rows.ForwardRows(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// InferRows fails if one of the layers isn't a RowInferer.
+func (layer *SequentialLayer) InferRows(input *mat.Dense) (*mat.Dense, error) {
+	result := input
+	for _, child := range layer.Layers {
+		inferer, ok := child.(RowInferer)
+		if !ok {
+			return nil, fmt.Errorf("layer %T can't infer rows without storing its input", child)
+		}
+		var err error
+		result, err = inferer.InferRows(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// BackwardRows panics if one of the layers isn't a RowLayer, ForwardRows
+// would have failed.
+func (layer *SequentialLayer) BackwardRows(output_grad *mat.Dense, rate float64) *mat.Dense {
+	in_grad := output_grad
+	for i := len(layer.Layers) - 1; i >= 0; i-- {
+		in_grad = layer.Layers[i].(RowLayer).BackwardRows(in_grad, rate)
+	}
+	return in_grad
+}
+
+func (layer *SequentialLayer) ReleaseTraining() {
+	for _, child := range layer.Layers {
+		ReleaseTraining(child)
+	}
+}
+
+func (layer *SequentialLayer) MarshalConfig() ([]byte, error) {
+	specs := make([]Spec, len(layer.Layers))
+	for i, child := range layer.Layers {
+		var err error
+		specs[i], err = Describe(child)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(specs)
+}
+
+// ParamSize is the sum of the parameter sizes of the children.
+func (layer *SequentialLayer) ParamSize(config []byte) (int, error) {
+	var specs []Spec
+	if err := json.Unmarshal(config, &specs); err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, spec := range specs {
+		size, err := ParamSize(spec)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+func (layer *SequentialLayer) UnmarshalConfig(config []byte) error {
+	var specs []Spec
+	if err := json.Unmarshal(config, &specs); err != nil {
+		return err
+	}
+
+	layer.Layers = make([]Layer, len(specs))
+	for i, spec := range specs {
+		child, err := Build(spec)
+		if err != nil {
+			return err
+		}
+		child_layer, ok := AsLayer(child)
+		if !ok {
+			return fmt.Errorf("%s can't be used inside a sequential layer", spec.Type)
+		}
+		layer.Layers[i] = child_layer
+	}
+	return nil
+}
+
+// Params returns the parameters of every child, prefixed by the child's
+// position ("0.weights", "2.1.biases", ...).
+func (layer *SequentialLayer) Params() []Param {
+	params := make([]Param, 0)
+	for i, child := range layer.Layers {
+		serializable, ok := child.(Serializable)
+		if !ok {
+			continue
+		}
+		for _, param := range serializable.Params() {
+			param.Name = fmt.Sprintf("%d.%s", i, param.Name)
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+func (layer *SequentialLayer) InputSize() int {
+	if len(layer.Layers) == 0 {
+		return 0
+	}
+	insize, _ := Sizes(layer.Layers[0])
+	return insize
+}
+
+func (layer *SequentialLayer) OutputSize() int {
+	if len(layer.Layers) == 0 {
+		return 0
+	}
+	_, outsize := Sizes(layer.Layers[len(layer.Layers)-1])
+	return outsize
+}
@@ -0,0 +1,265 @@
+package layer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SparseDenseLayer is a DenseLayer storing only its non-zero weights, in
+// compressed sparse row (CSR) form. For heavily pruned weights it is smaller
+// and faster than the dense layer, see ToSparse.
+//
+// The non-zero weights of input i are Values[RowStarts[i]:RowStarts[i+1]],
+// in the output columns given by the same range of Columns. Training only
+// changes those weights, the others stay 0.
+type SparseDenseLayer struct {
+	InSize, OutSize int
+	RowStarts       []int
+	Columns         []int
+	Values          *mat.Dense // 1 x number of non-zero weights, nil if there are none
+	Biases          *mat.Dense
+	Input           *mat.Dense
+
+	// reused by every Forward and Backward, sized for the last batch
+	output       mat.Dense
+	input_grad   mat.Dense
+	weights_grad []float64
+}
+
+// ToSparse converts dense, leaving out its zero weights.
+func ToSparse(dense *DenseLayer) *SparseDenseLayer {
+	insize, outsize := dense.Weights.Dims()
+	layer := &SparseDenseLayer{
+		InSize:    insize,
+		OutSize:   outsize,
+		RowStarts: make([]int, insize+1),
+		Biases:    mat.DenseCopyOf(dense.Biases),
+		Input:     mat.NewDense(1, insize, nil),
+	}
+	values := make([]float64, 0)
+	for i := 0; i < insize; i++ {
+		for j, v := range dense.Weights.RawRowView(i) {
+			if v != 0 {
+				layer.Columns = append(layer.Columns, j)
+				values = append(values, v)
+			}
+		}
+		layer.RowStarts[i+1] = len(values)
+	}
+	if len(values) > 0 {
+		layer.Values = mat.NewDense(1, len(values), values)
+	}
+	return layer
+}
+
+// Dense converts the layer back to a DenseLayer.
+func (layer *SparseDenseLayer) Dense() *DenseLayer {
+	weights := mat.NewDense(layer.InSize, layer.OutSize, nil)
+	layer.each(func(i, j int, v float64) { weights.Set(i, j, v) })
+	return &DenseLayer{
+		Weights: weights,
+		Biases:  mat.DenseCopyOf(layer.Biases),
+		Input:   mat.NewDense(1, layer.InSize, nil),
+	}
+}
+
+// each calls f for every non-zero weight.
+func (layer *SparseDenseLayer) each(f func(i, j int, v float64)) {
+	for i := 0; i < layer.InSize; i++ {
+		for k := layer.RowStarts[i]; k < layer.RowStarts[i+1]; k++ {
+			f(i, layer.Columns[k], layer.Values.At(0, k))
+		}
+	}
+}
+
+// NonZero returns the number of weights stored.
+func (layer *SparseDenseLayer) NonZero() int {
+	return len(layer.Columns)
+}
+
+// Forward returns a tensor owned by the layer, which the next Forward
+// overwrites, see ForwardRows.
+func (layer *SparseDenseLayer) Forward(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return forwardRows(layer, input)
+}
+
+func (layer *SparseDenseLayer) Backward(out_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	return backwardRows(layer, out_grad, rate)
+}
+
+func (layer *SparseDenseLayer) Infer(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return inferRows(layer, input)
+}
+
+// ForwardRows returns a matrix owned by the layer, which the next Forward
+// overwrites. Copy it to keep it longer.
+func (layer *SparseDenseLayer) ForwardRows(input *mat.Dense) (*mat.Dense, error) {
+	if err := layer.InferInto(&layer.output, input); err != nil {
+		return nil, err
+	}
+	layer.Input = input
+	return &layer.output, nil
+}
+
+func (layer *SparseDenseLayer) InferRows(input *mat.Dense) (*mat.Dense, error) {
+	var output mat.Dense
+	if err := layer.InferInto(&output, input); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+func (layer *SparseDenseLayer) InferInto(output, input *mat.Dense) error {
+	rows, in_c := input.Dims()
+	if in_c != layer.InSize {
+		return errors.New("input size is not compataible with this layer")
+	}
+
+	// every non-zero input adds its row of weights to the output, scaled
+	resize(output, rows, layer.OutSize)
+	var values []float64
+	if layer.Values != nil {
+		values = layer.Values.RawRowView(0)
+	}
+	biases := layer.Biases.RawRowView(0)
+	for r := 0; r < rows; r++ {
+		out_row := output.RawRowView(r)
+		copy(out_row, biases)
+		for i, x := range input.RawRowView(r) {
+			if x == 0 {
+				continue
+			}
+			start, end := layer.RowStarts[i], layer.RowStarts[i+1]
+			columns := layer.Columns[start:end]
+			for k, v := range values[start:end] {
+				out_row[columns[k]] += x * v
+			}
+		}
+	}
+	return nil
+}
+
+func (layer *SparseDenseLayer) BackwardRows(output_grad *mat.Dense, rate float64) *mat.Dense {
+	/*
+		The same gradients as DenseLayer, restricted to the stored weights:
+
+		dL/dw_ij = sum over the batch of x_i * dL/dy_j
+		dL/dx_i = sum_j dL/dy_j * w_ij
+	*/
+	rows, _ := output_grad.Dims()
+	// the gradient is owned by the layer, like the output of Forward
+	input_grad := &layer.input_grad
+	resize(input_grad, rows, layer.InSize)
+	input_grad.Zero()
+	if layer.Values != nil {
+		values := layer.Values.RawRowView(0)
+		if len(layer.weights_grad) != len(values) {
+			layer.weights_grad = make([]float64, len(values))
+		}
+		weights_grad := layer.weights_grad
+		clear(weights_grad)
+		for r := 0; r < rows; r++ {
+			grad_row := output_grad.RawRowView(r)
+			in_row, in_grad_row := layer.Input.RawRowView(r), input_grad.RawRowView(r)
+			for i := 0; i < layer.InSize; i++ {
+				for k := layer.RowStarts[i]; k < layer.RowStarts[i+1]; k++ {
+					g := grad_row[layer.Columns[k]]
+					weights_grad[k] += in_row[i] * g
+					in_grad_row[i] += g * values[k]
+				}
+			}
+		}
+		floats.AddScaled(values, -rate, weights_grad)
+	}
+
+	// biases -= rate * output_grad, summed over the batch
+	subtractRows(layer.Biases, rate, output_grad)
+
+	return input_grad
+}
+
+type sparseDenseConfig struct {
+	InSize    int   `json:"insize"`
+	OutSize   int   `json:"outsize"`
+	RowStarts []int `json:"row_starts"`
+	Columns   []int `json:"columns"`
+}
+
+// MarshalConfig stores where the non-zero weights are, their values are the
+// "values" parameter.
+func (layer *SparseDenseLayer) MarshalConfig() ([]byte, error) {
+	return json.Marshal(sparseDenseConfig{
+		InSize:    layer.InSize,
+		OutSize:   layer.OutSize,
+		RowStarts: layer.RowStarts,
+		Columns:   layer.Columns,
+	})
+}
+
+// size checks c and returns the number of parameter elements it allocates.
+func (c sparseDenseConfig) size() (int, error) {
+	if c.InSize <= 0 || c.OutSize <= 0 {
+		return 0, errors.New("sparse dense layer sizes must be positive")
+	}
+	if err := CheckSize(1, c.OutSize); err != nil {
+		return 0, err
+	}
+	if len(c.RowStarts) != c.InSize+1 || c.RowStarts[0] != 0 || c.RowStarts[c.InSize] != len(c.Columns) {
+		return 0, fmt.Errorf("sparse dense layer row starts don't match %d inputs and %d weights", c.InSize, len(c.Columns))
+	}
+	for i := 0; i < c.InSize; i++ {
+		if c.RowStarts[i] > c.RowStarts[i+1] {
+			return 0, errors.New("sparse dense layer row starts aren't sorted")
+		}
+	}
+	for _, column := range c.Columns {
+		if column < 0 || column >= c.OutSize {
+			return 0, fmt.Errorf("sparse dense layer column %d out of range", column)
+		}
+	}
+	return len(c.Columns) + c.OutSize, nil
+}
+
+func (layer *SparseDenseLayer) ParamSize(config []byte) (int, error) {
+	var c sparseDenseConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return 0, err
+	}
+	return c.size()
+}
+
+func (layer *SparseDenseLayer) UnmarshalConfig(config []byte) error {
+	var c sparseDenseConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if _, err := c.size(); err != nil {
+		return err
+	}
+
+	layer.InSize, layer.OutSize = c.InSize, c.OutSize
+	layer.RowStarts, layer.Columns = c.RowStarts, c.Columns
+	layer.Values = nil
+	if len(c.Columns) > 0 {
+		layer.Values = mat.NewDense(1, len(c.Columns), nil)
+	}
+	layer.Biases = mat.NewDense(1, c.OutSize, nil)
+	layer.Input = mat.NewDense(1, c.InSize, nil)
+	return nil
+}
+
+func (layer *SparseDenseLayer) Params() []Param {
+	params := []Param{{Name: "biases", Value: layer.Biases}}
+	if layer.Values != nil {
+		params = append(params, Param{Name: "values", Value: layer.Values})
+	}
+	return params
+}
+
+func (layer *SparseDenseLayer) InputSize() int  { return layer.InSize }
+func (layer *SparseDenseLayer) OutputSize() int { return layer.OutSize }
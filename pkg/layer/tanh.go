@@ -1,33 +1,83 @@
 package layer
 
 import (
+	"encoding/json"
+	"errors"
 	"math"
 
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
 	"gonum.org/v1/gonum/mat"
 )
 
 type TanhLayer struct {
+	// Input is set by Forward. Backward computes tanh(Input) again when
+	// it's set by hand, unless it is set between a Forward and its Backward.
 	Input *mat.Dense
+	Size  int // 0 if the layer was built without a size
+
+	// reused by every Forward and Backward, sized for the last batch
+	output     mat.Dense
+	input_grad mat.Dense
+	// output is tanh(Input), from a Forward no Backward has used yet
+	forwarded bool
 }
 
 func Tanh(insize int) *TanhLayer {
 	var layer TanhLayer
 	layer.Input = mat.NewDense(1, insize, nil)
+	layer.Size = insize
 
 	return &layer
 }
 
-func (layer *TanhLayer) Forward(input *mat.Dense) (*mat.Dense, error) {
+// Forward returns a tensor owned by the layer, which the next Forward
+// overwrites, see ForwardRows.
+func (layer *TanhLayer) Forward(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return forwardRows(layer, input)
+}
+
+func (layer *TanhLayer) Backward(out_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	return backwardRows(layer, out_grad, rate)
+}
+
+func (layer *TanhLayer) Infer(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return inferRows(layer, input)
+}
+
+// ForwardRows returns a matrix owned by the layer, which the next Forward
+// overwrites. Copy it to keep it longer.
+func (layer *TanhLayer) ForwardRows(input *mat.Dense) (*mat.Dense, error) {
 	layer.Input = input
+	layer.forwarded = false
+	if err := layer.InferInto(&layer.output, input); err != nil {
+		return nil, err
+	}
+	layer.forwarded = true
+	return &layer.output, nil
+}
 
+func (layer *TanhLayer) InferRows(input *mat.Dense) (*mat.Dense, error) {
 	var result mat.Dense
+	if err := layer.InferInto(&result, input); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
 
+func (layer *TanhLayer) InferInto(result, input *mat.Dense) error {
 	// result = tanh(input) ; for element in input
-	result.Apply(func(i, j int, v float64) float64 { return math.Tanh(v) }, input)
-	return &result, nil
+	rows, cols := input.Dims()
+	resize(result, rows, cols)
+	for i := 0; i < rows; i++ {
+		result_row := result.RawRowView(i)
+		for j, v := range input.RawRowView(i) {
+			result_row[j] = math.Tanh(v)
+		}
+	}
+	return nil
 }
 
-func (layer *TanhLayer) Backward(output_grad *mat.Dense, rate float64) *mat.Dense {
+func (layer *TanhLayer) BackwardRows(output_grad *mat.Dense, rate float64) *mat.Dense {
 	/*
 		dL/dinput = dL/dy * dy/dinput
 
@@ -35,13 +85,61 @@ func (layer *TanhLayer) Backward(output_grad *mat.Dense, rate float64) *mat.Dens
 		so, dy/dinput (y') = sech^2(input) = 1 - tanh^2(input)
 
 		dL/dinput = dL/dy * (1 - tanh^2(input))
+
+		tanh(input) is the output of Forward, so it isn't evaluated again.
+		It only is when there was no Forward since the last Backward, as
+		for an Input set by hand.
 	*/
+	if !layer.forwarded {
+		layer.InferInto(&layer.output, layer.Input)
+	}
+	layer.forwarded = false
 
-	var y_prime mat.Dense
-	y_prime.Apply(func(i, j int, v float64) float64 { return 1.0 - math.Tanh(v)*math.Tanh(v) }, layer.Input)
+	rows, cols := layer.output.Dims()
+	if grad_r, grad_c := output_grad.Dims(); grad_r != rows || grad_c != cols {
+		panic(mat.ErrShape)
+	}
 
-	var result mat.Dense
-	result.MulElem(output_grad, &y_prime)
+	// the gradient is owned by the layer, like the output of Forward
+	result := &layer.input_grad
+	resize(result, rows, cols)
+	for i := 0; i < rows; i++ {
+		grad_row, result_row := output_grad.RawRowView(i), result.RawRowView(i)
+		for j, y := range layer.output.RawRowView(i) {
+			result_row[j] = grad_row[j] * (1 - y*y)
+		}
+	}
+
+	return result
+}
+
+type tanhConfig struct {
+	Size int `json:"size"`
+}
+
+func (layer *TanhLayer) MarshalConfig() ([]byte, error) {
+	if layer.Size == 0 {
+		return nil, nil
+	}
+	return json.Marshal(tanhConfig{Size: layer.Size})
+}
 
-	return &result
+func (layer *TanhLayer) UnmarshalConfig(config []byte) error {
+	if len(config) == 0 {
+		return nil
+	}
+	var c tanhConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if c.Size < 0 {
+		return errors.New("tanh layer size can't be negative")
+	}
+	layer.Size = c.Size
+	return nil
 }
+
+func (layer *TanhLayer) Params() []Param { return nil }
+
+func (layer *TanhLayer) InputSize() int  { return layer.Size }
+func (layer *TanhLayer) OutputSize() int { return layer.Size }
@@ -5,16 +5,22 @@ import (
 )
 
 func MSE(true_val *mat.Dense, pred_val *mat.Dense) float64 {
-	var result mat.Dense
-	result.Sub(true_val, pred_val)
-
-	result.Apply(func(i, j int, v float64) float64 { return v * v }, &result)
+	r, c := true_val.Dims()
+	if pred_r, pred_c := pred_val.Dims(); pred_r != r || pred_c != c {
+		panic(mat.ErrShape)
+	}
 
-	r, c := result.Dims()
-	size := float64(r * c)
+	// summed row by row, without a matrix for the differences
+	sum := float64(0)
+	for i := 0; i < r; i++ {
+		pred_row := pred_val.RawRowView(i)
+		for j, v := range true_val.RawRowView(i) {
+			sum += (v - pred_row[j]) * (v - pred_row[j])
+		}
+	}
 
 	// size can't be zero, as 0 lenght matrix isn't allowed in gonum
-	return mat.Sum(&result) / size
+	return sum / float64(r*c)
 }
 func MSE_Prime(true_val *mat.Dense, pred_val *mat.Dense) *mat.Dense {
 	/*
@@ -0,0 +1,247 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/mat"
+)
+
+/*
+	Binary model files keep the JSON model file as a layer table and move
+	the weights out of it into raw tensors:
+
+	offset  size  field
+	0       4     magic "GONN"
+	4       4     container version (1)
+	8       4     tensor type: 1 = float64, 2 = float32
+	12      4     reserved, 0
+	16      8     length of the layer table
+	24      8     offset of the tensor section
+	32      8     length of the tensor section
+	40      32    sha256 of the tensor section
+	72      ...   layer table: a compact JSON model file (with its own
+	              checksum) whose params are {"offset", "rows", "cols"}
+	              references into the tensor section, with "dtype": "int8"
+	              for int8 parameters, stored a byte each whatever the
+	              tensor type
+	...           zero padding
+	...           tensor section: row major, little endian values, every
+	              tensor starts at a multiple of 64 bytes from the start
+	              of the file
+
+	All header integers are little endian.
+*/
+
+const (
+	binaryMagic      = "GONN"
+	binaryVersion    = 1
+	binaryHeaderSize = 72
+	tensorAlignment  = 64
+
+	tensorFloat64 = 1
+	tensorFloat32 = 2
+)
+
+type tensorRef struct {
+	Offset int    `json:"offset"`
+	Rows   int    `json:"rows"`
+	Cols   int    `json:"cols"`
+	DType  string `json:"dtype,omitempty"` // "int8", or the tensor type of the file
+}
+
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+func isBinary(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(binaryMagic))
+}
+
+func align(n int) int {
+	return (n + tensorAlignment - 1) / tensorAlignment * tensorAlignment
+}
+
+func marshalBinary(m model, use_float32 bool) ([]byte, error) {
+	tensor_type, tensor_size := uint32(tensorFloat64), 8
+	if use_float32 {
+		tensor_type, tensor_size = tensorFloat32, 4
+	}
+
+	tensors := make([]byte, 0)
+	encode := func(param layer.Param) (json.RawMessage, error) {
+		tensors = append(tensors, make([]byte, align(len(tensors))-len(tensors))...)
+
+		if param.Int8 != nil {
+			ref := tensorRef{Offset: len(tensors), Rows: param.Int8.Rows, Cols: param.Int8.Cols, DType: "int8"}
+			tensors = append(tensors, int8Bytes(param.Int8.Data)...)
+			return json.Marshal(ref)
+		}
+
+		value := param.Value
+		rows, cols := value.Dims()
+		ref := tensorRef{Offset: len(tensors), Rows: rows, Cols: cols}
+		tensors = append(tensors, make([]byte, rows*cols*tensor_size)...)
+		raw_tensor := tensors[ref.Offset:]
+		for i := 0; i < rows; i++ {
+			for j := 0; j < cols; j++ {
+				k := (i*cols + j) * tensor_size
+				if use_float32 {
+					binary.LittleEndian.PutUint32(raw_tensor[k:], math.Float32bits(float32(value.At(i, j))))
+				} else {
+					binary.LittleEndian.PutUint64(raw_tensor[k:], math.Float64bits(value.At(i, j)))
+				}
+			}
+		}
+		return json.Marshal(ref)
+	}
+
+	has_int8 := false
+	payload, err := m.describe(int8Tracker(encode, &has_int8))
+	if err != nil {
+		return nil, err
+	}
+	file, err := newModelFile(m.kind(), payload, has_int8)
+	if err != nil {
+		return nil, err
+	}
+	table, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tensor_offset := align(binaryHeaderSize + len(table))
+	data := make([]byte, tensor_offset, tensor_offset+len(tensors))
+	copy(data[0:4], binaryMagic)
+	binary.LittleEndian.PutUint32(data[4:], binaryVersion)
+	binary.LittleEndian.PutUint32(data[8:], tensor_type)
+	binary.LittleEndian.PutUint64(data[16:], uint64(len(table)))
+	binary.LittleEndian.PutUint64(data[24:], uint64(tensor_offset))
+	binary.LittleEndian.PutUint64(data[32:], uint64(len(tensors)))
+	tensor_sum := sha256.Sum256(tensors)
+	copy(data[40:72], tensor_sum[:])
+	copy(data[binaryHeaderSize:], table)
+
+	return append(data, tensors...), nil
+}
+
+func unmarshalBinary(m model, data []byte, options readOptions) error {
+	if len(data) < binaryHeaderSize {
+		return errors.New("binary model file is truncated")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != binaryVersion {
+		return fmt.Errorf("unsupported binary model container version %d", version)
+	}
+
+	tensor_type := binary.LittleEndian.Uint32(data[8:])
+	tensor_size := 0
+	switch tensor_type {
+	case tensorFloat64:
+		tensor_size = 8
+	case tensorFloat32:
+		tensor_size = 4
+	default:
+		return fmt.Errorf("unknown tensor type %d in binary model file", tensor_type)
+	}
+
+	size := uint64(len(data))
+	table_len := binary.LittleEndian.Uint64(data[16:])
+	tensor_offset := binary.LittleEndian.Uint64(data[24:])
+	tensor_len := binary.LittleEndian.Uint64(data[32:])
+	if table_len > size-binaryHeaderSize || tensor_offset < binaryHeaderSize+table_len ||
+		tensor_offset > size || tensor_len != size-tensor_offset {
+		return errors.New("binary model file is truncated or corrupted")
+	}
+	tensors := data[tensor_offset:]
+
+	if options.verify_tensors {
+		tensor_sum := sha256.Sum256(tensors)
+		if !bytes.Equal(tensor_sum[:], data[40:72]) {
+			return errors.New("model file checksum mismatch: the file is corrupted or was modified")
+		}
+	}
+
+	payload := m.newPayload()
+	if err := decodeModel(data[binaryHeaderSize:binaryHeaderSize+table_len], m.kind(), payload, options.verify_model); err != nil {
+		return err
+	}
+
+	decode := func(raw json.RawMessage, param layer.Param) error {
+		var ref tensorRef
+		if err := json.Unmarshal(raw, &ref); err != nil {
+			return err
+		}
+		rows, cols := paramDims(param)
+		if ref.Rows != rows || ref.Cols != cols {
+			return errors.New("shape doesn't match the layer config")
+		}
+		element_size := tensor_size
+		switch {
+		case ref.DType == "int8" && param.Int8 == nil:
+			return errors.New("int8 tensor for a float parameter")
+		case ref.DType == "int8":
+			element_size = 1
+		case ref.DType != "":
+			return fmt.Errorf("unknown tensor dtype %q", ref.DType)
+		}
+		length := rows * cols * element_size
+		if ref.Offset < 0 || ref.Offset > len(tensors)-length {
+			return errors.New("tensor is outside of the tensor section")
+		}
+		raw_tensor := tensors[ref.Offset : ref.Offset+length]
+
+		if ref.DType == "int8" {
+			for i, b := range raw_tensor {
+				param.Int8.Data[i] = int8(b)
+			}
+			return nil
+		}
+
+		/*
+			float64 tensors already have the layout gonum uses, so on little
+			endian machines the matrix can use the file's bytes directly.
+			That's what makes memory-mapped models load instantly.
+		*/
+		if param.Int8 == nil && options.alias && tensor_type == tensorFloat64 && nativeLittleEndian &&
+			uintptr(unsafe.Pointer(&raw_tensor[0]))%unsafe.Alignof(float64(0)) == 0 {
+			values := unsafe.Slice((*float64)(unsafe.Pointer(&raw_tensor[0])), rows*cols)
+			param.Value.SetRawMatrix(blas64.General{Rows: rows, Cols: cols, Stride: cols, Data: values})
+			if options.aliased != nil {
+				*options.aliased = append(*options.aliased, param.Value)
+			}
+			return nil
+		}
+
+		// int8 parameters of files before format 3 are float tensors
+		dst := param.Value
+		if param.Int8 != nil {
+			dst = mat.NewDense(rows, cols, nil)
+		}
+		for i := 0; i < rows; i++ {
+			for j := 0; j < cols; j++ {
+				k := (i*cols + j) * tensor_size
+				if tensor_type == tensorFloat32 {
+					dst.Set(i, j, float64(math.Float32frombits(binary.LittleEndian.Uint32(raw_tensor[k:]))))
+				} else {
+					dst.Set(i, j, math.Float64frombits(binary.LittleEndian.Uint64(raw_tensor[k:])))
+				}
+			}
+		}
+		if param.Int8 != nil {
+			return setParam(param, dst)
+		}
+		return nil
+	}
+
+	// every parameter takes at least a byte of the tensor section
+	return m.restore(payload, decode, len(tensors))
+}
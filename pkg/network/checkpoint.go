@@ -0,0 +1,205 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// TrainState is the progress of a training run: everything besides the
+// weights that Resume needs to continue exactly where Train stopped.
+type TrainState struct {
+	Epoch  int     // epochs completed
+	Epochs int     // epochs the run was started with
+	Rate   float64 // learning rate the run was started with, see Schedule
+
+	// Source of randomness for training, such as shuffling samples.
+	RNG *rand.PCG
+}
+
+// rate returns the learning rate of the current epoch.
+func (network *Network) rate() float64 {
+	if network.Schedule == nil {
+		return network.State.Rate
+	}
+	return network.Schedule(network.State.Epoch, network.State.Rate)
+}
+
+/*
+	A checkpoint is a model file of kind "checkpoint" whose model holds the
+	network and its TrainState:
+
+	{"Network": {"Layers": [...], "Loss": "MSE"}, "State": {...}}
+
+	It goes through the same encoders as Save, so weights are stored
+	exactly and the file is checksummed.
+*/
+
+type JSONTrainState struct {
+	Epoch  int
+	Epochs int
+	Rate   float64
+	RNG    []byte `json:",omitempty"` // rand.PCG.MarshalBinary
+}
+
+type JSONCheckpoint struct {
+	Network JSONNewtork
+	State   JSONTrainState
+}
+
+type checkpoint struct {
+	network *Network
+}
+
+func (c checkpoint) kind() string { return "checkpoint" }
+
+func (c checkpoint) describe(encode paramEncoder) (any, error) {
+	payload, err := c.network.describe(encode)
+	if err != nil {
+		return nil, err
+	}
+
+	state := c.network.State
+	json_state := JSONTrainState{Epoch: state.Epoch, Epochs: state.Epochs, Rate: state.Rate}
+	if state.RNG != nil {
+		if json_state.RNG, err = state.RNG.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+	return JSONCheckpoint{Network: payload.(JSONNewtork), State: json_state}, nil
+}
+
+func (c checkpoint) newPayload() any       { return &JSONCheckpoint{} }
+func (c checkpoint) mapping() *fileMapping { return &c.network.mapped }
+
+func (c checkpoint) restore(payload any, decode paramDecoder, capacity int) error {
+	json_checkpoint := payload.(*JSONCheckpoint)
+	json_state := json_checkpoint.State
+	if json_state.Epoch < 0 || json_state.Epoch > json_state.Epochs {
+		return fmt.Errorf("checkpoint is at epoch %d of %d", json_state.Epoch, json_state.Epochs)
+	}
+
+	state := TrainState{Epoch: json_state.Epoch, Epochs: json_state.Epochs, Rate: json_state.Rate}
+	if json_state.RNG != nil {
+		state.RNG = &rand.PCG{}
+		if err := state.RNG.UnmarshalBinary(json_state.RNG); err != nil {
+			return fmt.Errorf("checkpoint random state: %w", err)
+		}
+	}
+
+	if err := c.network.restore(&json_checkpoint.Network, decode, capacity); err != nil {
+		return err
+	}
+	c.network.State = state
+	return nil
+}
+
+// SaveCheckpoint saves the network together with its TrainState. The file
+// is written next to fpath first and then renamed, so a crash while saving
+// never leaves a half written checkpoint behind.
+func (network *Network) SaveCheckpoint(fpath string, opts ...SaveOption) error {
+	tmp := fpath + ".tmp"
+	if err := saveModel(checkpoint{network}, tmp, opts); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fpath)
+}
+
+// LoadCheckpoint restores the layers, loss and TrainState saved by
+// SaveCheckpoint. Call Resume to continue training.
+func (network *Network) LoadCheckpoint(fpath string, opts ...LoadOption) error {
+	return loadModel(checkpoint{network}, fpath, opts)
+}
+
+// Checkpointer saves checkpoints into Dir while the network trains, so long
+// runs survive the process being restarted:
+//
+//	net.Checkpoints = &network.Checkpointer{Dir: "checkpoints", Every: 10, KeepLast: 3}
+//	if latest, err := net.Checkpoints.Latest(); err == nil {
+//		err = net.LoadCheckpoint(latest)
+//		...
+//		net.Resume(inputs, outputs)
+//	} else {
+//		net.Train(inputs, outputs, 1000, 0.01)
+//	}
+type Checkpointer struct {
+	Dir      string
+	Every    int // epochs between checkpoints, every epoch if 0
+	KeepLast int // older checkpoints are deleted, all are kept if 0
+
+	// OnCancel also saves a checkpoint when the context passed to
+	// TrainContext or ResumeContext is done.
+	OnCancel bool
+}
+
+const checkpointPattern = "checkpoint-*.json"
+
+func (c *Checkpointer) path(epoch int) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("checkpoint-%08d.json", epoch))
+}
+
+// list returns the checkpoints in Dir, oldest first.
+func (c *Checkpointer) list() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(c.Dir, checkpointPattern))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// Latest returns the path of the newest checkpoint in Dir, or an error
+// matching fs.ErrNotExist if there is none.
+func (c *Checkpointer) Latest() (string, error) {
+	paths, err := c.list()
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no checkpoint in %s: %w", c.Dir, fs.ErrNotExist)
+	}
+	return paths[len(paths)-1], nil
+}
+
+// epochDone is called by Resume after every epoch. The last epoch of a run
+// is always saved.
+func (c *Checkpointer) epochDone(network *Network) error {
+	state := network.State
+	every := max(c.Every, 1)
+	if state.Epoch%every != 0 && state.Epoch != state.Epochs {
+		return nil
+	}
+
+	return c.save(network)
+}
+
+func (c *Checkpointer) save(network *Network) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	if err := network.SaveCheckpoint(c.path(network.State.Epoch)); err != nil {
+		return err
+	}
+	return c.rotate()
+}
+
+func (c *Checkpointer) rotate() error {
+	if c.KeepLast <= 0 {
+		return nil
+	}
+	paths, err := c.list()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(paths) > c.KeepLast {
+		errs = append(errs, os.Remove(paths[0]))
+		paths = paths[1:]
+	}
+	return errors.Join(errs...)
+}
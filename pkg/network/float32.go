@@ -0,0 +1,61 @@
+package network
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kapilpokhrel/goNN/pkg/f32"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"gonum.org/v1/gonum/mat"
+)
+
+// layers32 returns the float32 copy of the layers, converting them if
+// there is none yet.
+func (network *Network) layers32() ([]layer.Layer32, error) {
+	network.float32_mu.Lock()
+	defer network.float32_mu.Unlock()
+	if network.float32_layers != nil {
+		return network.float32_layers, nil
+	}
+
+	converted := make([]layer.Layer32, len(network.Layers))
+	for i, current_layer := range network.Layers {
+		var err error
+		if converted[i], err = layer.To32(current_layer); err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+	network.float32_layers = converted
+	return converted, nil
+}
+
+// ResetFloat32 drops the float32 copy of the weights, the next Predict with
+// InferFloat32 converts the layers again.
+func (network *Network) ResetFloat32() {
+	network.float32_mu.Lock()
+	network.float32_layers = nil
+	network.float32_mu.Unlock()
+}
+
+// infer32 is infer in float32. Preprocessing still runs in float64.
+func (network *Network) infer32(ctx context.Context, input *mat.Dense) (*mat.Dense, error) {
+	layers, err := network.layers32()
+	if err != nil {
+		return nil, err
+	}
+	preprocessed, err := network.preprocess(input)
+	if err != nil {
+		return nil, err
+	}
+
+	result := f32.FromDense(preprocessed)
+	for _, current_layer := range layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if result, err = current_layer.Infer32(result); err != nil {
+			return nil, err
+		}
+	}
+	return result.Dense(), nil
+}
@@ -0,0 +1,957 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"runtime/debug"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/preprocess"
+	"gonum.org/v1/gonum/mat"
+)
+
+const FormatVersion = 3 // latest model file format this package can read
+
+// Version is the version of the goNN module the program was built with,
+// written into every saved model. It comes from the build info, and is
+// "(devel)" when goNN isn't a versioned dependency, e.g. in its own tests.
+var Version = moduleVersion()
+
+func moduleVersion() string {
+	const path = "github.com/kapilpokhrel/goNN"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	module := &info.Main
+	if module.Path != path {
+		module = nil
+		for _, dep := range info.Deps {
+			if dep.Path == path {
+				module = dep
+			}
+		}
+	}
+	if module != nil && module.Replace != nil {
+		module = module.Replace
+	}
+	if module == nil || module.Version == "" {
+		return "(devel)"
+	}
+	return module.Version
+}
+
+/*
+	Format history:
+
+	0: files without format_version, from before the envelope, which only
+	   hold networks of Dense and Tanh layers
+	1: networks, graphs and checkpoints
+	2: networks with a preprocessing pipeline
+	3: int8 parameters, such as QuantizedDense weights, stored a byte each
+	   instead of as float64
+
+	Files are written with the oldest format that can hold the model, so
+	models without preprocessing still load in older goNN versions.
+*/
+
+/*
+	A model file is a small envelope around the model itself:
+
+	{
+		"format_version": 1,
+		"gonn_version": "v0.2.0",
+		"kind": "network",
+		"checksum": "sha256:...",
+		"model": {"Layers": [...], "Loss": "MSE"}
+	}
+
+	The checksum covers the compact JSON encoding of "model", so a file that
+	was cut short or edited by hand is rejected instead of silently loading
+	different weights.
+*/
+
+type JSONModelFile struct {
+	FormatVersion int             `json:"format_version"`
+	GoNNVersion   string          `json:"gonn_version"`
+	Kind          string          `json:"kind"`
+	Checksum      string          `json:"checksum"`
+	Model         json.RawMessage `json:"model"`
+}
+
+type JSONLayer struct {
+	Type        string                     `json:"type"`
+	Config      json.RawMessage            `json:"config,omitempty"`
+	InputShape  []int                      `json:"input_shape,omitempty"`
+	OutputShape []int                      `json:"output_shape,omitempty"`
+	Params      map[string]json.RawMessage `json:"params,omitempty"` // see paramEncoder
+}
+
+type JSONNewtork struct {
+	Layers     []JSONLayer
+	Loss       string
+	Preprocess []JSONLayer `json:",omitempty"`
+}
+
+type JSONNode struct {
+	Layer  JSONLayer
+	Inputs []int
+}
+
+type JSONGraph struct {
+	Nodes   []JSONNode
+	Inputs  []int
+	Outputs []int
+	Loss    string
+}
+
+func checksum(model []byte) string {
+	sum := sha256.Sum256(model)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newModelFile wraps model, with has_int8 set if its parameters were
+// encoded with int8 ones, see int8Tracker.
+func newModelFile(kind string, model any, has_int8 bool) (JSONModelFile, error) {
+	model_json, err := json.Marshal(model)
+	if err != nil {
+		return JSONModelFile{}, err
+	}
+
+	version := requiredVersion(model)
+	if has_int8 {
+		version = 3
+	}
+	return JSONModelFile{
+		FormatVersion: version,
+		GoNNVersion:   Version,
+		Kind:          kind,
+		Checksum:      checksum(model_json),
+		Model:         model_json,
+	}, nil
+}
+
+// requiredVersion is the oldest format version that can hold model.
+func requiredVersion(model any) int {
+	switch payload := model.(type) {
+	case JSONNewtork:
+		if len(payload.Preprocess) > 0 {
+			return 2
+		}
+	case JSONCheckpoint:
+		return requiredVersion(payload.Network)
+	}
+	return 1
+}
+
+func decodeModel(data []byte, kind string, model any, verify bool) error {
+	var file JSONModelFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("model file is truncated or not valid JSON: %w", err)
+	}
+
+	switch {
+	case file.FormatVersion == 0:
+		return decodeLegacy(data, kind, model)
+	case file.FormatVersion > FormatVersion:
+		return fmt.Errorf(
+			"model file format %d was written by goNN %s, this version only reads up to format %d",
+			file.FormatVersion, file.GoNNVersion, FormatVersion,
+		)
+	case file.Kind != kind:
+		return fmt.Errorf("model file contains a %q, not a %q", file.Kind, kind)
+	}
+
+	// MarshalIndent re-indents the embedded model, compacting it again
+	// gives back the exact bytes the checksum was computed on.
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, file.Model); err != nil {
+		return fmt.Errorf("model file is corrupted: %w", err)
+	}
+	if verify && checksum(compact.Bytes()) != file.Checksum {
+		return errors.New("model file checksum mismatch: the file is corrupted or was modified")
+	}
+
+	return json.Unmarshal(compact.Bytes(), model)
+}
+
+// jsonLegacyNetwork is the file goNN wrote before model files had an
+// envelope: Dense layers with base64 weights and biases of gonum's
+// MarshalBinary, Tanh layers and the loss.
+type jsonLegacyNetwork struct {
+	Layers []map[string]string
+	Loss   string
+}
+
+// decodeLegacy reads a network file without format_version into model,
+// giving its Dense layers the config and shapes their weights imply.
+func decodeLegacy(data []byte, kind string, model any) error {
+	var legacy jsonLegacyNetwork
+	if err := json.Unmarshal(data, &legacy); err != nil || legacy.Layers == nil || kind != "network" {
+		return errors.New("not a goNN model file: missing format_version")
+	}
+
+	json_network := model.(*JSONNewtork)
+	json_network.Layers = make([]JSONLayer, len(legacy.Layers))
+	for i, legacy_layer := range legacy.Layers {
+		json_layer := JSONLayer{Type: legacy_layer["type"]}
+		switch json_layer.Type {
+		case "Dense":
+			weights_bin, err := base64.StdEncoding.DecodeString(legacy_layer["weights"])
+			if err != nil {
+				return fmt.Errorf("layer %d: Dense weights: %w", i, err)
+			}
+			var weights mat.Dense
+			if err := weights.UnmarshalBinary(weights_bin); err != nil {
+				return fmt.Errorf("layer %d: Dense weights: %w", i, err)
+			}
+			insize, outsize := weights.Dims()
+			json_layer.Config = json.RawMessage(fmt.Sprintf(`{"insize":%d,"outsize":%d}`, insize, outsize))
+			json_layer.InputShape, json_layer.OutputShape = shape(insize), shape(outsize)
+			json_layer.Params = make(map[string]json.RawMessage, 2)
+			for _, name := range []string{"weights", "biases"} {
+				if json_layer.Params[name], err = json.Marshal(legacy_layer[name]); err != nil {
+					return err
+				}
+			}
+		case "Tanh":
+		default:
+			return fmt.Errorf("layer %d: unknown layer type %q", i, json_layer.Type)
+		}
+		json_network.Layers[i] = json_layer
+	}
+	json_network.Loss = legacy.Loss
+	return nil
+}
+
+/*
+	Weights are stored differently depending on the container. A
+	paramEncoder turns a parameter into the JSON value stored in
+	JSONLayer.Params and a paramDecoder fills the layer's parameter back
+	from that value:
+
+	- JSON files store a base64 string of gonum's MarshalBinary, or of the
+	  bytes of int8 parameters
+	- binary files store a reference into their tensor section
+
+	Files before format 3 stored int8 parameters as float64 matrices, they
+	are converted when read.
+*/
+
+type paramEncoder func(param layer.Param) (json.RawMessage, error)
+type paramDecoder func(raw json.RawMessage, dst layer.Param) error
+
+// int8Tracker wraps encode to record whether it was given int8
+// parameters, which need format 3.
+func int8Tracker(encode paramEncoder, has_int8 *bool) paramEncoder {
+	return func(param layer.Param) (json.RawMessage, error) {
+		if param.Int8 != nil {
+			*has_int8 = true
+		}
+		return encode(param)
+	}
+}
+
+// setParam copies value into dst. int8 parameters only accept integers
+// that fit.
+func setParam(dst layer.Param, value mat.Matrix) error {
+	rows, cols := paramDims(dst)
+	if value_rows, value_cols := value.Dims(); value_rows != rows || value_cols != cols {
+		return errors.New("shape doesn't match the layer config")
+	}
+	if dst.Int8 != nil {
+		return dst.Int8.CopyFrom(value)
+	}
+	dst.Value.Copy(value)
+	return nil
+}
+
+func encodeBase64Param(param layer.Param) (json.RawMessage, error) {
+	if param.Int8 != nil {
+		return json.Marshal(base64.StdEncoding.EncodeToString(int8Bytes(param.Int8.Data)))
+	}
+	param_bin, err := param.Value.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(param_bin))
+}
+
+func decodeBase64Param(raw json.RawMessage, dst layer.Param) error {
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		return err
+	}
+	param_bin, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+
+	// a float64 matrix is never a byte per element, with its header
+	if dst.Int8 != nil && len(param_bin) == len(dst.Int8.Data) {
+		for i, b := range param_bin {
+			dst.Int8.Data[i] = int8(b)
+		}
+		return nil
+	}
+	var value mat.Dense
+	if err := value.UnmarshalBinary(param_bin); err != nil {
+		return err
+	}
+	return setParam(dst, &value)
+}
+
+// int8Bytes returns the bytes of values, in two's complement.
+func int8Bytes(values []int8) []byte {
+	result := make([]byte, len(values))
+	for i, v := range values {
+		result[i] = byte(v)
+	}
+	return result
+}
+
+// JSONWeights is the readable form of a weight matrix written with
+// ReadableWeights, one JSON array per row.
+type JSONWeights struct {
+	Shape []int       `json:"shape"`
+	Data  [][]float64 `json:"data"`
+}
+
+func encodeReadableParam(param layer.Param) (json.RawMessage, error) {
+	value := param.Value
+	if param.Int8 != nil {
+		value = param.Int8.Dense()
+	}
+	rows, cols := value.Dims()
+	weights := JSONWeights{Shape: []int{rows, cols}, Data: make([][]float64, rows)}
+	for i := range weights.Data {
+		weights.Data[i] = mat.Row(nil, i, value)
+	}
+	return json.Marshal(weights)
+}
+
+func decodeReadableParam(raw json.RawMessage, dst layer.Param) error {
+	var weights JSONWeights
+	if err := json.Unmarshal(raw, &weights); err != nil {
+		return err
+	}
+
+	rows, cols := paramDims(dst)
+	if !equalShape(weights.Shape, []int{rows, cols}) || len(weights.Data) != rows {
+		return errors.New("shape doesn't match the layer config")
+	}
+	value := mat.NewDense(rows, cols, nil)
+	for i, row := range weights.Data {
+		if len(row) != cols {
+			return fmt.Errorf("row %d has %d values, expected %d", i, len(row), cols)
+		}
+		value.SetRow(i, row)
+	}
+	return setParam(dst, value)
+}
+
+// paramDims returns the shape of param.
+func paramDims(param layer.Param) (int, int) {
+	if param.Int8 != nil {
+		return param.Int8.Dims()
+	}
+	return param.Value.Dims()
+}
+
+// decodeJSONParam accepts both ways JSON files can store weights.
+func decodeJSONParam(raw json.RawMessage, dst layer.Param) error {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		return decodeReadableParam(raw, dst)
+	}
+	return decodeBase64Param(raw, dst)
+}
+
+func shape(size int) []int {
+	if size == 0 {
+		return nil
+	}
+	return []int{size}
+}
+
+func encodeLayer(current_layer any, encode paramEncoder) (JSONLayer, error) {
+	var json_layer JSONLayer
+
+	spec, err := layer.Describe(current_layer)
+	if err != nil {
+		return json_layer, err
+	}
+	json_layer.Type = spec.Type
+	json_layer.Config = spec.Config
+
+	insize, outsize := layer.Sizes(current_layer)
+	json_layer.InputShape = shape(insize)
+	json_layer.OutputShape = shape(outsize)
+
+	params := current_layer.(layer.Serializable).Params()
+	if len(params) > 0 {
+		json_layer.Params = make(map[string]json.RawMessage, len(params))
+	}
+	for _, param := range params {
+		json_layer.Params[param.Name], err = encode(param)
+		if err != nil {
+			return json_layer, fmt.Errorf("%s %s: %w", spec.Type, param.Name, err)
+		}
+	}
+	return json_layer, nil
+}
+
+// decodeLayer rebuilds a layer from a file that can still hold capacity
+// parameter elements, and takes the layer's parameters off capacity.
+func decodeLayer(json_layer JSONLayer, decode paramDecoder, capacity *int) (layer.Serializable, error) {
+	/*
+		The registered factory gives an empty layer, its config allocates
+		the weight matrices with their final shape and the saved values are
+		copied into them. A saved matrix or shape that disagrees with the
+		config means the file is inconsistent.
+
+		Configs asking for more parameters than the file can hold are
+		rejected before anything is allocated for them.
+	*/
+	spec := layer.Spec{Type: json_layer.Type, Config: json_layer.Config}
+	size, err := layer.ParamSize(spec)
+	if err != nil {
+		return nil, err
+	}
+	if size > *capacity {
+		return nil, fmt.Errorf("%s: config has %d parameters, more than the file holds", json_layer.Type, size)
+	}
+	*capacity -= size
+	new_layer, err := layer.Build(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	insize, outsize := layer.Sizes(new_layer)
+	if !equalShape(json_layer.InputShape, shape(insize)) || !equalShape(json_layer.OutputShape, shape(outsize)) {
+		return nil, fmt.Errorf(
+			"%s: saved shapes %v -> %v don't match the layer config (%v -> %v)",
+			json_layer.Type, json_layer.InputShape, json_layer.OutputShape, shape(insize), shape(outsize),
+		)
+	}
+
+	for _, param := range new_layer.Params() {
+		raw, ok := json_layer.Params[param.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s: missing parameter %q", json_layer.Type, param.Name)
+		}
+		if err := decode(raw, param); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", json_layer.Type, param.Name, err)
+		}
+	}
+	return new_layer, nil
+}
+
+func equalShape(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameDims(a, b mat.Matrix) bool {
+	ar, ac := a.Dims()
+	br, bc := b.Dims()
+	return ar == br && ac == bc
+}
+
+func encodeLoss(loss_func func(*mat.Dense, *mat.Dense) float64) (string, error) {
+	if loss_func == nil {
+		return "", nil
+	}
+	name := lossName(loss_func)
+	if name == "" {
+		return "", fmt.Errorf("loss function %s can't be saved", GetFunctionName(loss_func))
+	}
+	return name, nil
+}
+
+func decodeLoss(name string) (func(*mat.Dense, *mat.Dense) float64, func(*mat.Dense, *mat.Dense) *mat.Dense, error) {
+	if name == "" {
+		return nil, nil, nil
+	}
+	loss_func, loss_prime := lossFuncs(name)
+	if loss_func == nil {
+		return nil, nil, fmt.Errorf("unknown loss function %q", name)
+	}
+	return loss_func, loss_prime, nil
+}
+
+// checkChain makes sure every layer accepts what the previous one outputs.
+func checkChain(layers []layer.Layer) error {
+	width := 0
+	for i, current_layer := range layers {
+		insize, outsize := layer.Sizes(current_layer)
+		if insize != 0 && width != 0 && insize != width {
+			return fmt.Errorf("layer %d expects inputs of size %d, but the previous layer outputs %d", i, insize, width)
+		}
+		width = outsize
+	}
+	return nil
+}
+
+func (network *Network) kind() string { return "network" }
+
+func (network *Network) describe(encode paramEncoder) (any, error) {
+	var json_network JSONNewtork
+
+	json_network.Layers = make([]JSONLayer, len(network.Layers))
+	for i, current_layer := range network.Layers {
+		var err error
+		json_network.Layers[i], err = encodeLayer(current_layer, encode)
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+
+	if network.Preprocess != nil {
+		json_network.Preprocess = make([]JSONLayer, len(network.Preprocess.Steps))
+		for i, step := range network.Preprocess.Steps {
+			var err error
+			json_network.Preprocess[i], err = encodeLayer(step, encode)
+			if err != nil {
+				return nil, fmt.Errorf("preprocess step %d: %w", i, err)
+			}
+		}
+	}
+
+	var err error
+	json_network.Loss, err = encodeLoss(network.Loss)
+	if err != nil {
+		return nil, err
+	}
+
+	return json_network, nil
+}
+
+func (network *Network) newPayload() any       { return &JSONNewtork{} }
+func (network *Network) mapping() *fileMapping { return &network.mapped }
+
+func (network *Network) restore(payload any, decode paramDecoder, capacity int) error {
+	json_network := payload.(*JSONNewtork)
+
+	layers := make([]layer.Layer, len(json_network.Layers))
+	for i, current_layer := range json_network.Layers {
+		decoded, err := decodeLayer(current_layer, decode, &capacity)
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+		var ok bool
+		if layers[i], ok = layer.AsLayer(decoded); !ok {
+			return fmt.Errorf("layer %d: %s can't be used as a network layer", i, current_layer.Type)
+		}
+	}
+	if err := checkChain(layers); err != nil {
+		return err
+	}
+
+	var pipeline *preprocess.Pipeline
+	if len(json_network.Preprocess) > 0 {
+		pipeline = preprocess.NewPipeline()
+		for i, step := range json_network.Preprocess {
+			decoded, err := decodeLayer(step, decode, &capacity)
+			if err != nil {
+				return fmt.Errorf("preprocess step %d: %w", i, err)
+			}
+			transformer, ok := decoded.(preprocess.Transformer)
+			if !ok {
+				return fmt.Errorf("preprocess step %d: %s isn't a transformer", i, step.Type)
+			}
+			pipeline.Steps = append(pipeline.Steps, transformer)
+		}
+
+		if len(layers) > 0 {
+			_, width := layer.Sizes(pipeline.Steps[len(pipeline.Steps)-1])
+			if insize, _ := layer.Sizes(layers[0]); insize != 0 && insize != width {
+				return fmt.Errorf("the first layer expects inputs of size %d, but preprocessing outputs %d", insize, width)
+			}
+		}
+	}
+
+	loss_func, loss_prime, err := decodeLoss(json_network.Loss)
+	if err != nil {
+		return err
+	}
+
+	network.Layers = layers
+	network.Preprocess = pipeline
+	network.ResetFloat32()
+	if loss_func != nil {
+		network.Loss, network.LossPrime = loss_func, loss_prime
+	}
+	return nil
+}
+
+func (graph *Graph) kind() string { return "graph" }
+
+func (graph *Graph) describe(encode paramEncoder) (any, error) {
+	var json_graph JSONGraph
+
+	index := make(map[*Node]int, len(graph.order))
+	for i, node := range graph.order {
+		index[node] = i
+	}
+
+	json_graph.Nodes = make([]JSONNode, len(graph.order))
+	for i, node := range graph.order {
+		var json_node JSONNode
+		var err error
+		switch {
+		case node.Layer != nil:
+			json_node.Layer, err = encodeLayer(node.Layer, encode)
+		case node.Merge != nil:
+			json_node.Layer, err = encodeLayer(node.Merge, encode)
+		default:
+			json_node.Layer = JSONLayer{Type: "Input"}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", i, err)
+		}
+		json_node.Inputs = make([]int, len(node.Inputs))
+		for j, parent := range node.Inputs {
+			json_node.Inputs[j] = index[parent]
+		}
+		json_graph.Nodes[i] = json_node
+	}
+
+	json_graph.Inputs = make([]int, len(graph.Inputs))
+	for i, node := range graph.Inputs {
+		json_graph.Inputs[i] = index[node]
+	}
+	json_graph.Outputs = make([]int, len(graph.Outputs))
+	for i, node := range graph.Outputs {
+		json_graph.Outputs[i] = index[node]
+	}
+
+	var err error
+	json_graph.Loss, err = encodeLoss(graph.Loss)
+	if err != nil {
+		return nil, err
+	}
+
+	return json_graph, nil
+}
+
+func (graph *Graph) newPayload() any       { return &JSONGraph{} }
+func (graph *Graph) mapping() *fileMapping { return &graph.mapped }
+
+func (graph *Graph) restore(payload any, decode paramDecoder, capacity int) error {
+	json_graph := payload.(*JSONGraph)
+
+	// Nodes are saved in topological order, so parents always come first.
+	nodes := make([]*Node, len(json_graph.Nodes))
+	for i, json_node := range json_graph.Nodes {
+		node := &Node{}
+		if json_node.Layer.Type != "Input" {
+			decoded, err := decodeLayer(json_node.Layer, decode, &capacity)
+			if err != nil {
+				return fmt.Errorf("node %d: %w", i, err)
+			}
+			switch decoded := decoded.(type) {
+			case layer.Layer:
+				node.Layer = decoded
+			case layer.MergeLayer:
+				node.Merge = decoded
+			case layer.MatrixLayer:
+				node.Layer = layer.FromMatrix(decoded)
+			default:
+				return fmt.Errorf("node %d: %s is neither a layer nor a merge layer", i, json_node.Layer.Type)
+			}
+		}
+		node.Inputs = make([]*Node, len(json_node.Inputs))
+		for j, parent := range json_node.Inputs {
+			if parent < 0 || parent >= i {
+				return fmt.Errorf("node %d: input %d doesn't refer to an earlier node", i, parent)
+			}
+			node.Inputs[j] = nodes[parent]
+		}
+		nodes[i] = node
+	}
+
+	lookup := func(indices []int) ([]*Node, error) {
+		result := make([]*Node, len(indices))
+		for i, index := range indices {
+			if index < 0 || index >= len(nodes) {
+				return nil, fmt.Errorf("node index %d is out of range", index)
+			}
+			result[i] = nodes[index]
+		}
+		return result, nil
+	}
+
+	inputs, err := lookup(json_graph.Inputs)
+	if err != nil {
+		return err
+	}
+	outputs, err := lookup(json_graph.Outputs)
+	if err != nil {
+		return err
+	}
+
+	loss_func, loss_prime, err := decodeLoss(json_graph.Loss)
+	if err != nil {
+		return err
+	}
+
+	loaded := Graph{Inputs: inputs, Outputs: outputs}
+	if err := loaded.build(); err != nil {
+		return err
+	}
+
+	graph.Inputs, graph.Outputs, graph.order = loaded.Inputs, loaded.Outputs, loaded.order
+	if loss_func != nil {
+		graph.Loss, graph.LossPrime = loss_func, loss_prime
+	}
+	return nil
+}
+
+// model is implemented by Network and Graph, so both share one file format.
+type model interface {
+	kind() string
+	describe(encode paramEncoder) (any, error)
+	newPayload() any
+	restore(payload any, decode paramDecoder, capacity int) error
+	mapping() *fileMapping
+}
+
+type SaveOption func(*saveOptions)
+
+type saveOptions struct {
+	binary   bool
+	float32  bool
+	readable bool
+}
+
+// Binary saves the model in the binary container instead of JSON.
+func Binary() SaveOption {
+	return func(options *saveOptions) { options.binary = true }
+}
+
+// Float32 saves the model in the binary container with float32 tensors,
+// halving the file size at the cost of precision.
+func Float32() SaveOption {
+	return func(options *saveOptions) {
+		options.binary = true
+		options.float32 = true
+	}
+}
+
+// ReadableWeights writes weights as nested JSON number arrays with their
+// shape instead of base64, so they can be diffed, reviewed and edited.
+// Edited files have to be loaded with SkipChecksum.
+func ReadableWeights() SaveOption {
+	return func(options *saveOptions) { options.readable = true }
+}
+
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	mmap          bool
+	skip_checksum bool
+}
+
+// MemoryMap maps binary model files into memory instead of reading them.
+// float64 weights then point straight into the mapping, so even large
+// models load instantly and pages are only read when they are used.
+//
+// Only the header and layer table are verified, checking the tensor
+// checksum would read the whole file. The mapping is private: training
+// the loaded model never writes to the file. The model owns the mapping
+// until Close, or until it is loaded again, which copies the weights still
+// in it to memory and unmaps it.
+func MemoryMap() LoadOption {
+	return func(options *loadOptions) { options.mmap = true }
+}
+
+// SkipChecksum loads files whose checksums don't match, such as models
+// with hand-edited ReadableWeights. Everything else is still validated.
+func SkipChecksum() LoadOption {
+	return func(options *loadOptions) { options.skip_checksum = true }
+}
+
+// readOptions says how much of a file is verified and whether binary
+// tensors may keep pointing into the bytes being read.
+type readOptions struct {
+	alias          bool
+	verify_model   bool
+	verify_tensors bool
+
+	aliased *[]*mat.Dense // if set, collects the matrices pointing into the bytes
+}
+
+// fileMapping is a memory-mapped model file and the parameters that point
+// into it.
+type fileMapping struct {
+	unmap    func() error
+	matrices []*mat.Dense
+}
+
+// release copies the parameters out of the mapping and unmaps it, if the
+// model has one.
+func (mapping *fileMapping) release() error {
+	if mapping.unmap == nil {
+		return nil
+	}
+	for _, matrix := range mapping.matrices {
+		matrix.SetRawMatrix(mat.DenseCopyOf(matrix).RawMatrix())
+	}
+	err := mapping.unmap()
+	*mapping = fileMapping{}
+	return err
+}
+
+var defaultReadOptions = readOptions{alias: true, verify_model: true, verify_tensors: true}
+
+func marshalModel(m model, opts []SaveOption) ([]byte, error) {
+	var options saveOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.binary && options.readable {
+		return nil, errors.New("readable weights can only be written to JSON model files")
+	}
+	if options.binary {
+		return marshalBinary(m, options.float32)
+	}
+
+	encode := encodeBase64Param
+	if options.readable {
+		encode = encodeReadableParam
+	}
+	has_int8 := false
+	payload, err := m.describe(int8Tracker(encode, &has_int8))
+	if err != nil {
+		return nil, err
+	}
+	file, err := newModelFile(m.kind(), payload, has_int8)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(file, "", "    ")
+}
+
+// unmarshalModel picks the reader from the first bytes of data.
+func unmarshalModel(m model, data []byte, options readOptions) error {
+	// the model is replaced, so it no longer needs the file it was mapped from
+	if err := m.mapping().release(); err != nil {
+		return err
+	}
+
+	if isBinary(data) {
+		return unmarshalBinary(m, data, options)
+	}
+
+	payload := m.newPayload()
+	if err := decodeModel(data, m.kind(), payload, options.verify_model); err != nil {
+		return err
+	}
+	// every parameter element takes at least a byte of the file
+	return m.restore(payload, decodeJSONParam, len(data))
+}
+
+func encodeModel(m model, w io.Writer, opts []SaveOption) (int64, error) {
+	data, err := marshalModel(m, opts)
+	if err != nil {
+		return 0, err
+	}
+	written, err := w.Write(data)
+	return int64(written), err
+}
+
+// readModel reads everything from r. That data isn't used by anyone else,
+// so binary tensors can point into it.
+func readModel(m model, r io.Reader, options readOptions) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	return int64(len(data)), unmarshalModel(m, data, options)
+}
+
+func saveModel(m model, fpath string, opts []SaveOption) error {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	if _, err := encodeModel(m, f, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadModel(m model, fpath string, opts []LoadOption) error {
+	var options loadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	read_options := defaultReadOptions
+	if options.skip_checksum {
+		read_options.verify_model = false
+		read_options.verify_tensors = false
+	}
+
+	if !options.mmap {
+		f, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = readModel(m, f, read_options)
+		return err
+	}
+
+	data, unmap, err := mapFile(fpath)
+	if err != nil {
+		return err
+	}
+	if !isBinary(data) {
+		// JSON weights are always copied out, the mapping isn't needed
+		// once the model is decoded.
+		defer unmap()
+		read_options.alias = false
+		return unmarshalModel(m, data, read_options)
+	}
+	read_options.verify_tensors = false
+	var aliased []*mat.Dense
+	read_options.aliased = &aliased
+	if err := unmarshalModel(m, data, read_options); err != nil {
+		unmap()
+		return err
+	}
+	if len(aliased) == 0 {
+		// float32 tensors are converted, nothing points into the file
+		return unmap()
+	}
+	*m.mapping() = fileMapping{unmap: unmap, matrices: aliased}
+	return nil
+}
+
+func loadModelFS(m model, fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = readModel(m, f, defaultReadOptions)
+	return err
+}
@@ -0,0 +1,504 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Node is one step of a graph model. Input nodes have neither a Layer nor
+// a Merge, layer nodes have one parent and merge nodes have several.
+type Node struct {
+	Layer  layer.Layer
+	Merge  layer.MergeLayer
+	Inputs []*Node
+}
+
+func Input() *Node {
+	return &Node{}
+}
+
+func Apply(l layer.Layer, input *Node) *Node {
+	return &Node{Layer: l, Inputs: []*Node{input}}
+}
+
+func Merge(m layer.MergeLayer, inputs ...*Node) *Node {
+	return &Node{Merge: m, Inputs: inputs}
+}
+
+func (node *Node) isInput() bool {
+	return node.Layer == nil && node.Merge == nil
+}
+
+type Graph struct {
+	Inputs    []*Node
+	Outputs   []*Node
+	Loss      func(*mat.Dense, *mat.Dense) float64
+	LossPrime func(*mat.Dense, *mat.Dense) *mat.Dense
+
+	order []*Node // topological order, inputs first
+
+	forward_mu sync.Mutex // serializes Predict for layers that can't Infer
+	forwarded  bool       // the layers hold a Forward that BackProp hasn't used
+
+	mapped fileMapping // of a model loaded with MemoryMap
+}
+
+func NewGraph(inputs []*Node, outputs []*Node) (*Graph, error) {
+	graph := &Graph{Inputs: inputs, Outputs: outputs}
+	if err := graph.build(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+func (graph *Graph) build() error {
+	/*
+		Depth first search from the outputs towards the inputs.
+		A node is appended only after all of its parents are, so the
+		resulting order can be used for the forward pass directly and
+		reversed for the backward pass.
+	*/
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*Node]int)
+	is_input := make(map[*Node]bool)
+	for _, node := range graph.Inputs {
+		if !node.isInput() {
+			return errors.New("graph inputs must be created with Input()")
+		}
+		is_input[node] = true
+	}
+
+	used_layers := make(map[any]bool)
+	order := make([]*Node, 0)
+
+	var visit func(node *Node) error
+	visit = func(node *Node) error {
+		switch state[node] {
+		case visiting:
+			return errors.New("graph contains a cycle")
+		case done:
+			return nil
+		}
+		state[node] = visiting
+
+		switch {
+		case node.isInput():
+			if !is_input[node] {
+				return errors.New("graph depends on an input node that is not listed in Inputs")
+			}
+		case node.Layer != nil && node.Merge != nil:
+			return errors.New("node can't have both a layer and a merge layer")
+		case node.Layer != nil && len(node.Inputs) != 1:
+			return errors.New("layer node must have exactly one input")
+		case node.Merge != nil && len(node.Inputs) == 0:
+			return errors.New("merge node must have at least one input")
+		}
+
+		// Layers cache their input for backpropagation, so one layer
+		// can't appear at two places in the graph.
+		var used any = node.Layer
+		if node.Merge != nil {
+			used = node.Merge
+		}
+		if used != nil {
+			if used_layers[used] {
+				return errors.New("a layer is used by more than one node")
+			}
+			used_layers[used] = true
+		}
+
+		for _, parent := range node.Inputs {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[node] = done
+		order = append(order, node)
+		return nil
+	}
+
+	for _, node := range graph.Inputs {
+		if err := visit(node); err != nil {
+			return err
+		}
+	}
+	for _, node := range graph.Outputs {
+		if err := visit(node); err != nil {
+			return err
+		}
+	}
+
+	graph.order = order
+	return graph.checkShapes()
+}
+
+func (graph *Graph) checkShapes() error {
+	/*
+		Propagates the known widths through the graph and makes sure every
+		layer accepts what its parents output. Widths that can't be known
+		(graph inputs, layers built without a size) are 0 and always match.
+	*/
+	widths := make(map[*Node]int, len(graph.order))
+	for i, node := range graph.order {
+		switch {
+		case node.Layer != nil:
+			insize, outsize := layer.Sizes(node.Layer)
+			parent := widths[node.Inputs[0]]
+			if insize != 0 && parent != 0 && insize != parent {
+				return fmt.Errorf("node %d expects inputs of size %d, but its input has size %d", i, insize, parent)
+			}
+			widths[node] = outsize
+		case node.Merge != nil:
+			shaped, ok := node.Merge.(layer.MergeShaped)
+			if !ok {
+				continue
+			}
+			insizes := make([]int, len(node.Inputs))
+			for j, parent := range node.Inputs {
+				insizes[j] = widths[parent]
+			}
+			size, err := shaped.MergedSize(insizes)
+			if err != nil {
+				return fmt.Errorf("node %d: %w", i, err)
+			}
+			widths[node] = size
+		}
+	}
+	return nil
+}
+
+// Nodes returns the nodes of the graph in topological order, inputs first.
+func (graph *Graph) Nodes() []*Node {
+	return append([]*Node(nil), graph.order...)
+}
+
+// Params returns the parameters of every layer, named after the index of
+// its node in Nodes, e.g. "3.weights".
+func (graph *Graph) Params() []layer.Param {
+	params := make([]layer.Param, 0)
+	for i, node := range graph.order {
+		var l any = node.Layer
+		if node.Merge != nil {
+			l = node.Merge
+		}
+		serializable, ok := l.(layer.Serializable)
+		if !ok {
+			continue
+		}
+		for _, param := range serializable.Params() {
+			param.Name = fmt.Sprintf("%d.%s", i, param.Name)
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+func (graph *Graph) forward(inputs []*tensor.Tensor) ([]*tensor.Tensor, error) {
+	outputs, err := graph.run(context.Background(), inputs, false)
+	graph.forwarded = err == nil
+	return outputs, err
+}
+
+// infer is forward without changing the layers, see layer.Inferer. Graphs
+// with a layer that can't infer fall back to forward, one call at a time.
+func (graph *Graph) infer(ctx context.Context, inputs []*tensor.Tensor) ([]*tensor.Tensor, error) {
+	for _, node := range graph.order {
+		if (node.Layer != nil && !layer.CanInfer(node.Layer)) || (node.Merge != nil && !layer.CanInfer(node.Merge)) {
+			graph.forward_mu.Lock()
+			defer graph.forward_mu.Unlock()
+			outputs, err := graph.run(ctx, inputs, false)
+			// a Forward before the Predict was overwritten
+			graph.forwarded = false
+			if err != nil {
+				return nil, err
+			}
+			// the layers own what Forward returns
+			for i, output := range outputs {
+				outputs[i] = output.Clone()
+			}
+			return outputs, nil
+		}
+	}
+	return graph.run(ctx, inputs, true)
+}
+
+// run computes every node in order, checking ctx before each one.
+func (graph *Graph) run(ctx context.Context, inputs []*tensor.Tensor, infer bool) ([]*tensor.Tensor, error) {
+	if len(inputs) != len(graph.Inputs) {
+		return nil, fmt.Errorf("graph expects %d inputs, got %d", len(graph.Inputs), len(inputs))
+	}
+
+	values := make(map[*Node]*tensor.Tensor, len(graph.order))
+	for i, node := range graph.Inputs {
+		values[node] = inputs[i]
+	}
+
+	for _, node := range graph.order {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var err error
+		switch {
+		case node.Layer != nil && infer:
+			values[node], err = node.Layer.(layer.Inferer).Infer(values[node.Inputs[0]])
+		case node.Layer != nil:
+			values[node], err = node.Layer.Forward(values[node.Inputs[0]])
+		case node.Merge != nil:
+			merge_inputs := make([]*tensor.Tensor, len(node.Inputs))
+			for i, parent := range node.Inputs {
+				merge_inputs[i] = values[parent]
+			}
+			if infer {
+				values[node], err = node.Merge.(layer.MergeInferer).Infer(merge_inputs)
+			} else {
+				values[node], err = node.Merge.Forward(merge_inputs)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outputs := make([]*tensor.Tensor, len(graph.Outputs))
+	for i, node := range graph.Outputs {
+		outputs[i] = values[node]
+	}
+	return outputs, nil
+}
+
+// toTensors and toDense convert between the matrix and the tensor API.
+func toTensors(matrices []*mat.Dense) []*tensor.Tensor {
+	tensors := make([]*tensor.Tensor, len(matrices))
+	for i, m := range matrices {
+		tensors[i] = tensor.FromDense(m)
+	}
+	return tensors
+}
+
+func toDense(tensors []*tensor.Tensor) ([]*mat.Dense, error) {
+	matrices := make([]*mat.Dense, len(tensors))
+	for i, t := range tensors {
+		var err error
+		if matrices[i], err = t.Dense(); err != nil {
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+	return matrices, nil
+}
+
+// Forward runs the graph and keeps what BackProp needs, for training loops
+// written by hand. Unlike Predict it changes the layers, and the outputs
+// may be owned by them, to be overwritten by the next Forward.
+func (graph *Graph) Forward(inputs ...*mat.Dense) ([]*mat.Dense, error) {
+	outputs, err := graph.forward(toTensors(inputs))
+	if err != nil {
+		return nil, err
+	}
+	return toDense(outputs)
+}
+
+// ForwardTensor is Forward for inputs of any rank, with the batch first,
+// see Network.ForwardTensor.
+func (graph *Graph) ForwardTensor(inputs ...*tensor.Tensor) ([]*tensor.Tensor, error) {
+	return graph.forward(inputs)
+}
+
+// Predict is safe to call from several goroutines at once, as long as the
+// graph isn't trained at the same time. It doesn't keep what BackProp
+// needs, call Forward for that.
+func (graph *Graph) Predict(inputs ...*mat.Dense) []*mat.Dense {
+	outputs, err := graph.PredictContext(context.Background(), inputs...)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	return outputs
+}
+
+// PredictContext is Predict, returning its error. It stops between two
+// nodes once ctx is done and returns ctx.Err().
+func (graph *Graph) PredictContext(ctx context.Context, inputs ...*mat.Dense) ([]*mat.Dense, error) {
+	outputs, err := graph.infer(ctx, toTensors(inputs))
+	if err != nil {
+		return nil, err
+	}
+	return toDense(outputs)
+}
+
+// PredictTensor is Predict for inputs of any rank, see ForwardTensor.
+func (graph *Graph) PredictTensor(inputs ...*tensor.Tensor) []*tensor.Tensor {
+	outputs, err := graph.infer(context.Background(), inputs)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	return outputs
+}
+
+// PredictTensorContext is PredictTensor, returning its error, see
+// PredictContext.
+func (graph *Graph) PredictTensorContext(ctx context.Context, inputs ...*tensor.Tensor) ([]*tensor.Tensor, error) {
+	return graph.infer(ctx, inputs)
+}
+
+// BackProp updates the layers with the gradients of the loss with respect
+// to the outputs of the last Forward. Every BackProp needs its own Forward,
+// it panics without one, for example after Predict.
+func (graph *Graph) BackProp(out_grads []*mat.Dense, rate float64) {
+	graph.BackPropTensor(toTensors(out_grads), rate)
+}
+
+// BackPropTensor is BackProp after a ForwardTensor. It panics if a
+// gradient doesn't have the shape of its output.
+func (graph *Graph) BackPropTensor(out_grads []*tensor.Tensor, rate float64) {
+	if !graph.forwarded {
+		panic("network: BackProp without a Forward, Predict doesn't keep what BackProp needs")
+	}
+	graph.forwarded = false
+	/*
+		When a node feeds several others, its output gradient is the sum of
+		the gradients flowing back from each of them. Walking the nodes in
+		reverse topological order guarantees that every consumer has been
+		handled before a node's own Backward is called.
+	*/
+	grads := make(map[*Node]*tensor.Tensor, len(graph.order))
+	accumulate := func(node *Node, grad *tensor.Tensor) {
+		if prev, ok := grads[node]; ok {
+			sum, err := tensor.Add(prev, grad)
+			if err != nil {
+				panic(err)
+			}
+			grads[node] = sum
+		} else {
+			grads[node] = grad
+		}
+	}
+
+	for i, node := range graph.Outputs {
+		accumulate(node, out_grads[i])
+	}
+
+	for i := len(graph.order) - 1; i >= 0; i-- {
+		node := graph.order[i]
+		grad, ok := grads[node]
+		if !ok {
+			continue
+		}
+		switch {
+		case node.Layer != nil:
+			accumulate(node.Inputs[0], node.Layer.Backward(grad, rate))
+		case node.Merge != nil:
+			for j, in_grad := range node.Merge.Backward(grad, rate) {
+				accumulate(node.Inputs[j], in_grad)
+			}
+		}
+	}
+}
+
+// releaseTraining lets the layers drop what only Backward needs.
+func (graph *Graph) releaseTraining() {
+	for _, node := range graph.order {
+		if node.Layer != nil {
+			layer.ReleaseTraining(node.Layer)
+		}
+		if node.Merge != nil {
+			layer.ReleaseTraining(node.Merge)
+		}
+	}
+}
+
+// Train expects inputs[i] and outputs[i] to hold every graph input and
+// every expected graph output of the i-th sample.
+func (graph *Graph) Train(inputs [][]*mat.Dense, outputs [][]*mat.Dense, epoch int, rate float64) {
+	if err := graph.TrainContext(context.Background(), inputs, outputs, epoch, rate); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// TrainContext is Train, returning its error. Training stops between two
+// samples once ctx is done and ctx.Err() is returned.
+func (graph *Graph) TrainContext(ctx context.Context, inputs [][]*mat.Dense, outputs [][]*mat.Dense, epoch int, rate float64) error {
+	if len(outputs) != len(inputs) {
+		return fmt.Errorf("got %d input samples but %d output samples", len(inputs), len(outputs))
+	}
+	for j := range inputs {
+		if len(inputs[j]) != len(graph.Inputs) {
+			return fmt.Errorf("sample %d: graph expects %d inputs, got %d", j, len(graph.Inputs), len(inputs[j]))
+		}
+		if len(outputs[j]) != len(graph.Outputs) {
+			return fmt.Errorf("sample %d: graph has %d outputs, got %d targets", j, len(graph.Outputs), len(outputs[j]))
+		}
+	}
+
+	defer graph.releaseTraining()
+	for i := 0; i < epoch; i++ {
+		loss := float64(0)
+		for j, input := range inputs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			results, err := graph.Forward(input...)
+			if err != nil {
+				return err
+			}
+
+			out_grads := make([]*mat.Dense, len(results))
+			for k, result := range results {
+				loss += graph.Loss(outputs[j][k], result)
+				out_grads[k] = graph.LossPrime(outputs[j][k], result)
+			}
+			graph.BackProp(out_grads, rate)
+		}
+		fmt.Printf("Epoch = (%d/%d), error = %f\n", i+1, epoch, loss/float64(len(inputs)))
+	}
+	return nil
+}
+
+func (graph *Graph) WriteTo(w io.Writer) (int64, error) {
+	return encodeModel(graph, w, nil)
+}
+
+// Encode writes the model to w like WriteTo, with save options such as
+// Binary() or Float32().
+func (graph *Graph) Encode(w io.Writer, opts ...SaveOption) error {
+	_, err := encodeModel(graph, w, opts)
+	return err
+}
+
+// ReadFrom reads a model in any of the formats written by Encode.
+func (graph *Graph) ReadFrom(r io.Reader) (int64, error) {
+	return readModel(graph, r, defaultReadOptions)
+}
+
+func (graph *Graph) Save(fpath string, opts ...SaveOption) error {
+	return saveModel(graph, fpath, opts)
+}
+
+// Load detects the file format on its own, JSON and binary files are both
+// accepted.
+func (graph *Graph) Load(fpath string, opts ...LoadOption) error {
+	return loadModel(graph, fpath, opts)
+}
+
+// Close releases the file mapped by Load with MemoryMap, see Network.Close.
+func (graph *Graph) Close() error {
+	return graph.mapped.release()
+}
+
+// LoadFS loads a model from any fs.FS, such as an embed.FS compiled into
+// the binary.
+func (graph *Graph) LoadFS(fsys fs.FS, name string) error {
+	return loadModelFS(graph, fsys, name)
+}
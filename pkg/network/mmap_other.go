@@ -0,0 +1,14 @@
+//go:build !unix
+
+package network
+
+import "os"
+
+// mapFile falls back to reading the whole file where mmap isn't available.
+func mapFile(fpath string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package network
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mapFile maps fpath privately: writes go to copy-on-write pages and never
+// reach the file.
+func mapFile(fpath string) ([]byte, func() error, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, errors.New("model file is too large to map")
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -1,15 +1,21 @@
 package network
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
+	"math/rand/v2"
 	"reflect"
 	"runtime"
+	"sync"
 
+	"github.com/kapilpokhrel/goNN/pkg/data"
 	"github.com/kapilpokhrel/goNN/pkg/layer"
 	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/preprocess"
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -17,136 +23,647 @@ type Network struct {
 	Layers    []layer.Layer
 	Loss      func(*mat.Dense, *mat.Dense) float64
 	LossPrime func(*mat.Dense, *mat.Dense) *mat.Dense
+
+	// Preprocess, if set, is a fitted pipeline applied to every input
+	// before the first layer, by Train as well as Predict. It is saved
+	// with the network.
+	Preprocess *preprocess.Pipeline
+
+	// Schedule, if set, gives the learning rate of each epoch from the
+	// rate passed to Train. It isn't saved, set it again before Resume.
+	Schedule func(epoch int, rate float64) float64
+
+	// Checkpoints, if set, saves checkpoints while training.
+	Checkpoints *Checkpointer
+
+	// OnEpoch, if set, is called after every epoch, before the checkpoint
+	// is saved, for example to prune weights, see package prune. An error
+	// stops training. It isn't saved, set it again before Resume.
+	OnEpoch func(network *Network) error
+
+	// Workers, if more than 1, trains every batch data-parallel: the batch
+	// is split between as many replicas of the network, each running on its
+	// own goroutine. Every layer has to be registered, see layer.Register.
+	Workers int
+	// Hogwild, with more than 1 Workers, trains asynchronously instead:
+	// every worker takes whole batches and updates the shared weights
+	// without locking. It is faster for sparse models but not reproducible,
+	// not even when resuming from a checkpoint.
+	Hogwild bool
+
+	// InferFloat32 makes Predict run in float32, on a float32 copy of the
+	// weights made by the first call, see package f32. Every layer has to
+	// implement layer.Converter32. Training and Load update the copy, call
+	// ResetFloat32 after changing the weights any other way.
+	InferFloat32 bool
+
+	State TrainState
+
+	forward_mu sync.Mutex // serializes Predict for layers that can't Infer
+	scratch    sync.Pool  // of *[2]mat.Dense, for the outputs between layers in Predict
+	forwarded  bool       // the layers hold a Forward that BackProp hasn't used
+
+	float32_mu     sync.Mutex
+	float32_layers []layer.Layer32
+
+	mapped fileMapping // of a model loaded with MemoryMap
 }
 
-func (network *Network) Predict(input *mat.Dense) *mat.Dense {
-	result := input
-	for _, layer := range network.Layers {
-		var err error
-		result, err = layer.Forward(result)
+// preprocess applies the Preprocess pipeline, if there is one.
+func (network *Network) preprocess(input *mat.Dense) (*mat.Dense, error) {
+	if network.Preprocess == nil {
+		return input, nil
+	}
+	result, err := network.Preprocess.Transform(input)
+	if err != nil {
+		return nil, fmt.Errorf("preprocess: %w", err)
+	}
+	return result, nil
+}
+
+// preprocessTensor applies the Preprocess pipeline to the rows of input,
+// see tensor.Rows.
+func (network *Network) preprocessTensor(input *tensor.Tensor) (*tensor.Tensor, error) {
+	if network.Preprocess == nil {
+		return input, nil
+	}
+	rows, err := input.Rows()
+	if err != nil {
+		return nil, err
+	}
+	result, err := network.preprocess(rows)
+	if err != nil {
+		return nil, err
+	}
+	return tensor.FromRows(result, input.Shape()), nil
+}
+
+// rowwise reports whether every layer works on rows, see layer.Rowwise.
+// The network then runs on matrices from start to end, other networks run
+// on tensors.
+func (network *Network) rowwise() bool {
+	for _, current_layer := range network.Layers {
+		if !layer.Rowwise(current_layer) {
+			return false
+		}
+	}
+	return true
+}
+
+func (network *Network) forward(input *mat.Dense) (*mat.Dense, error) {
+	if !network.rowwise() {
+		result, err := network.forwardLayers(tensor.FromDense(input))
 		if err != nil {
-			fmt.Println(err)
-			return nil
+			return nil, err
 		}
+		return result.Dense()
+	}
+
+	result, err := network.preprocess(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, current_layer := range network.Layers {
+		result, err = current_layer.(layer.RowLayer).ForwardRows(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	network.forwarded = true
+	return result, nil
+}
+
+func (network *Network) forwardTensor(input *tensor.Tensor) (*tensor.Tensor, error) {
+	if !network.rowwise() {
+		return network.forwardLayers(input)
+	}
+	rows, err := input.Rows()
+	if err != nil {
+		return nil, err
+	}
+	result, err := network.forward(rows)
+	if err != nil {
+		return nil, err
+	}
+	return tensor.FromRows(result, input.Shape()), nil
+}
+
+// forwardLayers is forward on tensors, layer by layer.
+func (network *Network) forwardLayers(input *tensor.Tensor) (*tensor.Tensor, error) {
+	result, err := network.preprocessTensor(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, current_layer := range network.Layers {
+		result, err = current_layer.Forward(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	network.forwarded = true
+	return result, nil
+}
+
+// canInfer reports whether every layer can infer, see layer.CanInfer.
+func (network *Network) canInfer() bool {
+	for _, current_layer := range network.Layers {
+		if !layer.CanInfer(current_layer) {
+			return false
+		}
+	}
+	return true
+}
+
+// infer runs the network without changing its layers, see layer.Inferer.
+// Networks with a layer that can't infer fall back to forward, one call at
+// a time. ctx is checked between layers.
+func (network *Network) infer(ctx context.Context, input *mat.Dense) (*mat.Dense, error) {
+	if network.InferFloat32 {
+		return network.infer32(ctx, input)
+	}
+	if !network.canInfer() {
+		network.forward_mu.Lock()
+		defer network.forward_mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := network.forward(input)
+		// a Forward before the Predict was overwritten
+		network.forwarded = false
+		if err != nil {
+			return nil, err
+		}
+		// the layers own what Forward returns
+		return mat.DenseCopyOf(result), nil
+	}
+	if !network.rowwise() {
+		result, err := network.inferTensor(ctx, tensor.FromDense(input))
+		if err != nil {
+			return nil, err
+		}
+		return result.Dense()
+	}
+
+	result, err := network.preprocess(input)
+	if err != nil {
+		return nil, err
+	}
+
+	/*
+		Layers that can infer into a matrix alternate between two scratch
+		matrices, taken from a pool so that concurrent calls don't share
+		them. Only the final result is copied out.
+	*/
+	scratch, _ := network.scratch.Get().(*[2]mat.Dense)
+	if scratch == nil {
+		scratch = new([2]mat.Dense)
+	}
+	defer network.scratch.Put(scratch)
+
+	for _, current_layer := range network.Layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err = inferInto(current_layer, result, scratch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result == &scratch[0] || result == &scratch[1] {
+		result = mat.DenseCopyOf(result)
+	}
+	return result, nil
+}
+
+// inferTensor is infer on tensors. Networks working on rows run infer on
+// the rows of input.
+func (network *Network) inferTensor(ctx context.Context, input *tensor.Tensor) (*tensor.Tensor, error) {
+	if network.InferFloat32 || network.rowwise() {
+		rows, err := input.Rows()
+		if err != nil {
+			return nil, err
+		}
+		result, err := network.infer(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		return tensor.FromRows(result, input.Shape()), nil
+	}
+	if !network.canInfer() {
+		network.forward_mu.Lock()
+		defer network.forward_mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := network.forwardLayers(input)
+		// a Forward before the Predict was overwritten
+		network.forwarded = false
+		if err != nil {
+			return nil, err
+		}
+		// the layers own what Forward returns
+		return result.Clone(), nil
+	}
+
+	result, err := network.preprocessTensor(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, current_layer := range network.Layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err = current_layer.(layer.Inferer).Infer(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// inferInto runs l on input, writing to whichever scratch matrix isn't the
+// input if l can infer into a matrix. Sequential blocks are run one child
+// at a time, so their children share the scratch matrices.
+func inferInto(l layer.Layer, input *mat.Dense, scratch *[2]mat.Dense) (*mat.Dense, error) {
+	switch l := l.(type) {
+	case *layer.SequentialLayer:
+		result := input
+		for _, child := range l.Layers {
+			var err error
+			if result, err = inferInto(child, result, scratch); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case layer.InfererInto:
+		output := &scratch[0]
+		if output == input {
+			output = &scratch[1]
+		}
+		if err := l.InferInto(output, input); err != nil {
+			return nil, err
+		}
+		return output, nil
+	case layer.RowInferer:
+		return l.InferRows(input)
+	}
+	output, err := l.(layer.Inferer).Infer(tensor.FromDense(input))
+	if err != nil {
+		return nil, err
+	}
+	return output.Dense()
+}
+
+// Forward runs the network and keeps what BackProp needs, for training
+// loops written by hand. Unlike Predict it changes the layers, and the
+// result may be owned by the last one, to be overwritten by the next
+// Forward.
+func (network *Network) Forward(input *mat.Dense) (*mat.Dense, error) {
+	return network.forward(input)
+}
+
+// ForwardTensor is Forward for inputs of any rank, with the batch first.
+// Layers working on rows, like Dense, apply to the last dimension, see
+// layer.Layer. The result follows the same ownership rule as Forward's.
+func (network *Network) ForwardTensor(input *tensor.Tensor) (*tensor.Tensor, error) {
+	return network.forwardTensor(input)
+}
+
+// Predict is safe to call from several goroutines at once, as long as the
+// network isn't trained at the same time. It doesn't keep what BackProp
+// needs, call Forward for that.
+func (network *Network) Predict(input *mat.Dense) *mat.Dense {
+	result, err := network.infer(context.Background(), input)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	return result
+}
+
+// PredictContext is Predict, returning its error. It stops between two
+// layers once ctx is done and returns ctx.Err().
+func (network *Network) PredictContext(ctx context.Context, input *mat.Dense) (*mat.Dense, error) {
+	return network.infer(ctx, input)
+}
+
+// PredictTensor is Predict for inputs of any rank, see ForwardTensor.
+func (network *Network) PredictTensor(input *tensor.Tensor) *tensor.Tensor {
+	result, err := network.inferTensor(context.Background(), input)
+	if err != nil {
+		fmt.Println(err)
+		return nil
 	}
 	return result
 }
 
+// PredictTensorContext is PredictTensor, returning its error, see
+// PredictContext.
+func (network *Network) PredictTensorContext(ctx context.Context, input *tensor.Tensor) (*tensor.Tensor, error) {
+	return network.inferTensor(ctx, input)
+}
+
+// startBackProp checks that a Forward came before BackProp.
+func (network *Network) startBackProp() {
+	if !network.forwarded {
+		panic("network: BackProp without a Forward, Predict doesn't keep what BackProp needs")
+	}
+	network.forwarded = false
+	network.ResetFloat32()
+}
+
+// BackProp updates the layers with the gradient of the loss with respect to
+// the result of the last Forward. Every BackProp needs its own Forward, it
+// panics without one, for example after Predict, which doesn't store the
+// inputs of the layers.
 func (network *Network) BackProp(out_grad *mat.Dense, rate float64) {
+	if !network.rowwise() {
+		network.BackPropTensor(tensor.FromDense(out_grad), rate)
+		return
+	}
+	network.startBackProp()
 	in_grad := out_grad
 	for i := len(network.Layers) - 1; i >= 0; i-- {
-		layer := network.Layers[i]
-		in_grad = layer.Backward(in_grad, rate)
+		in_grad = network.Layers[i].(layer.RowLayer).BackwardRows(in_grad, rate)
 	}
 }
 
+// BackPropTensor is BackProp after a ForwardTensor. It panics if out_grad
+// doesn't have the shape of the result.
+func (network *Network) BackPropTensor(out_grad *tensor.Tensor, rate float64) {
+	if network.rowwise() {
+		rows, err := out_grad.Rows()
+		if err != nil {
+			panic(err)
+		}
+		network.BackProp(rows, rate)
+		return
+	}
+	network.startBackProp()
+	in_grad := out_grad
+	for i := len(network.Layers) - 1; i >= 0; i-- {
+		in_grad = network.Layers[i].Backward(in_grad, rate)
+	}
+}
+
+// Train starts a new run of epoch epochs over the samples, one at a time
+// and in order. Errors are printed and stop training, the run can be
+// continued with Resume.
 func (network *Network) Train(inputs []*mat.Dense, outputs []*mat.Dense, epoch int, rate float64) {
-	for i := 0; i < epoch; i++ {
-		err := float64(0)
-		for j, input := range inputs {
-			result := network.Predict(input)
-			err += network.Loss(outputs[j], result)
+	if err := network.TrainContext(context.Background(), inputs, outputs, epoch, rate); err != nil {
+		fmt.Println(err)
+	}
+}
 
-			out_grad := network.LossPrime(outputs[j], result)
-			network.BackProp(out_grad, rate)
-		}
-		fmt.Printf("Epoch = (%d/%d), error = %f\n", i+1, epoch, err/float64(len(inputs)))
+// TrainLoader is Train for samples from a DataLoader.
+func (network *Network) TrainLoader(loader *data.DataLoader, epoch int, rate float64) {
+	if err := network.TrainLoaderContext(context.Background(), loader, epoch, rate); err != nil {
+		fmt.Println(err)
 	}
 }
 
-// From: https://stackoverflow.com/a/7053871
-func GetFunctionName(i interface{}) string {
-	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
+// TrainContext is Train, returning its error. Training stops between two
+// batches once ctx is done and ctx.Err() is returned.
+func (network *Network) TrainContext(ctx context.Context, inputs []*mat.Dense, outputs []*mat.Dense, epoch int, rate float64) error {
+	dataset, err := data.FromSlices(inputs, outputs)
+	if err != nil {
+		return err
+	}
+	return network.TrainLoaderContext(ctx, &data.DataLoader{Dataset: dataset}, epoch, rate)
 }
 
-type JSONLayer map[string]string //weights and biases are stored in base64 after gonum's MarshalBinary
-type JSONNewtork struct {
-	Layers []JSONLayer
-	Loss   string
+// TrainLoaderContext is TrainLoader with a context, see TrainContext.
+func (network *Network) TrainLoaderContext(ctx context.Context, loader *data.DataLoader, epoch int, rate float64) error {
+	network.State = TrainState{
+		Epochs: epoch,
+		Rate:   rate,
+		RNG:    rand.NewPCG(rand.Uint64(), rand.Uint64()),
+	}
+	return network.ResumeLoaderContext(ctx, loader)
 }
 
-func (network *Network) Save(fpath string) {
-	json_layers := make([]JSONLayer, len(network.Layers))
-	var json_network JSONNewtork
+// Resume continues the run in State, usually restored by LoadCheckpoint,
+// until all its epochs are done.
+func (network *Network) Resume(inputs []*mat.Dense, outputs []*mat.Dense) error {
+	return network.ResumeContext(context.Background(), inputs, outputs)
+}
 
-	for i, current_layer := range network.Layers {
-		json_layer := make(JSONLayer)
-		switch reflect.TypeOf(current_layer).String() {
-		case "*layer.DenseLayer":
-			json_layer["type"] = "Dense"
-			original_layer := current_layer.(*layer.DenseLayer)
-			weights_bin, _ := original_layer.Weights.MarshalBinary()
-			biases_bin, _ := original_layer.Biases.MarshalBinary()
+// ResumeLoader is Resume for samples from a DataLoader. Shuffling uses
+// State.RNG, so a resumed run sees the same order the original would have.
+func (network *Network) ResumeLoader(loader *data.DataLoader) error {
+	return network.ResumeLoaderContext(context.Background(), loader)
+}
 
-			weights_base64 := make([]byte, base64.StdEncoding.EncodedLen(len(weights_bin)))
-			base64.StdEncoding.Encode(weights_base64, weights_bin)
-			json_layer["weights"] = string(weights_base64)
+// ResumeContext is Resume with a context, see TrainContext.
+func (network *Network) ResumeContext(ctx context.Context, inputs []*mat.Dense, outputs []*mat.Dense) error {
+	dataset, err := data.FromSlices(inputs, outputs)
+	if err != nil {
+		return err
+	}
+	return network.ResumeLoaderContext(ctx, &data.DataLoader{Dataset: dataset})
+}
+
+// ResumeLoaderContext is ResumeLoader with a context, see TrainContext.
+//
+// An epoch that is cancelled halfway keeps the updates made so far, but
+// State still points at its start, with the random state it started with.
+// A checkpoint saved on cancellation, see Checkpointer.OnCancel, therefore
+// repeats that epoch in the same order when resumed.
+func (network *Network) ResumeLoaderContext(ctx context.Context, loader *data.DataLoader) error {
+	defer network.releaseTraining()
+	state := &network.State
+	if state.RNG == nil {
+		state.RNG = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+
+	for state.Epoch < state.Epochs {
+		rng_state, err := state.RNG.MarshalBinary()
+		if err != nil {
+			return err
+		}
 
-			biases_base64 := make([]byte, base64.StdEncoding.EncodedLen(len(biases_bin)))
-			base64.StdEncoding.Encode(biases_base64, biases_bin)
-			json_layer["biases"] = string(biases_base64)
+		loss, err := network.epoch(ctx, loader, network.rate())
+		if err != nil && ctx.Err() != nil {
+			return network.cancelled(ctx, rng_state)
+		}
+		if err != nil {
+			return fmt.Errorf("epoch %d: %w", state.Epoch+1, err)
+		}
+		state.Epoch++
+		fmt.Printf("Epoch = (%d/%d), error = %f\n", state.Epoch, state.Epochs, loss)
 
-		case "*layer.TanhLayer":
-			json_layer["type"] = "Tanh"
-		default:
+		if network.OnEpoch != nil {
+			if err := network.OnEpoch(network); err != nil {
+				return fmt.Errorf("epoch %d: %w", state.Epoch, err)
+			}
+			network.ResetFloat32()
 		}
 
-		json_layers[i] = json_layer
+		if network.Checkpoints != nil {
+			if err := network.Checkpoints.epochDone(network); err != nil {
+				return fmt.Errorf("saving checkpoint: %w", err)
+			}
+		}
 	}
-	json_network.Layers = json_layers
-	switch GetFunctionName(network.Loss) {
-	case "github.com/kapilpokhrel/goNN/pkg/loss.MSE":
-		json_network.Loss = "MSE"
-	default:
+	return nil
+}
+
+// releaseTraining lets the layers drop what only Backward needs, see
+// layer.TrainingReleaser.
+func (network *Network) releaseTraining() {
+	for _, current_layer := range network.Layers {
+		layer.ReleaseTraining(current_layer)
 	}
+}
 
-	f, err := os.Create(fpath)
-	if err != nil {
-		panic(err)
+// cancelled rewinds the random state to the start of the interrupted epoch
+// and saves the final checkpoint if one is wanted.
+func (network *Network) cancelled(ctx context.Context, rng_state []byte) error {
+	if err := network.State.RNG.UnmarshalBinary(rng_state); err != nil {
+		return errors.Join(ctx.Err(), err)
 	}
-	json_str, _ := json.MarshalIndent(json_network, "", "    ")
-	f.WriteString(string(json_str))
+	if network.Checkpoints != nil && network.Checkpoints.OnCancel {
+		if err := network.Checkpoints.save(network); err != nil {
+			return errors.Join(ctx.Err(), fmt.Errorf("saving checkpoint: %w", err))
+		}
+	}
+	return ctx.Err()
 }
 
-func (network *Network) Load(fpath string) {
-	var json_network JSONNewtork
+// epoch trains on every batch once and returns the mean loss per sample.
+func (network *Network) epoch(ctx context.Context, loader *data.DataLoader, rate float64) (float64, error) {
+	// parallel training doesn't go through BackProp
+	defer network.ResetFloat32()
+
+	var trainer *parallelTrainer
+	if network.Workers > 1 && !network.Hogwild {
+		var err error
+		if trainer, err = network.newParallelTrainer(network.Workers); err != nil {
+			return 0, err
+		}
+	}
 
-	data, err := os.ReadFile(fpath)
+	batches, err := loader.Epoch(network.State.RNG)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	json.Unmarshal(data, &json_network)
+	defer batches.Close()
 
-	layers := make([]layer.Layer, len(json_network.Layers))
-	for i, current_layer := range json_network.Layers {
-		switch current_layer["type"] {
-		case "Dense":
-			var denselayer layer.DenseLayer
-			weights_bin, _ := base64.StdEncoding.DecodeString(current_layer["weights"])
-			biases_bin, _ := base64.StdEncoding.DecodeString(current_layer["biases"])
+	if network.Workers > 1 && network.Hogwild {
+		loss, samples, err := network.hogwildEpoch(ctx, batches, rate)
+		if err == nil && samples == 0 {
+			err = errors.New("data loader returned no samples")
+		}
+		if err != nil {
+			return 0, err
+		}
+		return loss / float64(samples), nil
+	}
 
-			var weights mat.Dense
-			weights.UnmarshalBinary(weights_bin)
-			var biases mat.Dense
-			biases.UnmarshalBinary(biases_bin)
+	loss, samples := float64(0), 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		batch, err := batches.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
 
-			denselayer.Weights = &weights
-			denselayer.Biases = &biases
-			layers[i] = &denselayer
+		if trainer != nil && batch.Size > 1 {
+			batch_loss, err := trainer.step(batch, rate)
+			if err != nil {
+				return 0, err
+			}
+			loss += batch_loss * float64(batch.Size)
+			samples += batch.Size
+			continue
+		}
 
-		case "Tanh":
-			var tanhlayer layer.TanhLayer
-			layers[i] = &tanhlayer
-		default:
+		result, err := network.forward(batch.Inputs)
+		if err != nil {
+			return 0, err
 		}
+		loss += network.Loss(batch.Targets, result) * float64(batch.Size)
+		samples += batch.Size
+
+		out_grad := network.LossPrime(batch.Targets, result)
+		network.BackProp(out_grad, rate)
+	}
+	if samples == 0 {
+		return 0, errors.New("data loader returned no samples")
+	}
+	return loss / float64(samples), nil
+}
+
+// Params returns the parameters of every layer, named after the layer's
+// index, e.g. "0.weights" or "2.1.biases" inside a Sequential block.
+func (network *Network) Params() []layer.Param {
+	return layer.Sequential(network.Layers...).Params()
+}
 
+// From: https://stackoverflow.com/a/7053871
+func GetFunctionName(i interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
+}
+
+func lossName(loss_func func(*mat.Dense, *mat.Dense) float64) string {
+	switch GetFunctionName(loss_func) {
+	case "github.com/kapilpokhrel/goNN/pkg/loss.MSE":
+		return "MSE"
+	default:
 	}
+	return ""
+}
 
-	network.Layers = layers
-	switch json_network.Loss {
+func lossFuncs(name string) (func(*mat.Dense, *mat.Dense) float64, func(*mat.Dense, *mat.Dense) *mat.Dense) {
+	switch name {
 	case "MSE":
-		network.Loss = loss.MSE
-		network.LossPrime = loss.MSE_Prime
+		return loss.MSE, loss.MSE_Prime
 	default:
 	}
+	return nil, nil
+}
+
+func (network *Network) WriteTo(w io.Writer) (int64, error) {
+	return encodeModel(network, w, nil)
+}
+
+// Encode writes the model to w like WriteTo, with save options such as
+// Binary() or Float32().
+func (network *Network) Encode(w io.Writer, opts ...SaveOption) error {
+	_, err := encodeModel(network, w, opts)
+	return err
+}
+
+// ReadFrom reads a model in any of the formats written by Encode.
+func (network *Network) ReadFrom(r io.Reader) (int64, error) {
+	return readModel(network, r, defaultReadOptions)
+}
+
+func (network *Network) Save(fpath string, opts ...SaveOption) error {
+	return saveModel(network, fpath, opts)
+}
+
+// Load detects the file format on its own, JSON and binary files are both
+// accepted.
+func (network *Network) Load(fpath string, opts ...LoadOption) error {
+	return loadModel(network, fpath, opts)
+}
+
+// Close releases the file mapped by Load with MemoryMap. The weights still
+// in it are copied to memory first, so the network stays usable. It does
+// nothing for networks that weren't memory-mapped, and mustn't be called
+// while the network is used.
+func (network *Network) Close() error {
+	return network.mapped.release()
+}
+
+// LoadFS loads a model from any fs.FS, such as an embed.FS compiled into
+// the binary.
+func (network *Network) LoadFS(fsys fs.FS, name string) error {
+	return loadModelFS(network, fsys, name)
 }
@@ -0,0 +1,233 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"gonum.org/v1/gonum/mat"
+)
+
+/*
+	Data-parallel training keeps one replica of the network per worker.
+	Every batch is split into contiguous shards of rows and each replica
+	trains on one shard, starting from the network's current parameters.
+	Layers apply their update during Backward, so what a replica changes is
+	its shard's gradient scaled by -rate. The network then moves by the
+	average of those changes, weighted by shard size, and the replicas are
+	synchronized to it before the next batch.
+
+	With a loss that is a mean over the batch, like MSE, the weighted
+	average of the shard gradients is the gradient of the whole batch, so
+	a step matches the single-threaded one up to rounding.
+*/
+
+// replicate builds an independent copy of the network's layers with the
+// same parameters. Every layer has to be registered.
+func (network *Network) replicate() (*Network, error) {
+	spec, err := layer.Describe(layer.Sequential(network.Layers...))
+	if err != nil {
+		return nil, fmt.Errorf("can't replicate network: %w", err)
+	}
+	built, err := layer.Build(spec)
+	if err != nil {
+		return nil, fmt.Errorf("can't replicate network: %w", err)
+	}
+
+	replica := &Network{
+		Layers:     built.(*layer.SequentialLayer).Layers,
+		Loss:       network.Loss,
+		LossPrime:  network.LossPrime,
+		Preprocess: network.Preprocess,
+	}
+	copyParams(replica.Params(), network.Params())
+	return replica, nil
+}
+
+func copyParams(dst, src []layer.Param) {
+	for i := range dst {
+		if dst[i].Int8 != nil {
+			copy(dst[i].Int8.Data, src[i].Int8.Data)
+			continue
+		}
+		dst[i].Value.Copy(src[i].Value)
+	}
+}
+
+// parallelTrainer trains one network on shards of every batch.
+type parallelTrainer struct {
+	params   []layer.Param
+	replicas []*Network
+	shards   [][]layer.Param // parameters of every replica
+}
+
+func (network *Network) newParallelTrainer(workers int) (*parallelTrainer, error) {
+	trainer := &parallelTrainer{params: network.Params()}
+	for i := 0; i < workers; i++ {
+		replica, err := network.replicate()
+		if err != nil {
+			return nil, err
+		}
+		trainer.replicas = append(trainer.replicas, replica)
+		trainer.shards = append(trainer.shards, replica.Params())
+	}
+	return trainer, nil
+}
+
+// step trains on one batch and returns its mean loss.
+func (trainer *parallelTrainer) step(batch data.Batch, rate float64) (float64, error) {
+	rows, input_cols := batch.Inputs.Dims()
+	_, target_cols := batch.Targets.Dims()
+	workers := min(len(trainer.replicas), rows)
+
+	losses := make([]float64, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for k := 0; k < workers; k++ {
+		start, end := k*rows/workers, (k+1)*rows/workers
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			replica := trainer.replicas[k]
+			copyParams(trainer.shards[k], trainer.params)
+
+			inputs := batch.Inputs.Slice(start, end, 0, input_cols).(*mat.Dense)
+			targets := batch.Targets.Slice(start, end, 0, target_cols).(*mat.Dense)
+			result, err := replica.forward(inputs)
+			if err != nil {
+				errs[k] = err
+				return
+			}
+			losses[k] = replica.Loss(targets, result) * float64(end-start)
+			replica.BackProp(replica.LossPrime(targets, result), rate)
+		}(k)
+	}
+	wg.Wait()
+
+	loss := float64(0)
+	for k := 0; k < workers; k++ {
+		if errs[k] != nil {
+			return 0, errs[k]
+		}
+		loss += losses[k]
+	}
+
+	// all-reduce: every parameter moves by the weighted mean of the
+	// replicas' changes
+	for i, param := range trainer.params {
+		if param.Int8 != nil {
+			// int8 parameters aren't trained
+			continue
+		}
+		r, c := param.Value.Dims()
+		total, delta := mat.NewDense(r, c, nil), mat.NewDense(r, c, nil)
+		for k := 0; k < workers; k++ {
+			start, end := k*rows/workers, (k+1)*rows/workers
+			delta.Sub(trainer.shards[k][i].Value, param.Value)
+			delta.Scale(float64(end-start)/float64(rows), delta)
+			total.Add(total, delta)
+		}
+		param.Value.Add(param.Value, total)
+	}
+	return loss / float64(rows), nil
+}
+
+/*
+	Hogwild training (Niu et al., 2011) runs whole batches on replicas that
+	share the network's parameter storage. Every replica updates the shared
+	weights in Backward without any locking, so updates can overwrite each
+	other and a replica may read weights while another writes them. For
+	sparse models, where most updates touch different weights, this rarely
+	matters and avoids all synchronization.
+*/
+
+// shareParams makes dst use the storage of src.
+func shareParams(dst, src []layer.Param) {
+	for i := range dst {
+		if dst[i].Int8 != nil {
+			dst[i].Int8.Data = src[i].Int8.Data
+			continue
+		}
+		dst[i].Value.SetRawMatrix(src[i].Value.RawMatrix())
+	}
+}
+
+// hogwildEpoch trains Workers replicas sharing the network's parameters on
+// the batches, and returns the summed loss and the number of samples.
+func (network *Network) hogwildEpoch(ctx context.Context, batches *data.Batches, rate float64) (float64, int, error) {
+	params := network.Params()
+	replicas := make([]*Network, network.Workers)
+	for k := range replicas {
+		replica, err := network.replicate()
+		if err != nil {
+			return 0, 0, err
+		}
+		shareParams(replica.Params(), params)
+		replicas[k] = replica
+	}
+
+	var (
+		mu        sync.Mutex
+		loss      float64
+		samples   int
+		first_err error
+	)
+	queue := make(chan data.Batch)
+	var wg sync.WaitGroup
+	for _, replica := range replicas {
+		wg.Add(1)
+		go func(replica *Network) {
+			defer wg.Done()
+			for batch := range queue {
+				result, err := replica.forward(batch.Inputs)
+				if err == nil {
+					batch_loss := replica.Loss(batch.Targets, result)
+					replica.BackProp(replica.LossPrime(batch.Targets, result), rate)
+
+					mu.Lock()
+					loss += batch_loss * float64(batch.Size)
+					samples += batch.Size
+					mu.Unlock()
+					continue
+				}
+				mu.Lock()
+				if first_err == nil {
+					first_err = err
+				}
+				mu.Unlock()
+			}
+		}(replica)
+	}
+
+	for {
+		mu.Lock()
+		failed := first_err != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			first_err = err
+			mu.Unlock()
+			break
+		}
+		batch, err := batches.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			mu.Lock()
+			first_err = err
+			mu.Unlock()
+			break
+		}
+		queue <- batch
+	}
+	close(queue)
+	wg.Wait()
+	return loss, samples, first_err
+}
@@ -0,0 +1,332 @@
+// Package npy reads and writes NumPy .npy and .npz files, so weights can
+// move between goNN and Python.
+package npy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+/*
+	An .npy file is:
+
+	offset  size  field
+	0       6     magic "\x93NUMPY"
+	6       2     format version, major and minor
+	8       2/4   header length, uint16 in version 1, uint32 in 2 and 3
+	...           header: a Python dict literal such as
+	              {'descr': '<f8', 'fortran_order': False, 'shape': (3, 4), }
+	              padded with spaces and a newline
+	...           the array data, in C (row major) or Fortran order
+*/
+
+const magic = "\x93NUMPY"
+
+var (
+	descrPattern   = regexp.MustCompile(`['"]descr['"]\s*:\s*['"]([^'"]*)['"]`)
+	fortranPattern = regexp.MustCompile(`['"]fortran_order['"]\s*:\s*(True|False)`)
+	shapePattern   = regexp.MustCompile(`['"]shape['"]\s*:\s*\(([^)]*)\)`)
+)
+
+type header struct {
+	order   binary.ByteOrder
+	kind    byte // 'f', 'i', 'u' or 'b'
+	size    int
+	fortran bool
+	shape   []int
+}
+
+func parseHeader(text string) (header, error) {
+	var h header
+
+	descr := descrPattern.FindStringSubmatch(text)
+	if descr == nil || len(descr[1]) < 3 {
+		return h, errors.New("npy: header has no dtype")
+	}
+	switch descr[1][0] {
+	case '<', '|', '=':
+		h.order = binary.LittleEndian
+	case '>':
+		h.order = binary.BigEndian
+	default:
+		return h, fmt.Errorf("npy: unsupported dtype %q", descr[1])
+	}
+	h.kind = descr[1][1]
+	size, err := strconv.Atoi(descr[1][2:])
+	if err != nil {
+		return h, fmt.Errorf("npy: unsupported dtype %q", descr[1])
+	}
+	h.size = size
+	switch {
+	case h.kind == 'f' && (size == 4 || size == 8),
+		(h.kind == 'i' || h.kind == 'u') && (size == 1 || size == 2 || size == 4 || size == 8),
+		h.kind == 'b' && size == 1:
+	default:
+		return h, fmt.Errorf("npy: unsupported dtype %q", descr[1])
+	}
+
+	fortran := fortranPattern.FindStringSubmatch(text)
+	if fortran == nil {
+		return h, errors.New("npy: header has no fortran_order")
+	}
+	h.fortran = fortran[1] == "True"
+
+	shape := shapePattern.FindStringSubmatch(text)
+	if shape == nil {
+		return h, errors.New("npy: header has no shape")
+	}
+	for _, dim := range strings.Split(shape[1], ",") {
+		dim = strings.TrimSpace(dim)
+		if dim == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(dim, "L"))
+		if err != nil || n < 0 {
+			return h, fmt.Errorf("npy: invalid shape (%s)", shape[1])
+		}
+		h.shape = append(h.shape, n)
+	}
+	return h, nil
+}
+
+// value decodes one element of the header's dtype.
+func (h header) value(b []byte) float64 {
+	switch h.kind {
+	case 'f':
+		if h.size == 4 {
+			return float64(math.Float32frombits(h.order.Uint32(b)))
+		}
+		return math.Float64frombits(h.order.Uint64(b))
+	case 'i':
+		switch h.size {
+		case 1:
+			return float64(int8(b[0]))
+		case 2:
+			return float64(int16(h.order.Uint16(b)))
+		case 4:
+			return float64(int32(h.order.Uint32(b)))
+		}
+		return float64(int64(h.order.Uint64(b)))
+	}
+	switch h.size {
+	case 1:
+		return float64(b[0])
+	case 2:
+		return float64(h.order.Uint16(b))
+	case 4:
+		return float64(h.order.Uint32(b))
+	}
+	return float64(h.order.Uint64(b))
+}
+
+// Read reads an .npy array of up to two dimensions. Scalars become a 1x1
+// matrix and vectors a single row, which is how goNN stores biases.
+// Integer and boolean arrays are converted to float64.
+func Read(r io.Reader) (*mat.Dense, error) {
+	return readMatrix(r, readerSize(r))
+}
+
+// readMatrix reads the array of Read from r, which holds size bytes, see
+// read.
+func readMatrix(r io.Reader, size int64) (*mat.Dense, error) {
+	t, err := read(r, size)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Rank() {
+	case 0:
+		t = t.Reshape(1, 1)
+	case 1:
+		t = t.Reshape(1, t.Dim(0))
+	case 2:
+	default:
+		return nil, fmt.Errorf("npy: arrays with %d dimensions aren't supported", t.Rank())
+	}
+	if t.Size() == 0 {
+		return nil, errors.New("npy: empty arrays aren't supported")
+	}
+	return t.Dense()
+}
+
+// ReadTensor reads an .npy array of any shape, see Read.
+func ReadTensor(r io.Reader) (*tensor.Tensor, error) {
+	return read(r, readerSize(r))
+}
+
+// readerSize returns the number of bytes left in r, or -1 if unknown.
+// Seekers, like files, are measured by seeking to their end and back.
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case io.Seeker:
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if _, err2 := r.Seek(offset, io.SeekStart); err != nil || err2 != nil {
+			return -1
+		}
+		return end - offset
+	}
+	return -1
+}
+
+// readChunk is the most data read at once when the size of the reader is
+// unknown.
+const readChunk = 1 << 20
+
+// readData reads n bytes from r. Its buffer grows as the data arrives, so
+// a header claiming more data than r holds fails without allocating it.
+func readData(r io.Reader, n int) ([]byte, error) {
+	data := make([]byte, 0, min(n, readChunk))
+	for len(data) < n {
+		step := min(n-len(data), readChunk)
+		data = slices.Grow(data, step)
+		read, err := io.ReadFull(r, data[len(data):len(data)+step])
+		data = data[:len(data)+read]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// read reads an .npy array from r, which holds size bytes, or an unknown
+// number when size is negative. The shape in the header is checked
+// against size before any data is allocated for it, and when size is
+// unknown the data is read in bounded chunks.
+func read(r io.Reader, size int64) (*tensor.Tensor, error) {
+	br := bufio.NewReader(r)
+	prefix := make([]byte, 8)
+	if _, err := io.ReadFull(br, prefix); err != nil {
+		return nil, errors.New("npy: file is truncated")
+	}
+	if string(prefix[:6]) != magic {
+		return nil, errors.New("npy: not an .npy file")
+	}
+
+	var length int
+	switch major := prefix[6]; major {
+	case 1:
+		var n uint16
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, errors.New("npy: file is truncated")
+		}
+		length = int(n)
+		size -= 10
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, errors.New("npy: file is truncated")
+		}
+		length = int(n)
+		size -= 12
+	default:
+		return nil, fmt.Errorf("npy: unsupported format version %d.%d", major, prefix[7])
+	}
+
+	if size >= 0 && int64(length) > size {
+		return nil, errors.New("npy: file is truncated")
+	}
+	size -= int64(length)
+	text := make([]byte, length)
+	if _, err := io.ReadFull(br, text); err != nil {
+		return nil, errors.New("npy: file is truncated")
+	}
+	h, err := parseHeader(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	count := 1
+	for _, dim := range h.shape {
+		if dim != 0 && count > math.MaxInt/dim {
+			return nil, errors.New("npy: array shape is too large")
+		}
+		count *= dim
+	}
+	if count > math.MaxInt/h.size {
+		return nil, errors.New("npy: array shape is too large")
+	}
+	if size >= 0 && int64(count*h.size) > size {
+		return nil, errors.New("npy: file is truncated")
+	}
+
+	data, err := readData(br, count*h.size)
+	if err != nil {
+		return nil, errors.New("npy: file is truncated")
+	}
+	values := make([]float64, count)
+	for k := range values {
+		values[k] = h.value(data[k*h.size:])
+	}
+	if !h.fortran {
+		return tensor.FromData(values, h.shape...), nil
+	}
+	// Fortran order is the row-major order of the reversed shape
+	reversed := slices.Clone(h.shape)
+	slices.Reverse(reversed)
+	return tensor.FromData(values, reversed...).Transpose().Clone(), nil
+}
+
+// Write writes m as a little endian float64 .npy array of shape (rows, cols).
+func Write(w io.Writer, m mat.Matrix) error {
+	rows, cols := m.Dims()
+	values := make([]float64, 0, rows*cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			values = append(values, m.At(i, j))
+		}
+	}
+	return write(w, []int{rows, cols}, values)
+}
+
+// WriteTensor writes t as a little endian float64 .npy array of its shape.
+func WriteTensor(w io.Writer, t *tensor.Tensor) error {
+	return write(w, t.Shape(), t.Values())
+}
+
+// write writes the values, in row-major order, as an array of shape.
+func write(w io.Writer, shape []int, values []float64) error {
+	dims := make([]string, len(shape))
+	for i, dim := range shape {
+		dims[i] = strconv.Itoa(dim)
+	}
+	tuple := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		// a Python tuple of one element needs its comma
+		tuple += ","
+	}
+	text := fmt.Sprintf("{'descr': '<f8', 'fortran_order': False, 'shape': (%s), }", tuple)
+	// NumPy pads the header so the data starts at a multiple of 64 bytes.
+	padding := 63 - (len(magic)+4+len(text))%64
+	text += strings.Repeat(" ", padding) + "\n"
+
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(text)))
+	buf.WriteString(text)
+	data := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+	buf.Write(data)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,174 @@
+package npy
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// An .npz file is a zip archive with one .npy file per array, as written by
+// numpy.savez and numpy.savez_compressed.
+
+// ReadNPZ reads every array of an .npz archive, keyed by name without the
+// .npy extension.
+func ReadNPZ(r io.ReaderAt, size int64) (map[string]*mat.Dense, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("npy: not an .npz file: %w", err)
+	}
+
+	arrays := make(map[string]*mat.Dense, len(archive.File))
+	for _, file := range archive.File {
+		name, ok := strings.CutSuffix(file.Name, ".npy")
+		if !ok {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		arrays[name], err = readMatrix(f, int64(min(file.UncompressedSize64, math.MaxInt64)))
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+	return arrays, nil
+}
+
+func LoadNPZ(fpath string) (map[string]*mat.Dense, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ReadNPZ(f, info.Size())
+}
+
+// WriteNPZ writes the arrays as an uncompressed .npz archive, in name order.
+func WriteNPZ(w io.Writer, arrays map[string]*mat.Dense) error {
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	archive := zip.NewWriter(w)
+	for _, name := range names {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err := Write(f, arrays[name]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ExportNetwork writes the parameters of the network as an .npz archive,
+// named like Network.Params, e.g. "0.weights" and "0.biases". int8
+// parameters are written as float64 arrays of their values.
+func ExportNetwork(w io.Writer, net *network.Network) error {
+	params := net.Params()
+	arrays := make(map[string]*mat.Dense, len(params))
+	for _, param := range params {
+		arrays[param.Name] = param.Value
+		if param.Int8 != nil {
+			arrays[param.Name] = param.Int8.Dense()
+		}
+	}
+	return WriteNPZ(w, arrays)
+}
+
+// LoadWeights copies arrays into the network's parameters.
+//
+// mapping maps goNN parameter names ("0.weights", see Network.Params) to
+// array names. Parameters not in mapping use their own name, so a nil
+// mapping loads an archive written by ExportNetwork. A ".T" suffix
+// transposes the array, for frameworks that store Dense weights as
+// (outputs, inputs), e.g. "fc1.weight.T" for PyTorch. Mapping a parameter
+// to "" skips it.
+//
+// Every parameter has to be found and have the right shape, otherwise
+// nothing is changed and an error is returned. Pruning masks are the
+// exception: a mask without an array keeps its current value.
+func LoadWeights(net *network.Network, arrays map[string]*mat.Dense, mapping map[string]string) error {
+	if err := loadParams(net.Params(), arrays, mapping); err != nil {
+		return err
+	}
+	net.ResetFloat32()
+	return nil
+}
+
+func loadParams(params []layer.Param, arrays map[string]*mat.Dense, mapping map[string]string) error {
+	values := make([]mat.Matrix, len(params))
+	int8s := make([]*layer.Int8Matrix, len(params)) // int8 parameters, converted
+	missing := make([]string, 0)
+	for i, param := range params {
+		name, ok := mapping[param.Name]
+		if !ok {
+			name = param.Name
+		} else if name == "" {
+			continue
+		}
+
+		var value mat.Matrix
+		if array, ok := arrays[name]; ok {
+			value = array
+		} else if base, ok := strings.CutSuffix(name, ".T"); ok && arrays[base] != nil {
+			value = arrays[base].T()
+		} else if param.Name == "mask" || strings.HasSuffix(param.Name, ".mask") {
+			continue
+		} else {
+			missing = append(missing, fmt.Sprintf("%s (%s)", param.Name, name))
+			continue
+		}
+
+		var rows, cols int
+		if param.Int8 != nil {
+			rows, cols = param.Int8.Dims()
+		} else {
+			rows, cols = param.Value.Dims()
+		}
+		value_rows, value_cols := value.Dims()
+		if rows != value_rows || cols != value_cols {
+			return fmt.Errorf("npy: %s has shape %dx%d, but array %s has shape %dx%d",
+				param.Name, rows, cols, name, value_rows, value_cols)
+		}
+		if param.Int8 != nil {
+			int8s[i] = layer.NewInt8Matrix(rows, cols, nil)
+			if err := int8s[i].CopyFrom(value); err != nil {
+				return fmt.Errorf("npy: %s: %w", param.Name, err)
+			}
+		}
+		values[i] = value
+	}
+	if len(missing) > 0 {
+		return errors.New("npy: no array for " + strings.Join(missing, ", "))
+	}
+
+	for i, param := range params {
+		switch {
+		case int8s[i] != nil:
+			copy(param.Int8.Data, int8s[i].Data)
+		case values[i] != nil:
+			param.Value.Copy(values[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,480 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/*
+	The subset of onnx.proto goNN reads and writes. Field numbers are the
+	ones from the ONNX spec, fields not listed here are skipped when
+	decoding and never written.
+*/
+
+// Tensor element types.
+const (
+	TensorFloat  = 1
+	TensorDouble = 11
+)
+
+// Attribute types.
+const (
+	AttributeFloat  = 1
+	AttributeInt    = 2
+	AttributeString = 3
+	AttributeInts   = 7
+)
+
+type ModelProto struct {
+	IRVersion       int64
+	ProducerName    string
+	ProducerVersion string
+	OpsetImport     []OperatorSetID
+	Graph           GraphProto
+}
+
+type OperatorSetID struct {
+	Domain  string
+	Version int64
+}
+
+type GraphProto struct {
+	Name        string
+	Node        []NodeProto
+	Initializer []TensorProto
+	Input       []ValueInfoProto
+	Output      []ValueInfoProto
+}
+
+type NodeProto struct {
+	Name      string
+	OpType    string
+	Domain    string
+	Input     []string
+	Output    []string
+	Attribute []AttributeProto
+}
+
+type AttributeProto struct {
+	Name string
+	Type int32
+	F    float32
+	I    int64
+	S    []byte
+	Ints []int64
+}
+
+type TensorProto struct {
+	Name       string
+	Dims       []int64
+	DataType   int32
+	FloatData  []float32
+	DoubleData []float64
+	RawData    []byte
+}
+
+// ValueInfoProto describes a tensor input or output of a graph.
+type ValueInfoProto struct {
+	Name     string
+	ElemType int32
+	Shape    []Dimension
+}
+
+// Dimension is either a fixed size (Value) or a named, free one (Param).
+type Dimension struct {
+	Value int64
+	Param string
+}
+
+func (m *ModelProto) Marshal() []byte {
+	var w protoWriter
+	m.marshal(&w)
+	return w.buf
+}
+
+func (m *ModelProto) Unmarshal(data []byte) error {
+	*m = ModelProto{}
+	return m.unmarshal(data)
+}
+
+func (m *ModelProto) marshal(w *protoWriter) {
+	w.int64(1, m.IRVersion)
+	w.string(2, m.ProducerName)
+	w.string(3, m.ProducerVersion)
+	w.message(7, m.Graph.marshal)
+	for _, opset := range m.OpsetImport {
+		w.message(8, opset.marshal)
+	}
+}
+
+func (m *ModelProto) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		var err error
+		switch {
+		case num == 1 && wire == wireVarint:
+			var v uint64
+			v, err = r.uvarint()
+			m.IRVersion = int64(v)
+		case num == 2 && wire == wireBytes:
+			m.ProducerName, err = readString(r)
+		case num == 3 && wire == wireBytes:
+			m.ProducerVersion, err = readString(r)
+		case num == 7 && wire == wireBytes:
+			err = readMessage(r, m.Graph.unmarshal)
+		case num == 8 && wire == wireBytes:
+			var opset OperatorSetID
+			err = readMessage(r, opset.unmarshal)
+			m.OpsetImport = append(m.OpsetImport, opset)
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+}
+
+func (o *OperatorSetID) marshal(w *protoWriter) {
+	w.string(1, o.Domain)
+	w.int64(2, o.Version)
+}
+
+func (o *OperatorSetID) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		var err error
+		switch {
+		case num == 1 && wire == wireBytes:
+			o.Domain, err = readString(r)
+		case num == 2 && wire == wireVarint:
+			var v uint64
+			v, err = r.uvarint()
+			o.Version = int64(v)
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+}
+
+func (g *GraphProto) marshal(w *protoWriter) {
+	for i := range g.Node {
+		w.message(1, g.Node[i].marshal)
+	}
+	w.string(2, g.Name)
+	for i := range g.Initializer {
+		w.message(5, g.Initializer[i].marshal)
+	}
+	for i := range g.Input {
+		w.message(11, g.Input[i].marshal)
+	}
+	for i := range g.Output {
+		w.message(12, g.Output[i].marshal)
+	}
+}
+
+func (g *GraphProto) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		var err error
+		switch {
+		case num == 1 && wire == wireBytes:
+			var node NodeProto
+			err = readMessage(r, node.unmarshal)
+			g.Node = append(g.Node, node)
+		case num == 2 && wire == wireBytes:
+			g.Name, err = readString(r)
+		case num == 5 && wire == wireBytes:
+			var tensor TensorProto
+			err = readMessage(r, tensor.unmarshal)
+			g.Initializer = append(g.Initializer, tensor)
+		case (num == 11 || num == 12) && wire == wireBytes:
+			var info ValueInfoProto
+			err = readMessage(r, info.unmarshal)
+			if num == 11 {
+				g.Input = append(g.Input, info)
+			} else {
+				g.Output = append(g.Output, info)
+			}
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+}
+
+func (n *NodeProto) marshal(w *protoWriter) {
+	for _, input := range n.Input {
+		w.bytes(1, []byte(input))
+	}
+	for _, output := range n.Output {
+		w.bytes(2, []byte(output))
+	}
+	w.string(3, n.Name)
+	w.string(4, n.OpType)
+	for i := range n.Attribute {
+		w.message(5, n.Attribute[i].marshal)
+	}
+	w.string(7, n.Domain)
+}
+
+func (n *NodeProto) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		var err error
+		var s string
+		switch {
+		case num == 1 && wire == wireBytes:
+			s, err = readString(r)
+			n.Input = append(n.Input, s)
+		case num == 2 && wire == wireBytes:
+			s, err = readString(r)
+			n.Output = append(n.Output, s)
+		case num == 3 && wire == wireBytes:
+			n.Name, err = readString(r)
+		case num == 4 && wire == wireBytes:
+			n.OpType, err = readString(r)
+		case num == 5 && wire == wireBytes:
+			var attribute AttributeProto
+			err = readMessage(r, attribute.unmarshal)
+			n.Attribute = append(n.Attribute, attribute)
+		case num == 7 && wire == wireBytes:
+			n.Domain, err = readString(r)
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+}
+
+func (a *AttributeProto) marshal(w *protoWriter) {
+	w.string(1, a.Name)
+	switch a.Type {
+	case AttributeFloat:
+		w.fixed32(2, math.Float32bits(a.F))
+	case AttributeInt:
+		w.int64(3, a.I)
+	case AttributeString:
+		w.bytes(4, a.S)
+	case AttributeInts:
+		w.packedInt64(8, a.Ints)
+	}
+	w.int64(20, int64(a.Type))
+}
+
+func (a *AttributeProto) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		var err error
+		var v uint64
+		switch {
+		case num == 1 && wire == wireBytes:
+			a.Name, err = readString(r)
+		case num == 2 && wire == wireFixed32:
+			var bits uint32
+			bits, err = r.fixed32()
+			a.F = math.Float32frombits(bits)
+		case num == 3 && wire == wireVarint:
+			v, err = r.uvarint()
+			a.I = int64(v)
+		case num == 4 && wire == wireBytes:
+			a.S, err = r.bytes()
+		case num == 8 && (wire == wireVarint || wire == wireBytes):
+			a.Ints, err = r.int64s(wire, a.Ints)
+		case num == 20 && wire == wireVarint:
+			v, err = r.uvarint()
+			a.Type = int32(v)
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+}
+
+func (t *TensorProto) marshal(w *protoWriter) {
+	w.packedInt64(1, t.Dims)
+	w.int64(2, int64(t.DataType))
+	w.packedFloat(4, t.FloatData)
+	w.string(8, t.Name)
+	if len(t.RawData) > 0 {
+		w.bytes(9, t.RawData)
+	}
+	w.packedDouble(10, t.DoubleData)
+}
+
+func (t *TensorProto) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		var err error
+		switch {
+		case num == 1 && (wire == wireVarint || wire == wireBytes):
+			t.Dims, err = r.int64s(wire, t.Dims)
+		case num == 2 && wire == wireVarint:
+			var v uint64
+			v, err = r.uvarint()
+			t.DataType = int32(v)
+		case num == 4 && (wire == wireFixed32 || wire == wireBytes):
+			t.FloatData, err = r.floats(wire, t.FloatData)
+		case num == 8 && wire == wireBytes:
+			t.Name, err = readString(r)
+		case num == 9 && wire == wireBytes:
+			t.RawData, err = r.bytes()
+		case num == 10 && (wire == wireFixed64 || wire == wireBytes):
+			t.DoubleData, err = r.doubles(wire, t.DoubleData)
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+}
+
+// Values returns the tensor's elements as float64, whichever of the
+// typed fields or raw_data they are stored in.
+func (t *TensorProto) Values() ([]float64, error) {
+	size := 1
+	for _, dim := range t.Dims {
+		if dim < 0 {
+			return nil, fmt.Errorf("tensor %q has a negative dimension", t.Name)
+		}
+		if dim > 0 && int64(size) > math.MaxInt/dim {
+			return nil, fmt.Errorf("tensor %q is too large", t.Name)
+		}
+		size *= int(dim)
+	}
+
+	// the shape comes from the file, only trust it as far as the data goes
+	values := make([]float64, 0, min(size, len(t.RawData)/4+len(t.FloatData)+len(t.DoubleData)))
+	switch {
+	case t.DataType == TensorFloat && len(t.RawData) > 0:
+		if len(t.RawData) != 4*size {
+			return nil, fmt.Errorf("tensor %q: raw data doesn't match its shape", t.Name)
+		}
+		for i := 0; i < len(t.RawData); i += 4 {
+			values = append(values, float64(math.Float32frombits(binary.LittleEndian.Uint32(t.RawData[i:]))))
+		}
+	case t.DataType == TensorDouble && len(t.RawData) > 0:
+		if len(t.RawData) != 8*size {
+			return nil, fmt.Errorf("tensor %q: raw data doesn't match its shape", t.Name)
+		}
+		for i := 0; i < len(t.RawData); i += 8 {
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(t.RawData[i:])))
+		}
+	case t.DataType == TensorFloat:
+		for _, v := range t.FloatData {
+			values = append(values, float64(v))
+		}
+	case t.DataType == TensorDouble:
+		values = append(values, t.DoubleData...)
+	default:
+		return nil, fmt.Errorf("tensor %q has unsupported data type %d", t.Name, t.DataType)
+	}
+
+	if len(values) != size {
+		return nil, fmt.Errorf("tensor %q: data doesn't match its shape", t.Name)
+	}
+	return values, nil
+}
+
+func (v *ValueInfoProto) marshal(w *protoWriter) {
+	w.string(1, v.Name)
+	// TypeProto { tensor_type: TypeProto.Tensor { elem_type, shape } }
+	w.message(2, func(w *protoWriter) {
+		w.message(1, func(w *protoWriter) {
+			w.int64(1, int64(v.ElemType))
+			w.message(2, func(w *protoWriter) {
+				for _, dim := range v.Shape {
+					w.message(1, func(w *protoWriter) {
+						if dim.Param != "" {
+							w.string(2, dim.Param)
+						} else {
+							w.int64(1, dim.Value)
+						}
+					})
+				}
+			})
+		})
+	})
+}
+
+func (v *ValueInfoProto) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		switch {
+		case num == 1 && wire == wireBytes:
+			var err error
+			v.Name, err = readString(r)
+			return err
+		case num == 2 && wire == wireBytes:
+			return readMessage(r, func(data []byte) error {
+				return eachField(data, func(r *protoReader, num, wire int) error {
+					if num != 1 || wire != wireBytes {
+						return r.skip(wire)
+					}
+					return readMessage(r, v.unmarshalTensorType)
+				})
+			})
+		}
+		return r.skip(wire)
+	})
+}
+
+func (v *ValueInfoProto) unmarshalTensorType(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		switch {
+		case num == 1 && wire == wireVarint:
+			elem_type, err := r.uvarint()
+			v.ElemType = int32(elem_type)
+			return err
+		case num == 2 && wire == wireBytes:
+			return readMessage(r, func(data []byte) error {
+				return eachField(data, func(r *protoReader, num, wire int) error {
+					if num != 1 || wire != wireBytes {
+						return r.skip(wire)
+					}
+					var dim Dimension
+					err := readMessage(r, dim.unmarshal)
+					v.Shape = append(v.Shape, dim)
+					return err
+				})
+			})
+		}
+		return r.skip(wire)
+	})
+}
+
+func (d *Dimension) unmarshal(data []byte) error {
+	return eachField(data, func(r *protoReader, num, wire int) error {
+		var err error
+		switch {
+		case num == 1 && wire == wireVarint:
+			var value uint64
+			value, err = r.uvarint()
+			d.Value = int64(value)
+		case num == 2 && wire == wireBytes:
+			d.Param, err = readString(r)
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+}
+
+func eachField(data []byte, field func(r *protoReader, num, wire int) error) error {
+	r := protoReader{data}
+	for !r.done() {
+		num, wire, err := r.next()
+		if err != nil {
+			return err
+		}
+		if err := field(&r, num, wire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readString(r *protoReader) (string, error) {
+	b, err := r.bytes()
+	return string(b), err
+}
+
+func readMessage(r *protoReader, unmarshal func([]byte) error) error {
+	b, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	return unmarshal(b)
+}
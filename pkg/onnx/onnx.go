@@ -0,0 +1,614 @@
+// Package onnx converts goNN models to and from ONNX.
+//
+// Layers map to ONNX operators as follows:
+//
+//	Dense           Gemm (MatMul without a bias on import)
+//	SparseDense     Gemm with the dense weights, imported as Dense
+//	QuantizedDense  Gemm with the dequantized weights, imported as Dense
+//	Tanh            Tanh
+//	Sequential      its layers, flattened
+//	Add             Add, or Sum for more than two inputs
+//	Multiply        Mul
+//	Concat          Concat along axis 1
+//	Matrix          the layer it wraps
+//
+// Values are rows of a batch, so every tensor in the exported graph has
+// the shape [N, features]. Imported models accept inputs of any rank,
+// their last dimension being the features, see layer.Layer.
+package onnx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	irVersion    = 7
+	opsetVersion = 13
+)
+
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	float32 bool
+}
+
+// Float32 exports weights and values as float instead of double. Most
+// runtimes only implement float kernels, but the weights lose precision.
+func Float32() ExportOption {
+	return func(options *exportOptions) { options.float32 = true }
+}
+
+// exportFunc adds the nodes computing l from the named inputs and returns
+// the name of the value holding the result.
+type exportFunc func(e *exporter, l any, inputs []string) (string, error)
+
+// importFunc converts one ONNX node. inputs are the graph nodes of its
+// non-constant inputs, in order.
+type importFunc func(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error)
+
+// Keyed by the layer's registry name. Filled in init since
+// exportSequential refers back to the table.
+var exporters map[string]exportFunc
+
+func init() {
+	exporters = map[string]exportFunc{
+		"Dense":          exportDense,
+		"SparseDense":    exportSparseDense,
+		"QuantizedDense": exportQuantizedDense,
+		"Tanh":           exportTanh,
+		"Sequential":     exportSequential,
+		"Add":            exportAdd,
+		"Multiply":       exportMultiply,
+		"Concat":         exportConcat,
+		"Matrix":         exportMatrix,
+	}
+}
+
+// Keyed by ONNX op type.
+var importers = map[string]importFunc{
+	"Gemm":     importGemm,
+	"MatMul":   importMatMul,
+	"Tanh":     importTanh,
+	"Add":      importAdd,
+	"Sum":      importAdd,
+	"Mul":      importMul,
+	"Concat":   importConcat,
+	"Identity": importIdentity,
+}
+
+// Export writes the network as an ONNX model with one input and one output.
+func Export(w io.Writer, net *network.Network, opts ...ExportOption) error {
+	if net.Preprocess != nil && len(net.Preprocess.Steps) > 0 {
+		return errors.New("onnx: preprocessing pipelines can't be exported, apply them before the exported model")
+	}
+	input := network.Input()
+	node := input
+	for _, l := range net.Layers {
+		node = network.Apply(l, node)
+	}
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{node})
+	if err != nil {
+		return err
+	}
+	return ExportGraph(w, graph, opts...)
+}
+
+func ExportGraph(w io.Writer, graph *network.Graph, opts ...ExportOption) error {
+	var options exportOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	e := &exporter{
+		options: options,
+		counts:  make(map[string]int),
+	}
+	e.graph.Name = "goNN"
+
+	values := make(map[*network.Node]string)
+	widths := make(map[*network.Node]int)
+	for i, node := range graph.Inputs {
+		values[node] = fmt.Sprintf("input_%d", i)
+	}
+
+	for _, node := range graph.Nodes() {
+		if node.Layer == nil && node.Merge == nil {
+			continue
+		}
+
+		inputs := make([]string, len(node.Inputs))
+		insizes := make([]int, len(node.Inputs))
+		for i, parent := range node.Inputs {
+			inputs[i] = values[parent]
+			insizes[i] = widths[parent]
+		}
+
+		var l any = node.Merge
+		if node.Layer != nil {
+			l = node.Layer
+			insize, outsize := layer.Sizes(node.Layer)
+			if parent := node.Inputs[0]; widths[parent] == 0 {
+				widths[parent] = insize
+			}
+			widths[node] = outsize
+		} else if shaped, ok := node.Merge.(layer.MergeShaped); ok {
+			widths[node], _ = shaped.MergedSize(insizes)
+		}
+
+		output, err := e.layer(l, inputs)
+		if err != nil {
+			return err
+		}
+		values[node] = output
+	}
+
+	for _, node := range graph.Inputs {
+		e.graph.Input = append(e.graph.Input, e.valueInfo(values[node], widths[node]))
+	}
+	for _, node := range graph.Outputs {
+		e.graph.Output = append(e.graph.Output, e.valueInfo(values[node], widths[node]))
+	}
+
+	model := ModelProto{
+		IRVersion:       irVersion,
+		ProducerName:    "goNN",
+		ProducerVersion: network.Version,
+		OpsetImport:     []OperatorSetID{{Version: opsetVersion}},
+		Graph:           e.graph,
+	}
+	_, err := w.Write(model.Marshal())
+	return err
+}
+
+type exporter struct {
+	options exportOptions
+	graph   GraphProto
+	counts  map[string]int
+}
+
+func (e *exporter) layer(l any, inputs []string) (string, error) {
+	name, err := layer.NameOf(l)
+	if err != nil {
+		return "", err
+	}
+	export, ok := exporters[name]
+	if !ok {
+		return "", fmt.Errorf("onnx: layer type %q has no ONNX mapping", name)
+	}
+	return export(e, l, inputs)
+}
+
+// name returns a fresh name like "dense_0".
+func (e *exporter) name(prefix string) string {
+	n := e.counts[prefix]
+	e.counts[prefix]++
+	return fmt.Sprintf("%s_%d", prefix, n)
+}
+
+func (e *exporter) elemType() int32 {
+	if e.options.float32 {
+		return TensorFloat
+	}
+	return TensorDouble
+}
+
+// node adds a node whose single output has the node's name.
+func (e *exporter) node(prefix string, op string, inputs []string, attributes ...AttributeProto) string {
+	name := e.name(prefix)
+	e.graph.Node = append(e.graph.Node, NodeProto{
+		Name:      name,
+		OpType:    op,
+		Input:     inputs,
+		Output:    []string{name},
+		Attribute: attributes,
+	})
+	return name
+}
+
+func (e *exporter) initializer(name string, value *mat.Dense, dims ...int64) {
+	tensor := TensorProto{Name: name, Dims: dims, DataType: e.elemType()}
+	rows, cols := value.Dims()
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if e.options.float32 {
+				tensor.FloatData = append(tensor.FloatData, float32(value.At(i, j)))
+			} else {
+				tensor.DoubleData = append(tensor.DoubleData, value.At(i, j))
+			}
+		}
+	}
+	e.graph.Initializer = append(e.graph.Initializer, tensor)
+}
+
+// valueInfo describes a [N, width] value, leaving unknown widths free.
+func (e *exporter) valueInfo(name string, width int) ValueInfoProto {
+	features := Dimension{Value: int64(width)}
+	if width == 0 {
+		features = Dimension{Param: name + "_features"}
+	}
+	return ValueInfoProto{
+		Name:     name,
+		ElemType: e.elemType(),
+		Shape:    []Dimension{{Param: "N"}, features},
+	}
+}
+
+// exportSparseDense exports the layer as a Dense one, ONNX sparse tensors
+// aren't widely supported.
+func exportSparseDense(e *exporter, l any, inputs []string) (string, error) {
+	return exportDense(e, l.(*layer.SparseDenseLayer).Dense(), inputs)
+}
+
+// exportQuantizedDense exports the layer as a Dense one with the dequantized
+// weights, which runtimes can quantize again. The input isn't quantized, so
+// the results differ by its rounding.
+func exportQuantizedDense(e *exporter, l any, inputs []string) (string, error) {
+	return exportDense(e, l.(*layer.QuantizedDenseLayer).Dense(), inputs)
+}
+
+func exportDense(e *exporter, l any, inputs []string) (string, error) {
+	dense := l.(*layer.DenseLayer)
+	rows, cols := dense.Weights.Dims()
+	name := e.name("dense")
+	e.initializer(name+".weights", dense.Weights, int64(rows), int64(cols))
+	e.initializer(name+".biases", dense.Biases, int64(cols))
+	e.graph.Node = append(e.graph.Node, NodeProto{
+		Name:   name,
+		OpType: "Gemm",
+		Input:  []string{inputs[0], name + ".weights", name + ".biases"},
+		Output: []string{name},
+	})
+	return name, nil
+}
+
+func exportTanh(e *exporter, l any, inputs []string) (string, error) {
+	return e.node("tanh", "Tanh", inputs), nil
+}
+
+func exportSequential(e *exporter, l any, inputs []string) (string, error) {
+	value := inputs[0]
+	for _, child := range l.(*layer.SequentialLayer).Layers {
+		var err error
+		if value, err = e.layer(child, []string{value}); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+func exportAdd(e *exporter, l any, inputs []string) (string, error) {
+	if len(inputs) == 2 {
+		return e.node("add", "Add", inputs), nil
+	}
+	return e.node("sum", "Sum", inputs), nil
+}
+
+func exportMultiply(e *exporter, l any, inputs []string) (string, error) {
+	// Mul is binary, more inputs are multiplied one by one.
+	value := inputs[0]
+	for _, input := range inputs[1:] {
+		value = e.node("mul", "Mul", []string{value, input})
+	}
+	if len(inputs) == 1 {
+		value = e.node("identity", "Identity", inputs)
+	}
+	return value, nil
+}
+
+func exportConcat(e *exporter, l any, inputs []string) (string, error) {
+	axis := AttributeProto{Name: "axis", Type: AttributeInt, I: 1}
+	return e.node("concat", "Concat", inputs, axis), nil
+}
+
+func exportMatrix(e *exporter, l any, inputs []string) (string, error) {
+	return e.layer(l.(*layer.MatrixAdapter).Layer, inputs)
+}
+
+// Import reads an ONNX model made of a single chain of supported ops.
+func Import(r io.Reader) (*network.Network, error) {
+	graph, err := ImportGraph(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(graph.Inputs) != 1 || len(graph.Outputs) != 1 {
+		return nil, errors.New("onnx: model isn't a single chain of layers, use ImportGraph")
+	}
+
+	layers := make([]layer.Layer, 0)
+	for node := graph.Outputs[0]; node != graph.Inputs[0]; node = node.Inputs[0] {
+		if node.Layer == nil {
+			return nil, errors.New("onnx: model isn't a single chain of layers, use ImportGraph")
+		}
+		layers = append([]layer.Layer{node.Layer}, layers...)
+	}
+	return &network.Network{Layers: layers}, nil
+}
+
+// ImportGraph reads an ONNX model that only uses the supported ops.
+func ImportGraph(r io.Reader) (*network.Graph, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var model ModelProto
+	if err := model.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	for _, opset := range model.OpsetImport {
+		if opset.Domain != "" && opset.Domain != "ai.onnx" {
+			return nil, fmt.Errorf("onnx: unsupported operator set %q", opset.Domain)
+		}
+	}
+
+	im := &importer{
+		constants: make(map[string]*TensorProto),
+		values:    make(map[string]*network.Node),
+		widths:    make(map[*network.Node]int),
+	}
+	for i := range model.Graph.Initializer {
+		tensor := &model.Graph.Initializer[i]
+		im.constants[tensor.Name] = tensor
+	}
+
+	inputs := make([]*network.Node, 0)
+	for _, info := range model.Graph.Input {
+		// Older exporters also list the initializers as inputs.
+		if _, ok := im.constants[info.Name]; ok {
+			continue
+		}
+		if info.ElemType != TensorFloat && info.ElemType != TensorDouble {
+			return nil, fmt.Errorf("onnx: input %q has unsupported element type %d", info.Name, info.ElemType)
+		}
+		node := network.Input()
+		if len(info.Shape) >= 2 {
+			im.widths[node] = int(info.Shape[len(info.Shape)-1].Value)
+		}
+		im.values[info.Name] = node
+		inputs = append(inputs, node)
+	}
+
+	for i := range model.Graph.Node {
+		node := &model.Graph.Node[i]
+		if node.Domain != "" && node.Domain != "ai.onnx" {
+			return nil, fmt.Errorf("onnx: node %q uses unsupported domain %q", node.Name, node.Domain)
+		}
+		convert, ok := importers[node.OpType]
+		if !ok {
+			return nil, fmt.Errorf("onnx: unsupported op %q", node.OpType)
+		}
+		if len(node.Output) != 1 {
+			return nil, fmt.Errorf("onnx: %s node %q must have exactly one output", node.OpType, node.Name)
+		}
+
+		parents := make([]*network.Node, 0, len(node.Input))
+		for _, name := range node.Input {
+			if _, ok := im.constants[name]; ok || name == "" {
+				continue
+			}
+			parent, ok := im.values[name]
+			if !ok {
+				return nil, fmt.Errorf("onnx: node %q uses undefined value %q", node.Name, name)
+			}
+			parents = append(parents, parent)
+		}
+
+		converted, err := convert(im, node, parents)
+		if err != nil {
+			return nil, fmt.Errorf("onnx: %s node %q: %w", node.OpType, node.Name, err)
+		}
+		im.setWidth(converted)
+		im.values[node.Output[0]] = converted
+	}
+
+	outputs := make([]*network.Node, len(model.Graph.Output))
+	for i, info := range model.Graph.Output {
+		node, ok := im.values[info.Name]
+		if !ok {
+			return nil, fmt.Errorf("onnx: output %q isn't computed by the graph", info.Name)
+		}
+		outputs[i] = node
+	}
+
+	return network.NewGraph(inputs, outputs)
+}
+
+type importer struct {
+	constants map[string]*TensorProto
+	values    map[string]*network.Node
+	widths    map[*network.Node]int
+}
+
+func (im *importer) setWidth(node *network.Node) {
+	if node.Layer != nil {
+		_, im.widths[node] = layer.Sizes(node.Layer)
+		return
+	}
+	if shaped, ok := node.Merge.(layer.MergeShaped); ok {
+		insizes := make([]int, len(node.Inputs))
+		for i, parent := range node.Inputs {
+			insizes[i] = im.widths[parent]
+		}
+		im.widths[node], _ = shaped.MergedSize(insizes)
+	}
+}
+
+// constant returns the initializer name as a matrix, 1-D tensors become
+// a single row.
+func (im *importer) constant(name string) (*mat.Dense, error) {
+	tensor, ok := im.constants[name]
+	if !ok {
+		return nil, fmt.Errorf("%q must be an initializer", name)
+	}
+	values, err := tensor.Values()
+	if err != nil {
+		return nil, err
+	}
+	for _, dim := range tensor.Dims {
+		if dim == 0 {
+			return nil, fmt.Errorf("initializer %q has a zero dimension", name)
+		}
+	}
+	switch len(tensor.Dims) {
+	case 1:
+		return mat.NewDense(1, len(values), values), nil
+	case 2:
+		return mat.NewDense(int(tensor.Dims[0]), int(tensor.Dims[1]), values), nil
+	}
+	return nil, fmt.Errorf("initializer %q must have 1 or 2 dimensions, it has %d", name, len(tensor.Dims))
+}
+
+func attribute(node *NodeProto, name string) *AttributeProto {
+	for i := range node.Attribute {
+		if node.Attribute[i].Name == name {
+			return &node.Attribute[i]
+		}
+	}
+	return nil
+}
+
+func intAttribute(node *NodeProto, name string, fallback int64) int64 {
+	if a := attribute(node, name); a != nil {
+		return a.I
+	}
+	return fallback
+}
+
+func floatAttribute(node *NodeProto, name string, fallback float64) float64 {
+	if a := attribute(node, name); a != nil {
+		return float64(a.F)
+	}
+	return fallback
+}
+
+func single(inputs []*network.Node) error {
+	if len(inputs) != 1 {
+		return fmt.Errorf("expected one non-constant input, got %d", len(inputs))
+	}
+	return nil
+}
+
+// dense builds a Dense layer with the given weights and biases, both
+// copied. A nil bias is all zeros.
+func dense(weights *mat.Dense, biases *mat.Dense) *layer.DenseLayer {
+	rows, cols := weights.Dims()
+	l := layer.Dense(rows, cols)
+	l.Weights.Copy(weights)
+	l.Biases.Zero()
+	if biases != nil {
+		l.Biases.Copy(biases)
+	}
+	return l
+}
+
+func importGemm(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error) {
+	if err := single(inputs); err != nil {
+		return nil, err
+	}
+	if len(node.Input) < 2 {
+		return nil, errors.New("missing B input")
+	}
+	if intAttribute(node, "transA", 0) != 0 {
+		return nil, errors.New("transA isn't supported")
+	}
+
+	b, err := im.constant(node.Input[1])
+	if err != nil {
+		return nil, err
+	}
+	weights := mat.DenseCopyOf(b)
+	if intAttribute(node, "transB", 0) != 0 {
+		weights = mat.DenseCopyOf(b.T())
+	}
+	weights.Scale(floatAttribute(node, "alpha", 1), weights)
+	_, cols := weights.Dims()
+
+	var biases *mat.Dense
+	if len(node.Input) > 2 && node.Input[2] != "" {
+		c, err := im.constant(node.Input[2])
+		if err != nil {
+			return nil, err
+		}
+		biases = mat.NewDense(1, cols, nil)
+		switch c_rows, c_cols := c.Dims(); {
+		case c_rows == 1 && c_cols == 1:
+			for j := 0; j < cols; j++ {
+				biases.Set(0, j, c.At(0, 0))
+			}
+		case c_rows == 1 && c_cols == cols:
+			biases.Copy(c)
+		default:
+			return nil, fmt.Errorf("bias of shape %dx%d can't be broadcast to a row of %d", c_rows, c_cols, cols)
+		}
+		biases.Scale(floatAttribute(node, "beta", 1), biases)
+	}
+
+	return network.Apply(dense(weights, biases), inputs[0]), nil
+}
+
+func importMatMul(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error) {
+	if err := single(inputs); err != nil {
+		return nil, err
+	}
+	if len(node.Input) != 2 || im.values[node.Input[0]] == nil {
+		return nil, errors.New("only activations times a constant matrix are supported")
+	}
+	weights, err := im.constant(node.Input[1])
+	if err != nil {
+		return nil, err
+	}
+	return network.Apply(dense(weights, nil), inputs[0]), nil
+}
+
+func importTanh(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error) {
+	if err := single(inputs); err != nil {
+		return nil, err
+	}
+	return network.Apply(layer.Tanh(im.widths[inputs[0]]), inputs[0]), nil
+}
+
+// variadic checks that none of the node's inputs are constants, goNN
+// merges only combine activations.
+func variadic(node *NodeProto, inputs []*network.Node) error {
+	if len(inputs) != len(node.Input) || len(inputs) == 0 {
+		return errors.New("constant inputs aren't supported")
+	}
+	return nil
+}
+
+func importAdd(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error) {
+	if err := variadic(node, inputs); err != nil {
+		return nil, err
+	}
+	return network.Merge(layer.Add(), inputs...), nil
+}
+
+func importMul(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error) {
+	if err := variadic(node, inputs); err != nil {
+		return nil, err
+	}
+	return network.Merge(layer.Multiply(), inputs...), nil
+}
+
+func importConcat(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error) {
+	if err := variadic(node, inputs); err != nil {
+		return nil, err
+	}
+	if axis := intAttribute(node, "axis", math.MinInt64); axis != 1 && axis != -1 {
+		return nil, errors.New("only concatenation along the feature axis is supported")
+	}
+	return network.Merge(layer.Concat(), inputs...), nil
+}
+
+func importIdentity(im *importer, node *NodeProto, inputs []*network.Node) (*network.Node, error) {
+	if err := single(inputs); err != nil {
+		return nil, err
+	}
+	return inputs[0], nil
+}
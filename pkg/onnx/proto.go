@@ -0,0 +1,228 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+/*
+	Just enough of the protobuf wire format to read and write ONNX files.
+
+	Every field is a key (field number << 3 | wire type) followed by its
+	value: a varint, 8 or 4 little endian bytes, or a varint length and that
+	many bytes for strings, nested messages and packed repeated numbers.
+*/
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("onnx: truncated protobuf message")
+
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) key(num int, wire int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(num)<<3|uint64(wire))
+}
+
+func (w *protoWriter) varint(num int, v uint64) {
+	w.key(num, wireVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *protoWriter) int64(num int, v int64) {
+	w.varint(num, uint64(v))
+}
+
+func (w *protoWriter) fixed32(num int, v uint32) {
+	w.key(num, wireFixed32)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, v)
+}
+
+func (w *protoWriter) bytes(num int, b []byte) {
+	w.key(num, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(num int, s string) {
+	if s != "" {
+		w.bytes(num, []byte(s))
+	}
+}
+
+func (w *protoWriter) message(num int, marshal func(*protoWriter)) {
+	var sub protoWriter
+	marshal(&sub)
+	w.bytes(num, sub.buf)
+}
+
+func (w *protoWriter) packedInt64(num int, values []int64) {
+	if len(values) == 0 {
+		return
+	}
+	packed := make([]byte, 0, len(values))
+	for _, v := range values {
+		packed = binary.AppendUvarint(packed, uint64(v))
+	}
+	w.bytes(num, packed)
+}
+
+func (w *protoWriter) packedFloat(num int, values []float32) {
+	if len(values) == 0 {
+		return
+	}
+	packed := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		packed = binary.LittleEndian.AppendUint32(packed, math.Float32bits(v))
+	}
+	w.bytes(num, packed)
+}
+
+func (w *protoWriter) packedDouble(num int, values []float64) {
+	if len(values) == 0 {
+		return
+	}
+	packed := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		packed = binary.LittleEndian.AppendUint64(packed, math.Float64bits(v))
+	}
+	w.bytes(num, packed)
+}
+
+type protoReader struct {
+	data []byte
+}
+
+func (r *protoReader) done() bool {
+	return len(r.data) == 0
+}
+
+func (r *protoReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+// next reads the key of the next field.
+func (r *protoReader) next() (int, int, error) {
+	key, err := r.uvarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(key >> 3), int(key & 7), nil
+}
+
+func (r *protoReader) fixed32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+func (r *protoReader) fixed64() (uint64, error) {
+	if len(r.data) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v, nil
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	length, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(r.data)) {
+		return nil, errTruncated
+	}
+	b := r.data[:length]
+	r.data = r.data[length:]
+	return b, nil
+}
+
+func (r *protoReader) skip(wire int) error {
+	var err error
+	switch wire {
+	case wireVarint:
+		_, err = r.uvarint()
+	case wireFixed64:
+		_, err = r.fixed64()
+	case wireBytes:
+		_, err = r.bytes()
+	case wireFixed32:
+		_, err = r.fixed32()
+	default:
+		err = errors.New("onnx: unsupported protobuf wire type")
+	}
+	return err
+}
+
+// int64s reads a repeated int64 field, which may be packed or not.
+func (r *protoReader) int64s(wire int, values []int64) ([]int64, error) {
+	if wire == wireVarint {
+		v, err := r.uvarint()
+		return append(values, int64(v)), err
+	}
+	packed, err := r.bytes()
+	if err != nil {
+		return values, err
+	}
+	sub := protoReader{packed}
+	for !sub.done() {
+		v, err := sub.uvarint()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, int64(v))
+	}
+	return values, nil
+}
+
+func (r *protoReader) floats(wire int, values []float32) ([]float32, error) {
+	if wire == wireFixed32 {
+		v, err := r.fixed32()
+		return append(values, math.Float32frombits(v)), err
+	}
+	packed, err := r.bytes()
+	if err != nil {
+		return values, err
+	}
+	if len(packed)%4 != 0 {
+		return values, errTruncated
+	}
+	for i := 0; i < len(packed); i += 4 {
+		values = append(values, math.Float32frombits(binary.LittleEndian.Uint32(packed[i:])))
+	}
+	return values, nil
+}
+
+func (r *protoReader) doubles(wire int, values []float64) ([]float64, error) {
+	if wire == wireFixed64 {
+		v, err := r.fixed64()
+		return append(values, math.Float64frombits(v)), err
+	}
+	packed, err := r.bytes()
+	if err != nil {
+		return values, err
+	}
+	if len(packed)%8 != 0 {
+		return values, errTruncated
+	}
+	for i := 0; i < len(packed); i += 8 {
+		values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(packed[i:])))
+	}
+	return values, nil
+}
@@ -0,0 +1,159 @@
+package preprocess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"gonum.org/v1/gonum/mat"
+)
+
+// OneHotEncoder replaces categorical columns, holding numeric category
+// codes, by one column per category in sorted order. The other columns are
+// kept as they are and the encoded ones are appended after them.
+type OneHotEncoder struct {
+	Columns []int
+
+	Features   int
+	Categories [][]float64 // per entry of Columns
+}
+
+func OneHot(columns ...int) *OneHotEncoder {
+	return &OneHotEncoder{Columns: columns}
+}
+
+func (encoder *OneHotEncoder) Fit(x *mat.Dense) error {
+	_, cols := x.Dims()
+	if len(encoder.Columns) == 0 {
+		return errors.New("one hot encoder has no columns")
+	}
+	seen := make(map[int]bool)
+	categories := make([][]float64, len(encoder.Columns))
+	for i, column := range encoder.Columns {
+		if column < 0 || column >= cols {
+			return fmt.Errorf("column %d doesn't exist, the data has %d columns", column, cols)
+		}
+		if seen[column] {
+			return fmt.Errorf("column %d is encoded twice", column)
+		}
+		seen[column] = true
+		values := mat.Col(nil, column, x)
+		slices.Sort(values)
+		categories[i] = slices.Compact(values)
+	}
+	encoder.Features = cols
+	encoder.Categories = categories
+	return nil
+}
+
+func (encoder *OneHotEncoder) Transform(x *mat.Dense) (*mat.Dense, error) {
+	if err := checkInput(x, encoder.Features); err != nil {
+		return nil, err
+	}
+
+	rows, _ := x.Dims()
+	result := mat.NewDense(rows, encoder.OutputSize(), nil)
+	for r := 0; r < rows; r++ {
+		c := 0
+		for j := 0; j < encoder.Features; j++ {
+			if !slices.Contains(encoder.Columns, j) {
+				result.Set(r, c, x.At(r, j))
+				c++
+			}
+		}
+		for i, column := range encoder.Columns {
+			value := x.At(r, column)
+			index, found := slices.BinarySearch(encoder.Categories[i], value)
+			if !found {
+				return nil, fmt.Errorf("row %d: unknown category %v in column %d", r, value, column)
+			}
+			result.Set(r, c+index, 1)
+			c += len(encoder.Categories[i])
+		}
+	}
+	return result, nil
+}
+
+type oneHotConfig struct {
+	Features   int         `json:"features"`
+	Columns    []int       `json:"columns"`
+	Categories [][]float64 `json:"categories"`
+}
+
+func (encoder *OneHotEncoder) MarshalConfig() ([]byte, error) {
+	if encoder.Features == 0 {
+		return nil, errNotFitted
+	}
+	return json.Marshal(oneHotConfig{Features: encoder.Features, Columns: encoder.Columns, Categories: encoder.Categories})
+}
+
+func (encoder *OneHotEncoder) UnmarshalConfig(config []byte) error {
+	var c oneHotConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if c.Features <= 0 || len(c.Columns) == 0 || len(c.Columns) != len(c.Categories) {
+		return errors.New("invalid one hot encoder config")
+	}
+	for i, column := range c.Columns {
+		if column < 0 || column >= c.Features || !slices.IsSorted(c.Categories[i]) {
+			return errors.New("invalid one hot encoder config")
+		}
+	}
+	encoder.Features, encoder.Columns, encoder.Categories = c.Features, c.Columns, c.Categories
+	return nil
+}
+
+// Params is empty, the categories are part of the config.
+func (encoder *OneHotEncoder) Params() []layer.Param { return nil }
+
+func (encoder *OneHotEncoder) InputSize() int { return encoder.Features }
+
+func (encoder *OneHotEncoder) OutputSize() int {
+	if encoder.Features == 0 {
+		return 0
+	}
+	size := encoder.Features - len(encoder.Columns)
+	for _, categories := range encoder.Categories {
+		size += len(categories)
+	}
+	return size
+}
+
+// LabelEncoder maps string labels to the numbers 0 to n-1 and back, e.g.
+// to build targets for a classifier. The classes are sorted.
+type LabelEncoder struct {
+	Classes []string
+}
+
+func (encoder *LabelEncoder) Fit(labels []string) {
+	classes := slices.Clone(labels)
+	slices.Sort(classes)
+	encoder.Classes = slices.Compact(classes)
+}
+
+func (encoder *LabelEncoder) Transform(labels []string) ([]float64, error) {
+	codes := make([]float64, len(labels))
+	for i, label := range labels {
+		index, found := slices.BinarySearch(encoder.Classes, label)
+		if !found {
+			return nil, fmt.Errorf("unknown label %q", label)
+		}
+		codes[i] = float64(index)
+	}
+	return codes, nil
+}
+
+func (encoder *LabelEncoder) Inverse(codes []float64) ([]string, error) {
+	labels := make([]string, len(codes))
+	for i, code := range codes {
+		index := int(code)
+		if float64(index) != code || index < 0 || index >= len(encoder.Classes) {
+			return nil, fmt.Errorf("%v isn't a label code", code)
+		}
+		labels[i] = encoder.Classes[index]
+	}
+	return labels, nil
+}
@@ -0,0 +1,149 @@
+package preprocess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"gonum.org/v1/gonum/mat"
+)
+
+// PCA projects centered data onto its first principal components.
+type PCA struct {
+	Components int
+
+	Mean *mat.Dense // 1 x features
+	// Vectors holds one component per column, features x Components.
+	Vectors *mat.Dense
+	// Variance is the variance of the data along every component.
+	Variance *mat.Dense // 1 x Components
+}
+
+func NewPCA(components int) *PCA {
+	return &PCA{Components: components}
+}
+
+func (pca *PCA) Fit(x *mat.Dense) error {
+	rows, cols := x.Dims()
+	if pca.Components <= 0 || pca.Components > min(rows, cols) {
+		return fmt.Errorf("can't keep %d components of %d samples with %d features", pca.Components, rows, cols)
+	}
+
+	mean := make([]float64, cols)
+	for j := range mean {
+		mean[j] = mat.Sum(x.ColView(j)) / float64(rows)
+	}
+	pca.Mean = mat.NewDense(1, cols, mean)
+
+	var svd mat.SVD
+	if !svd.Factorize(pca.center(x), mat.SVDThin) {
+		pca.Mean = nil
+		return errors.New("pca: svd failed")
+	}
+	var v mat.Dense
+	svd.VTo(&v)
+	values := svd.Values(nil)
+
+	pca.Vectors = mat.DenseCopyOf(v.Slice(0, cols, 0, pca.Components))
+	variance := make([]float64, pca.Components)
+	for k := range variance {
+		variance[k] = values[k] * values[k] / float64(rows)
+		// the sign of a component is arbitrary, make its largest entry
+		// positive so fits are reproducible
+		column := pca.Vectors.ColView(k)
+		largest := 0.0
+		for j := 0; j < cols; j++ {
+			if v := column.AtVec(j); v*v > largest*largest {
+				largest = v
+			}
+		}
+		if largest < 0 {
+			for j := 0; j < cols; j++ {
+				pca.Vectors.Set(j, k, -pca.Vectors.At(j, k))
+			}
+		}
+	}
+	pca.Variance = mat.NewDense(1, pca.Components, variance)
+	return nil
+}
+
+func (pca *PCA) Transform(x *mat.Dense) (*mat.Dense, error) {
+	if err := checkInput(x, width(pca.Mean)); err != nil {
+		return nil, err
+	}
+	var result mat.Dense
+	result.Mul(pca.center(x), pca.Vectors)
+	return &result, nil
+}
+
+func (pca *PCA) center(x *mat.Dense) *mat.Dense {
+	var centered mat.Dense
+	centered.Apply(func(i, j int, v float64) float64 {
+		return v - pca.Mean.At(0, j)
+	}, x)
+	return &centered
+}
+
+type pcaConfig struct {
+	Features   int `json:"features"`
+	Components int `json:"components"`
+}
+
+func (pca *PCA) MarshalConfig() ([]byte, error) {
+	if pca.Mean == nil {
+		return nil, errNotFitted
+	}
+	return json.Marshal(pcaConfig{Features: width(pca.Mean), Components: pca.Components})
+}
+
+// size checks c and returns the number of elements of the fitted PCA.
+func (c pcaConfig) size() (int, error) {
+	if c.Features <= 0 || c.Components <= 0 || c.Components > c.Features {
+		return 0, errors.New("invalid pca config")
+	}
+	if err := layer.CheckSize(c.Features, c.Components); err != nil {
+		return 0, err
+	}
+	return c.Features + c.Features*c.Components + c.Components, nil
+}
+
+func (pca *PCA) ParamSize(config []byte) (int, error) {
+	var c pcaConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return 0, err
+	}
+	return c.size()
+}
+
+func (pca *PCA) UnmarshalConfig(config []byte) error {
+	var c pcaConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if _, err := c.size(); err != nil {
+		return err
+	}
+	pca.Components = c.Components
+	pca.Mean = mat.NewDense(1, c.Features, nil)
+	pca.Vectors = mat.NewDense(c.Features, c.Components, nil)
+	pca.Variance = mat.NewDense(1, c.Components, nil)
+	return nil
+}
+
+func (pca *PCA) Params() []layer.Param {
+	return []layer.Param{
+		{Name: "mean", Value: pca.Mean},
+		{Name: "vectors", Value: pca.Vectors},
+		{Name: "variance", Value: pca.Variance},
+	}
+}
+
+func (pca *PCA) InputSize() int { return width(pca.Mean) }
+
+func (pca *PCA) OutputSize() int {
+	if pca.Mean == nil {
+		return 0
+	}
+	return pca.Components
+}
@@ -0,0 +1,161 @@
+// Package preprocess fits transforms such as scaling or PCA on training
+// data and applies the same transform to new data. Rows are samples and
+// columns are features, as everywhere in goNN.
+//
+// A fitted Pipeline can be set as Network.Preprocess; it is then saved
+// with the model and applied by Predict and Train.
+package preprocess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Transformer learns a transform from data with Fit and applies it with
+// Transform. Transformers are registered in the layer registry, so they
+// are saved the same way layers are.
+type Transformer interface {
+	Fit(x *mat.Dense) error
+	Transform(x *mat.Dense) (*mat.Dense, error)
+	layer.Serializable
+}
+
+func init() {
+	layer.Register("preprocess.StandardScaler", func() layer.Serializable { return &StandardScaler{} })
+	layer.Register("preprocess.MinMaxScaler", func() layer.Serializable { return &MinMaxScaler{} })
+	layer.Register("preprocess.RobustScaler", func() layer.Serializable { return &RobustScaler{} })
+	layer.Register("preprocess.OneHotEncoder", func() layer.Serializable { return &OneHotEncoder{} })
+	layer.Register("preprocess.PCA", func() layer.Serializable { return &PCA{} })
+}
+
+// Pipeline applies its steps one after the other.
+type Pipeline struct {
+	Steps []Transformer
+}
+
+func NewPipeline(steps ...Transformer) *Pipeline {
+	return &Pipeline{Steps: steps}
+}
+
+// Fit fits every step on the output of the steps before it.
+func (pipeline *Pipeline) Fit(x *mat.Dense) error {
+	_, err := pipeline.FitTransform(x)
+	return err
+}
+
+func (pipeline *Pipeline) FitTransform(x *mat.Dense) (*mat.Dense, error) {
+	for i, step := range pipeline.Steps {
+		if err := step.Fit(x); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		var err error
+		if x, err = step.Transform(x); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+	return x, nil
+}
+
+func (pipeline *Pipeline) Transform(x *mat.Dense) (*mat.Dense, error) {
+	for i, step := range pipeline.Steps {
+		var err error
+		if x, err = step.Transform(x); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+	return x, nil
+}
+
+// Stack puts samples of the same width below each other, e.g. to fit a
+// pipeline on the inputs passed to Network.Train.
+func Stack(samples []*mat.Dense) (*mat.Dense, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples to stack")
+	}
+	rows, cols := 0, 0
+	for i, sample := range samples {
+		r, c := sample.Dims()
+		if i > 0 && c != cols {
+			return nil, fmt.Errorf("sample %d has %d columns, sample 0 has %d", i, c, cols)
+		}
+		rows, cols = rows+r, c
+	}
+
+	stacked := mat.NewDense(rows, cols, nil)
+	row := 0
+	for _, sample := range samples {
+		r, _ := sample.Dims()
+		stacked.Slice(row, row+r, 0, cols).(*mat.Dense).Copy(sample)
+		row += r
+	}
+	return stacked, nil
+}
+
+var errNotFitted = errors.New("transformer isn't fitted")
+
+// checkInput makes sure x has as many columns as the fitted transformer.
+func checkInput(x *mat.Dense, features int) error {
+	if features == 0 {
+		return errNotFitted
+	}
+	if _, cols := x.Dims(); cols != features {
+		return fmt.Errorf("expected %d features, got %d", features, cols)
+	}
+	return nil
+}
+
+// width returns the number of columns of a fitted 1 x n row, 0 if nil.
+func width(row *mat.Dense) int {
+	if row == nil {
+		return 0
+	}
+	_, cols := row.Dims()
+	return cols
+}
+
+// scaleColumns returns (x - shift) / scale, column by column.
+func scaleColumns(x *mat.Dense, shift, scale *mat.Dense) *mat.Dense {
+	var result mat.Dense
+	result.Apply(func(i, j int, v float64) float64 {
+		return (v - shift.At(0, j)) / scale.At(0, j)
+	}, x)
+	return &result
+}
+
+// nonZero replaces zero scales, of constant columns, by 1.
+func nonZero(scale []float64) []float64 {
+	for i, s := range scale {
+		if s == 0 {
+			scale[i] = 1
+		}
+	}
+	return scale
+}
+
+type featuresConfig struct {
+	Features int `json:"features"`
+}
+
+// size checks c and returns the number of elements of a scaler's two rows.
+func (c featuresConfig) size() (int, error) {
+	if c.Features <= 0 {
+		return 0, errors.New("scaler needs at least one feature")
+	}
+	if err := layer.CheckSize(1, c.Features); err != nil {
+		return 0, err
+	}
+	return 2 * c.Features, nil
+}
+
+// featuresSize is the ParamSize of scalers configured by a featuresConfig.
+func featuresSize(config []byte) (int, error) {
+	var c featuresConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return 0, err
+	}
+	return c.size()
+}
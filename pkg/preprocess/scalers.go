@@ -0,0 +1,243 @@
+package preprocess
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// StandardScaler shifts every feature to zero mean and unit variance.
+type StandardScaler struct {
+	Mean  *mat.Dense
+	Scale *mat.Dense // standard deviation, 1 for constant features
+}
+
+func Standard() *StandardScaler {
+	return &StandardScaler{}
+}
+
+func (scaler *StandardScaler) Fit(x *mat.Dense) error {
+	_, cols := x.Dims()
+	mean := make([]float64, cols)
+	scale := make([]float64, cols)
+	for j := 0; j < cols; j++ {
+		mean[j], scale[j] = stat.PopMeanStdDev(mat.Col(nil, j, x), nil)
+	}
+	scaler.Mean = mat.NewDense(1, cols, mean)
+	scaler.Scale = mat.NewDense(1, cols, nonZero(scale))
+	return nil
+}
+
+func (scaler *StandardScaler) Transform(x *mat.Dense) (*mat.Dense, error) {
+	if err := checkInput(x, width(scaler.Mean)); err != nil {
+		return nil, err
+	}
+	return scaleColumns(x, scaler.Mean, scaler.Scale), nil
+}
+
+func (scaler *StandardScaler) MarshalConfig() ([]byte, error) {
+	if scaler.Mean == nil {
+		return nil, errNotFitted
+	}
+	return json.Marshal(featuresConfig{Features: width(scaler.Mean)})
+}
+
+func (scaler *StandardScaler) ParamSize(config []byte) (int, error) {
+	return featuresSize(config)
+}
+
+func (scaler *StandardScaler) UnmarshalConfig(config []byte) error {
+	var c featuresConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if _, err := c.size(); err != nil {
+		return err
+	}
+	scaler.Mean = mat.NewDense(1, c.Features, nil)
+	scaler.Scale = mat.NewDense(1, c.Features, nil)
+	return nil
+}
+
+func (scaler *StandardScaler) Params() []layer.Param {
+	return []layer.Param{{Name: "mean", Value: scaler.Mean}, {Name: "scale", Value: scaler.Scale}}
+}
+
+func (scaler *StandardScaler) InputSize() int  { return width(scaler.Mean) }
+func (scaler *StandardScaler) OutputSize() int { return width(scaler.Mean) }
+
+// MinMaxScaler maps every feature linearly so that its smallest training
+// value becomes Min and its largest Max.
+type MinMaxScaler struct {
+	Min, Max float64
+
+	DataMin   *mat.Dense
+	DataRange *mat.Dense // 1 for constant features
+}
+
+// MinMax scales features to [min, max], usually [0, 1] or [-1, 1].
+func MinMax(min, max float64) *MinMaxScaler {
+	return &MinMaxScaler{Min: min, Max: max}
+}
+
+func (scaler *MinMaxScaler) Fit(x *mat.Dense) error {
+	if scaler.Max <= scaler.Min {
+		return errors.New("min max scaler needs Min < Max")
+	}
+	_, cols := x.Dims()
+	data_min := make([]float64, cols)
+	data_range := make([]float64, cols)
+	for j := 0; j < cols; j++ {
+		column := mat.Col(nil, j, x)
+		data_min[j] = slices.Min(column)
+		data_range[j] = slices.Max(column) - data_min[j]
+	}
+	scaler.DataMin = mat.NewDense(1, cols, data_min)
+	scaler.DataRange = mat.NewDense(1, cols, nonZero(data_range))
+	return nil
+}
+
+func (scaler *MinMaxScaler) Transform(x *mat.Dense) (*mat.Dense, error) {
+	if err := checkInput(x, width(scaler.DataMin)); err != nil {
+		return nil, err
+	}
+	result := scaleColumns(x, scaler.DataMin, scaler.DataRange)
+	result.Apply(func(i, j int, v float64) float64 {
+		return scaler.Min + v*(scaler.Max-scaler.Min)
+	}, result)
+	return result, nil
+}
+
+type minMaxConfig struct {
+	Features int     `json:"features"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+}
+
+func (scaler *MinMaxScaler) MarshalConfig() ([]byte, error) {
+	if scaler.DataMin == nil {
+		return nil, errNotFitted
+	}
+	return json.Marshal(minMaxConfig{Features: width(scaler.DataMin), Min: scaler.Min, Max: scaler.Max})
+}
+
+// size checks c and returns the number of elements of the scaler's rows.
+func (c minMaxConfig) size() (int, error) {
+	if c.Features <= 0 || c.Max <= c.Min {
+		return 0, errors.New("invalid min max scaler config")
+	}
+	if err := layer.CheckSize(1, c.Features); err != nil {
+		return 0, err
+	}
+	return 2 * c.Features, nil
+}
+
+func (scaler *MinMaxScaler) ParamSize(config []byte) (int, error) {
+	var c minMaxConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return 0, err
+	}
+	return c.size()
+}
+
+func (scaler *MinMaxScaler) UnmarshalConfig(config []byte) error {
+	var c minMaxConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if _, err := c.size(); err != nil {
+		return err
+	}
+	scaler.Min, scaler.Max = c.Min, c.Max
+	scaler.DataMin = mat.NewDense(1, c.Features, nil)
+	scaler.DataRange = mat.NewDense(1, c.Features, nil)
+	return nil
+}
+
+func (scaler *MinMaxScaler) Params() []layer.Param {
+	return []layer.Param{{Name: "data_min", Value: scaler.DataMin}, {Name: "data_range", Value: scaler.DataRange}}
+}
+
+func (scaler *MinMaxScaler) InputSize() int  { return width(scaler.DataMin) }
+func (scaler *MinMaxScaler) OutputSize() int { return width(scaler.DataMin) }
+
+// RobustScaler centers every feature on its median and divides by its
+// interquartile range, so outliers barely affect the scaling.
+type RobustScaler struct {
+	Median *mat.Dense
+	IQR    *mat.Dense // 1 for features with no spread
+}
+
+func Robust() *RobustScaler {
+	return &RobustScaler{}
+}
+
+func (scaler *RobustScaler) Fit(x *mat.Dense) error {
+	_, cols := x.Dims()
+	median := make([]float64, cols)
+	iqr := make([]float64, cols)
+	for j := 0; j < cols; j++ {
+		column := mat.Col(nil, j, x)
+		slices.Sort(column)
+		median[j] = quantile(column, 0.5)
+		iqr[j] = quantile(column, 0.75) - quantile(column, 0.25)
+	}
+	scaler.Median = mat.NewDense(1, cols, median)
+	scaler.IQR = mat.NewDense(1, cols, nonZero(iqr))
+	return nil
+}
+
+// quantile interpolates linearly between the closest ranks of sorted, the
+// usual definition of the median and quartiles. stat.Quantile's LinInterp
+// would give 2 as the median of 1, 2, 3, 10.
+func quantile(sorted []float64, p float64) float64 {
+	h := float64(len(sorted)-1) * p
+	lo := int(math.Floor(h))
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (h-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+func (scaler *RobustScaler) Transform(x *mat.Dense) (*mat.Dense, error) {
+	if err := checkInput(x, width(scaler.Median)); err != nil {
+		return nil, err
+	}
+	return scaleColumns(x, scaler.Median, scaler.IQR), nil
+}
+
+func (scaler *RobustScaler) MarshalConfig() ([]byte, error) {
+	if scaler.Median == nil {
+		return nil, errNotFitted
+	}
+	return json.Marshal(featuresConfig{Features: width(scaler.Median)})
+}
+
+func (scaler *RobustScaler) ParamSize(config []byte) (int, error) {
+	return featuresSize(config)
+}
+
+func (scaler *RobustScaler) UnmarshalConfig(config []byte) error {
+	var c featuresConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	if _, err := c.size(); err != nil {
+		return err
+	}
+	scaler.Median = mat.NewDense(1, c.Features, nil)
+	scaler.IQR = mat.NewDense(1, c.Features, nil)
+	return nil
+}
+
+func (scaler *RobustScaler) Params() []layer.Param {
+	return []layer.Param{{Name: "median", Value: scaler.Median}, {Name: "iqr", Value: scaler.IQR}}
+}
+
+func (scaler *RobustScaler) InputSize() int  { return width(scaler.Median) }
+func (scaler *RobustScaler) OutputSize() int { return width(scaler.Median) }
@@ -0,0 +1,177 @@
+// Package prune shrinks trained networks by zeroing the Dense weights with
+// the smallest magnitude.
+//
+// Pruning sets the Mask of every pruned layer, so that further training
+// keeps pruned weights at 0; pruned weights are never brought back. Pruning
+// gradually while training, with a Schedule, usually loses less accuracy
+// than pruning everything at once. Once pruned, ToSparse stores the layers in
+// compressed sparse row form, which is smaller and faster to run.
+package prune
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// DenseLayers returns the Dense layers of net, including those in
+// Sequential blocks, in order.
+func DenseLayers(net *network.Network) []*layer.DenseLayer {
+	return denseLayers(net.Layers, nil)
+}
+
+func denseLayers(layers []layer.Layer, found []*layer.DenseLayer) []*layer.DenseLayer {
+	for _, l := range layers {
+		switch l := l.(type) {
+		case *layer.DenseLayer:
+			found = append(found, l)
+		case *layer.SequentialLayer:
+			found = denseLayers(l.Layers, found)
+		}
+	}
+	return found
+}
+
+// weight is one weight of a layer, by its index in the layer's raw data.
+type weight struct {
+	dense     *layer.DenseLayer
+	index     int
+	magnitude float64
+}
+
+// weights lists the weights of layers.
+func weights(layers []*layer.DenseLayer) []weight {
+	found := make([]weight, 0)
+	for _, dense := range layers {
+		rows, cols := dense.Weights.Dims()
+		for i := 0; i < rows; i++ {
+			for j := 0; j < cols; j++ {
+				found = append(found, weight{dense: dense, index: i*cols + j, magnitude: math.Abs(dense.Weights.At(i, j))})
+			}
+		}
+	}
+	return found
+}
+
+// prune zeroes and masks the fraction sparsity of candidates with the
+// smallest magnitude.
+func prune(candidates []weight, sparsity float64) error {
+	if sparsity < 0 || sparsity > 1 || math.IsNaN(sparsity) {
+		return fmt.Errorf("prune: sparsity %v isn't between 0 and 1", sparsity)
+	}
+	// weights already at 0 sort first, so pruned weights stay pruned
+	slices.SortStableFunc(candidates, func(a, b weight) int {
+		switch {
+		case a.magnitude < b.magnitude:
+			return -1
+		case a.magnitude > b.magnitude:
+			return 1
+		}
+		return 0
+	})
+
+	for _, candidate := range candidates {
+		if candidate.dense.Mask == nil {
+			rows, cols := candidate.dense.Weights.Dims()
+			candidate.dense.Mask = mat.NewDense(rows, cols, nil)
+			for i := 0; i < rows; i++ {
+				for j := 0; j < cols; j++ {
+					candidate.dense.Mask.Set(i, j, 1)
+				}
+			}
+		}
+	}
+	for _, candidate := range candidates[:int(math.Round(sparsity*float64(len(candidates))))] {
+		_, cols := candidate.dense.Weights.Dims()
+		i, j := candidate.index/cols, candidate.index%cols
+		candidate.dense.Weights.Set(i, j, 0)
+		candidate.dense.Mask.Set(i, j, 0)
+	}
+	return nil
+}
+
+// Layer prunes the fraction sparsity of the weights of dense with the
+// smallest magnitude, a per-layer threshold. Weights pruned before count
+// towards it.
+func Layer(dense *layer.DenseLayer, sparsity float64) error {
+	return prune(weights([]*layer.DenseLayer{dense}), sparsity)
+}
+
+// PerLayer prunes every Dense layer of net to sparsity.
+func PerLayer(net *network.Network, sparsity float64) error {
+	for _, dense := range DenseLayers(net) {
+		if err := Layer(dense, sparsity); err != nil {
+			return err
+		}
+	}
+	net.ResetFloat32()
+	return nil
+}
+
+// Global prunes the fraction sparsity of all weights of the Dense layers of
+// net with the smallest magnitude, one threshold for every layer. Layers
+// with small weights end up sparser than others.
+func Global(net *network.Network, sparsity float64) error {
+	if err := prune(weights(DenseLayers(net)), sparsity); err != nil {
+		return err
+	}
+	net.ResetFloat32()
+	return nil
+}
+
+// Sparsity returns the fraction of Dense weights of net that are 0.
+func Sparsity(net *network.Network) float64 {
+	zeros, total := 0, 0
+	for _, dense := range DenseLayers(net) {
+		rows, cols := dense.Weights.Dims()
+		total += rows * cols
+		zeros += rows*cols - nonZero(dense.Weights)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(zeros) / float64(total)
+}
+
+func nonZero(m *mat.Dense) int {
+	count := 0
+	rows, _ := m.Dims()
+	for i := 0; i < rows; i++ {
+		for _, v := range m.RawRowView(i) {
+			if v != 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// ToSparse replaces every Dense layer of net, including those in
+// Sequential blocks, with at least min_sparsity of its weights at 0 by a
+// SparseDense layer, and returns how many were replaced.
+func ToSparse(net *network.Network, min_sparsity float64) int {
+	replaced := toSparse(net.Layers, min_sparsity)
+	net.ResetFloat32()
+	return replaced
+}
+
+func toSparse(layers []layer.Layer, min_sparsity float64) int {
+	replaced := 0
+	for i, l := range layers {
+		switch l := l.(type) {
+		case *layer.DenseLayer:
+			rows, cols := l.Weights.Dims()
+			if float64(rows*cols-nonZero(l.Weights)) >= min_sparsity*float64(rows*cols) {
+				layers[i] = layer.ToSparse(l)
+				replaced++
+			}
+		case *layer.SequentialLayer:
+			replaced += toSparse(l.Layers, min_sparsity)
+		}
+	}
+	return replaced
+}
@@ -0,0 +1,49 @@
+package prune
+
+import (
+	"math"
+
+	"github.com/kapilpokhrel/goNN/pkg/network"
+)
+
+// Schedule prunes gradually while training, from Initial sparsity after
+// epoch Start to Final sparsity after epoch End. Sparsity grows quickly at
+// first and slowly towards the end (Zhu and Gupta, 2017), when there are
+// fewer weights left to prune and each matters more:
+//
+//	sparsity = Final + (Initial - Final) * (1 - (epoch - Start) / (End - Start))^3
+//
+// Use it as the network's OnEpoch:
+//
+//	model.OnEpoch = prune.Schedule{Final: 0.9, Start: 2, End: 10}.OnEpoch
+type Schedule struct {
+	Initial, Final float64
+	Start, End     int // epochs, counted from 1 like State.Epoch
+	// Global uses one threshold for all layers, see Global, instead of
+	// pruning every layer to the same sparsity.
+	Global bool
+}
+
+// At returns the sparsity wanted after epoch, 0 before Start.
+func (schedule Schedule) At(epoch int) float64 {
+	switch {
+	case epoch < schedule.Start:
+		return 0
+	case epoch >= schedule.End:
+		return schedule.Final
+	}
+	remaining := 1 - float64(epoch-schedule.Start)/float64(schedule.End-schedule.Start)
+	return schedule.Final + (schedule.Initial-schedule.Final)*math.Pow(remaining, 3)
+}
+
+// OnEpoch prunes net to the sparsity of the epoch it just finished.
+func (schedule Schedule) OnEpoch(net *network.Network) error {
+	if net.State.Epoch < schedule.Start {
+		return nil
+	}
+	sparsity := schedule.At(net.State.Epoch)
+	if schedule.Global {
+		return Global(net, sparsity)
+	}
+	return PerLayer(net, sparsity)
+}
@@ -0,0 +1,175 @@
+// Package quantize converts trained networks to int8 weights for inference
+// on small devices, see layer.QuantizedDenseLayer.
+//
+// Quantization happens after training. The input range of every Dense layer
+// is calibrated by running the float network over sample data, which should
+// look like what the quantized network will see. Compare then tells how much
+// accuracy was lost.
+package quantize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+)
+
+// Options chooses how weights are quantized.
+type Options struct {
+	// PerChannel gives every output column of a weight matrix its own scale
+	// and zero point, instead of one for the whole matrix. It is more
+	// accurate when the columns have different ranges.
+	PerChannel bool
+	// BatchSize is the number of calibration samples run at once, 64 if 0.
+	BatchSize int
+}
+
+// valueRange is the range of the values seen by one layer.
+type valueRange struct{ min, max float64 }
+
+// Network returns a copy of float with every Dense layer, including those
+// in Sequential blocks, replaced by a QuantizedDense layer. The input range
+// of each is calibrated on calibration. Other layers are copied, so every
+// layer has to be registered, see layer.Register.
+func Network(float *network.Network, calibration data.Dataset, options Options) (*network.Network, error) {
+	ranges, err := calibrate(float, calibration, options.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("quantize: calibrating: %w", err)
+	}
+
+	quantized := &network.Network{
+		Layers:     make([]layer.Layer, len(float.Layers)),
+		Loss:       float.Loss,
+		LossPrime:  float.LossPrime,
+		Preprocess: float.Preprocess,
+	}
+	for i, current := range float.Layers {
+		if quantized.Layers[i], err = convert(current, ranges, options); err != nil {
+			return nil, fmt.Errorf("quantize: layer %d: %w", i, err)
+		}
+	}
+	return quantized, nil
+}
+
+// calibrate runs float over the dataset and records the input range of
+// every Dense layer.
+func calibrate(float *network.Network, calibration data.Dataset, batch_size int) (map[*layer.DenseLayer]*valueRange, error) {
+	if batch_size == 0 {
+		batch_size = 64
+	}
+	loader := &data.DataLoader{Dataset: calibration, BatchSize: batch_size}
+	batches, err := loader.Epoch(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer batches.Close()
+
+	ranges := make(map[*layer.DenseLayer]*valueRange)
+	samples := 0
+	for {
+		batch, err := batches.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		samples += batch.Size
+
+		inputs := batch.Inputs
+		if float.Preprocess != nil {
+			if inputs, err = float.Preprocess.Transform(inputs); err != nil {
+				return nil, fmt.Errorf("preprocess: %w", err)
+			}
+		}
+		result := tensor.FromDense(inputs)
+		for _, current := range float.Layers {
+			if result, err = observe(current, result, ranges); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if samples == 0 {
+		return nil, errors.New("no calibration samples")
+	}
+	return ranges, nil
+}
+
+// observe runs l on input, recording the input of Dense layers. Layers
+// that can't infer are run with Forward.
+func observe(l layer.Layer, input *tensor.Tensor, ranges map[*layer.DenseLayer]*valueRange) (*tensor.Tensor, error) {
+	switch l := l.(type) {
+	case *layer.SequentialLayer:
+		result := input
+		for _, child := range l.Layers {
+			var err error
+			if result, err = observe(child, result, ranges); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case *layer.DenseLayer:
+		seen, ok := ranges[l]
+		if !ok {
+			seen = &valueRange{min: math.Inf(1), max: math.Inf(-1)}
+			ranges[l] = seen
+		}
+		seen.min = math.Min(seen.min, input.Min().Item())
+		seen.max = math.Max(seen.max, input.Max().Item())
+	}
+
+	if !layer.CanInfer(l) {
+		// only changes what the layer keeps for Backward
+		return l.Forward(input)
+	}
+	return l.(layer.Inferer).Infer(input)
+}
+
+// convert returns the quantized version of l, or a copy of it.
+func convert(l layer.Layer, ranges map[*layer.DenseLayer]*valueRange, options Options) (layer.Layer, error) {
+	switch l := l.(type) {
+	case *layer.SequentialLayer:
+		converted := layer.Sequential(make([]layer.Layer, len(l.Layers))...)
+		for i, child := range l.Layers {
+			var err error
+			if converted.Layers[i], err = convert(child, ranges, options); err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	case *layer.DenseLayer:
+		seen := ranges[l]
+		return layer.QuantizeDense(l, layer.QuantRange(seen.min, seen.max), options.PerChannel), nil
+	}
+	return clone(l)
+}
+
+// clone builds a new layer with the same configuration and parameters.
+func clone(l layer.Layer) (layer.Layer, error) {
+	spec, err := layer.Describe(l)
+	if err != nil {
+		return nil, err
+	}
+	built, err := layer.Build(spec)
+	if err != nil {
+		return nil, err
+	}
+	cloned, ok := built.(layer.Layer)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a layer", spec.Type)
+	}
+	dst, src := built.Params(), l.(layer.Serializable).Params()
+	for i := range dst {
+		if dst[i].Int8 != nil {
+			copy(dst[i].Int8.Data, src[i].Int8.Data)
+			continue
+		}
+		dst[i].Value.Copy(src[i].Value)
+	}
+	return cloned, nil
+}
@@ -0,0 +1,127 @@
+package quantize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Report compares a quantized network with the float network it was made
+// from, on the same samples.
+type Report struct {
+	Samples int
+
+	// Accuracies are the share of samples classified right: the largest
+	// output is where the target is largest or, for a single output, the
+	// output and the target are on the same side of 0.5.
+	FloatAccuracy, QuantizedAccuracy float64
+	// Losses are the mean loss per sample, NaN if the float network has no
+	// Loss.
+	FloatLoss, QuantizedLoss float64
+	// MaxError and MeanError are the largest and the mean absolute
+	// difference between the outputs of the two networks.
+	MaxError, MeanError float64
+}
+
+// AccuracyDelta is the accuracy gained by quantizing, usually negative.
+func (report Report) AccuracyDelta() float64 {
+	return report.QuantizedAccuracy - report.FloatAccuracy
+}
+
+func (report Report) String() string {
+	return fmt.Sprintf("accuracy %.2f%% -> %.2f%% (%+.2f), loss %.6f -> %.6f, output error mean %.6f max %.6f over %d samples",
+		100*report.FloatAccuracy, 100*report.QuantizedAccuracy, 100*report.AccuracyDelta(),
+		report.FloatLoss, report.QuantizedLoss, report.MeanError, report.MaxError, report.Samples)
+}
+
+// Compare runs both networks over dataset.
+func Compare(float, quantized *network.Network, dataset data.Dataset) (Report, error) {
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 64}
+	batches, err := loader.Epoch(nil)
+	if err != nil {
+		return Report{}, err
+	}
+	defer batches.Close()
+
+	var report Report
+	var float_correct, quantized_correct, values int
+	for {
+		batch, err := batches.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Report{}, err
+		}
+
+		float_result, err := float.PredictContext(context.Background(), batch.Inputs)
+		if err != nil {
+			return Report{}, fmt.Errorf("float network: %w", err)
+		}
+		quantized_result, err := quantized.PredictContext(context.Background(), batch.Inputs)
+		if err != nil {
+			return Report{}, fmt.Errorf("quantized network: %w", err)
+		}
+
+		rows, cols := float_result.Dims()
+		for i := 0; i < rows; i++ {
+			target := batch.Targets.RawRowView(i)
+			if correct(float_result.RawRowView(i), target) {
+				float_correct++
+			}
+			if correct(quantized_result.RawRowView(i), target) {
+				quantized_correct++
+			}
+		}
+		var diff mat.Dense
+		diff.Sub(float_result, quantized_result)
+		diff.Apply(func(i, j int, v float64) float64 { return math.Abs(v) }, &diff)
+		report.MaxError = math.Max(report.MaxError, mat.Max(&diff))
+		report.MeanError += mat.Sum(&diff)
+		values += rows * cols
+
+		if float.Loss != nil {
+			report.FloatLoss += float.Loss(batch.Targets, float_result) * float64(batch.Size)
+			report.QuantizedLoss += float.Loss(batch.Targets, quantized_result) * float64(batch.Size)
+		}
+		report.Samples += batch.Size
+	}
+	if report.Samples == 0 {
+		return Report{}, errors.New("quantize: no samples to compare on")
+	}
+
+	n := float64(report.Samples)
+	report.FloatAccuracy = float64(float_correct) / n
+	report.QuantizedAccuracy = float64(quantized_correct) / n
+	report.MeanError /= float64(values)
+	if float.Loss != nil {
+		report.FloatLoss /= n
+		report.QuantizedLoss /= n
+	} else {
+		report.FloatLoss, report.QuantizedLoss = math.NaN(), math.NaN()
+	}
+	return report, nil
+}
+
+func correct(output, target []float64) bool {
+	if len(output) == 1 {
+		return (output[0] >= 0.5) == (target[0] >= 0.5)
+	}
+	return argmax(output) == argmax(target)
+}
+
+func argmax(values []float64) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
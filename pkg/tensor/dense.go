@@ -0,0 +1,70 @@
+package tensor
+
+import (
+	"fmt"
+	"slices"
+
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/mat"
+)
+
+// FromDense returns the rank 2 tensor sharing m's storage.
+func FromDense(m *mat.Dense) *Tensor {
+	raw := m.RawMatrix()
+	return &Tensor{
+		shape:   []int{raw.Rows, raw.Cols},
+		strides: []int{raw.Stride, 1},
+		data:    raw.Data,
+	}
+}
+
+// Dense returns a rank 2 tensor as a matrix. It shares t's storage when
+// the elements of every row are next to each other, as in a tensor made
+// by New, a Slice of it or FromDense, and is a copy otherwise, for
+// example for a transposed view.
+func (t *Tensor) Dense() (*mat.Dense, error) {
+	if len(t.shape) != 2 {
+		return nil, fmt.Errorf("tensor: a matrix needs 2 dimensions, got shape %v", t.shape)
+	}
+	rows, cols := t.shape[0], t.shape[1]
+	if rows == 0 || cols == 0 {
+		return nil, fmt.Errorf("tensor: gonum matrices can't be empty, got shape %v", t.shape)
+	}
+
+	stride := t.strides[0]
+	if rows == 1 {
+		stride = cols
+	}
+	if t.strides[1] != 1 && cols > 1 || stride < cols {
+		return mat.NewDense(rows, cols, t.Values()), nil
+	}
+	var m mat.Dense
+	m.SetRawMatrix(blas64.General{
+		Rows:   rows,
+		Cols:   cols,
+		Stride: stride,
+		Data:   t.data[t.offset : t.offset+(rows-1)*stride+cols],
+	})
+	return &m, nil
+}
+
+// Rows returns t as a matrix with one row per element of its leading
+// dimensions and its last dimension as the columns, so that a layer
+// working on rows of features sees every step of a [batch, steps,
+// features] sequence. Storage is shared as by Dense.
+func (t *Tensor) Rows() (*mat.Dense, error) {
+	if len(t.shape) == 0 {
+		return nil, fmt.Errorf("tensor: rows need at least 1 dimension, got a scalar")
+	}
+	features := t.shape[len(t.shape)-1]
+	return t.Reshape(t.Size()/max(features, 1), features).Dense()
+}
+
+// FromRows is the inverse of Rows: it returns the tensor sharing m's
+// storage with the leading dimensions of shape and m's columns as its last
+// dimension.
+func FromRows(m *mat.Dense, shape []int) *Tensor {
+	_, cols := m.Dims()
+	shape = append(slices.Clone(shape[:len(shape)-1]), cols)
+	return FromDense(m).Reshape(shape...)
+}
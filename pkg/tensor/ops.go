@@ -0,0 +1,153 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Map returns a new tensor with f applied to every element.
+func (t *Tensor) Map(f func(v float64) float64) *Tensor {
+	result := t.Clone()
+	for i, v := range result.data {
+		result.data[i] = f(v)
+	}
+	return result
+}
+
+// Apply replaces every element v of t by f(v), in place.
+func (t *Tensor) Apply(f func(v float64) float64) {
+	t.each(func(pos int) { t.data[pos] = f(t.data[pos]) })
+}
+
+func (t *Tensor) Scale(factor float64) *Tensor {
+	return t.Map(func(v float64) float64 { return factor * v })
+}
+
+// combine applies op to the elements of a and b, broadcast to a common
+// shape.
+func combine(a, b *Tensor, op func(x, y float64) float64) (*Tensor, error) {
+	shape, err := BroadcastShape(a.shape, b.shape)
+	if err != nil {
+		return nil, err
+	}
+	a, _ = a.Broadcast(shape...)
+	b, _ = b.Broadcast(shape...)
+
+	result := New(shape...)
+	values := b.Values()
+	i := 0
+	a.each(func(pos int) {
+		result.data[i] = op(a.data[pos], values[i])
+		i++
+	})
+	return result, nil
+}
+
+// Add returns a + b, broadcasting them to a common shape, see Broadcast.
+func Add(a, b *Tensor) (*Tensor, error) {
+	return combine(a, b, func(x, y float64) float64 { return x + y })
+}
+
+func Sub(a, b *Tensor) (*Tensor, error) {
+	return combine(a, b, func(x, y float64) float64 { return x - y })
+}
+
+// Mul multiplies element by element.
+func Mul(a, b *Tensor) (*Tensor, error) {
+	return combine(a, b, func(x, y float64) float64 { return x * y })
+}
+
+func Div(a, b *Tensor) (*Tensor, error) {
+	return combine(a, b, func(x, y float64) float64 { return x / y })
+}
+
+// reduce folds the elements over the given dimensions, all of them if
+// there are none, and removes those dimensions.
+func (t *Tensor) reduce(axes []int, init float64, op func(acc, v float64) float64) *Tensor {
+	reduced := make([]bool, len(t.shape))
+	for _, axis := range axes {
+		reduced[t.axis(axis)] = true
+	}
+	if len(axes) == 0 {
+		for i := range reduced {
+			reduced[i] = true
+		}
+	}
+
+	// a view of t with the reduced dimensions moved to the end
+	perm := make([]int, 0, len(t.shape))
+	shape := make([]int, 0, len(t.shape))
+	for i := range t.shape {
+		if !reduced[i] {
+			perm = append(perm, i)
+			shape = append(shape, t.shape[i])
+		}
+	}
+	for i := range t.shape {
+		if reduced[i] {
+			perm = append(perm, i)
+		}
+	}
+
+	result := New(shape...)
+	group := t.Size() / max(result.Size(), 1)
+	if group == 0 {
+		for i := range result.data {
+			result.data[i] = init
+		}
+		return result
+	}
+	count := 0
+	t.Transpose(perm...).each(func(pos int) {
+		i := count / group
+		if count%group == 0 {
+			result.data[i] = init
+		}
+		result.data[i] = op(result.data[i], t.data[pos])
+		count++
+	})
+	return result
+}
+
+// Sum adds up the elements over the given dimensions, or over all of them
+// if there are none.
+func (t *Tensor) Sum(axes ...int) *Tensor {
+	return t.reduce(axes, 0, func(acc, v float64) float64 { return acc + v })
+}
+
+func (t *Tensor) Mean(axes ...int) *Tensor {
+	sum := t.Sum(axes...)
+	return sum.Scale(float64(sum.Size()) / float64(t.Size()))
+}
+
+func (t *Tensor) Max(axes ...int) *Tensor {
+	return t.reduce(axes, math.Inf(-1), math.Max)
+}
+
+func (t *Tensor) Min(axes ...int) *Tensor {
+	return t.reduce(axes, math.Inf(1), math.Min)
+}
+
+// Equal reports whether a and b have the same shape and elements.
+func Equal(a, b *Tensor) bool {
+	return slices.Equal(a.shape, b.shape) && slices.Equal(a.Values(), b.Values())
+}
+
+// EqualApprox is Equal with an absolute tolerance.
+func EqualApprox(a, b *Tensor, tolerance float64) bool {
+	if !slices.Equal(a.shape, b.shape) {
+		return false
+	}
+	values := b.Values()
+	for i, v := range a.Values() {
+		if math.Abs(v-values[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Tensor) String() string {
+	return fmt.Sprintf("tensor%v%v", t.shape, t.Values())
+}
@@ -0,0 +1,176 @@
+// Package tensor is an N-dimensional array of float64 over a flat slice,
+// for inputs that don't fit the rows of a matrix: sequences, images and
+// batches of either.
+//
+// A Tensor is a view: a shape, the stride of every dimension and an offset
+// into data that may be shared with other tensors. Slice, Transpose,
+// Broadcast and, for contiguous tensors, Reshape return views without
+// copying, so writing to a view writes to the tensor it came from. Dense
+// converts rank 2 tensors to gonum matrices for matrix products, and Rows
+// tensors of any rank, one row per vector of features, sharing memory
+// whenever the layout allows it.
+//
+// Operations panic on invalid shapes or indices, like gonum's mat, except
+// for the ones combining tensors, which return an error.
+package tensor
+
+import (
+	"fmt"
+	"slices"
+)
+
+type Tensor struct {
+	shape   []int
+	strides []int
+	offset  int
+	data    []float64
+}
+
+// New returns a tensor of zeros.
+func New(shape ...int) *Tensor {
+	return FromData(make([]float64, size(shape)), shape...)
+}
+
+// FromData returns a tensor using data, in row-major order, as its
+// storage.
+func FromData(data []float64, shape ...int) *Tensor {
+	if len(data) != size(shape) {
+		panic(fmt.Sprintf("tensor: %d values for shape %v", len(data), shape))
+	}
+	return &Tensor{shape: slices.Clone(shape), strides: rowMajor(shape), data: data}
+}
+
+func size(shape []int) int {
+	n := 1
+	for _, dim := range shape {
+		if dim < 0 {
+			panic(fmt.Sprintf("tensor: negative dimension in shape %v", shape))
+		}
+		n *= dim
+	}
+	return n
+}
+
+// rowMajor returns the strides of a contiguous tensor of the shape.
+func rowMajor(shape []int) []int {
+	strides := make([]int, len(shape))
+	stride := 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		strides[i] = stride
+		stride *= shape[i]
+	}
+	return strides
+}
+
+func (t *Tensor) Shape() []int   { return slices.Clone(t.shape) }
+func (t *Tensor) Strides() []int { return slices.Clone(t.strides) }
+func (t *Tensor) Rank() int      { return len(t.shape) }
+func (t *Tensor) Size() int      { return size(t.shape) }
+
+// Dim returns the size of dimension i, counted from the end if negative.
+func (t *Tensor) Dim(i int) int {
+	return t.shape[t.axis(i)]
+}
+
+// axis checks i and turns a negative i into a dimension from the end.
+func (t *Tensor) axis(i int) int {
+	if i < 0 {
+		i += len(t.shape)
+	}
+	if i < 0 || i >= len(t.shape) {
+		panic(fmt.Sprintf("tensor: no dimension %d in shape %v", i, t.shape))
+	}
+	return i
+}
+
+func (t *Tensor) position(index []int) int {
+	if len(index) != len(t.shape) {
+		panic(fmt.Sprintf("tensor: %d indices for shape %v", len(index), t.shape))
+	}
+	pos := t.offset
+	for i, k := range index {
+		if k < 0 || k >= t.shape[i] {
+			panic(fmt.Sprintf("tensor: index %v out of range for shape %v", index, t.shape))
+		}
+		pos += k * t.strides[i]
+	}
+	return pos
+}
+
+func (t *Tensor) At(index ...int) float64 {
+	return t.data[t.position(index)]
+}
+
+func (t *Tensor) Set(v float64, index ...int) {
+	t.data[t.position(index)] = v
+}
+
+// Item returns the value of a tensor with a single element, like the
+// result of reducing over every dimension.
+func (t *Tensor) Item() float64 {
+	if t.Size() != 1 {
+		panic(fmt.Sprintf("tensor: Item of a tensor of shape %v", t.shape))
+	}
+	return t.data[t.offset]
+}
+
+// Contiguous reports whether the elements are laid out in row-major order
+// without gaps, so the tensor can be reshaped without copying.
+func (t *Tensor) Contiguous() bool {
+	expected := rowMajor(t.shape)
+	for i, dim := range t.shape {
+		if dim > 1 && t.strides[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// each calls f with the position in data of every element, in row-major
+// order.
+func (t *Tensor) each(f func(pos int)) {
+	n := t.Size()
+	if n == 0 {
+		return
+	}
+	index := make([]int, len(t.shape))
+	pos := t.offset
+	for count := 0; count < n; count++ {
+		f(pos)
+		for i := len(index) - 1; i >= 0; i-- {
+			index[i]++
+			pos += t.strides[i]
+			if index[i] < t.shape[i] {
+				break
+			}
+			pos -= index[i] * t.strides[i]
+			index[i] = 0
+		}
+	}
+}
+
+// Values returns a copy of the elements in row-major order.
+func (t *Tensor) Values() []float64 {
+	values := make([]float64, 0, t.Size())
+	t.each(func(pos int) { values = append(values, t.data[pos]) })
+	return values
+}
+
+// Clone returns a contiguous copy of t.
+func (t *Tensor) Clone() *Tensor {
+	return FromData(t.Values(), t.shape...)
+}
+
+// Copy copies the elements of src, which must have the same shape, into t.
+func (t *Tensor) Copy(src *Tensor) error {
+	if !slices.Equal(t.shape, src.shape) {
+		return fmt.Errorf("tensor: can't copy shape %v into %v", src.shape, t.shape)
+	}
+	values := src.Values()
+	i := 0
+	t.each(func(pos int) {
+		t.data[pos] = values[i]
+		i++
+	})
+	return nil
+}
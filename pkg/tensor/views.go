@@ -0,0 +1,159 @@
+package tensor
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Slice returns the view of elements start to end-1 along dimension dim.
+func (t *Tensor) Slice(dim, start, end int) *Tensor {
+	dim = t.axis(dim)
+	if start < 0 || end > t.shape[dim] || start > end {
+		panic(fmt.Sprintf("tensor: slice [%d:%d] out of range for dimension %d of shape %v", start, end, dim, t.shape))
+	}
+	view := &Tensor{shape: slices.Clone(t.shape), strides: slices.Clone(t.strides), offset: t.offset, data: t.data}
+	view.shape[dim] = end - start
+	view.offset += start * t.strides[dim]
+	return view
+}
+
+// Index returns the view of element i along dimension dim, with that
+// dimension removed, e.g. one sample of a batch.
+func (t *Tensor) Index(dim, i int) *Tensor {
+	dim = t.axis(dim)
+	view := t.Slice(dim, i, i+1)
+	view.shape = slices.Delete(view.shape, dim, dim+1)
+	view.strides = slices.Delete(view.strides, dim, dim+1)
+	return view
+}
+
+// Reshape returns t with a new shape of the same size. One dimension can
+// be -1, it is then inferred from the others. Contiguous tensors are
+// reshaped without copying, others are copied first.
+func (t *Tensor) Reshape(shape ...int) *Tensor {
+	shape = slices.Clone(shape)
+	inferred, known := -1, 1
+	for i, dim := range shape {
+		if dim == -1 && inferred < 0 {
+			inferred = i
+			continue
+		}
+		known *= dim
+	}
+	if inferred >= 0 && known > 0 {
+		shape[inferred] = t.Size() / known
+	}
+	if size(shape) != t.Size() {
+		panic(fmt.Sprintf("tensor: can't reshape %v to %v", t.shape, shape))
+	}
+
+	source := t
+	if !t.Contiguous() {
+		source = t.Clone()
+	}
+	return &Tensor{shape: shape, strides: rowMajor(shape), offset: source.offset, data: source.data}
+}
+
+// Transpose returns the view with dimensions permuted: dimension i of the
+// result is dimension perm[i] of t. Without perm the dimensions are
+// reversed, which transposes a matrix.
+func (t *Tensor) Transpose(perm ...int) *Tensor {
+	if len(perm) == 0 {
+		for i := len(t.shape) - 1; i >= 0; i-- {
+			perm = append(perm, i)
+		}
+	}
+	sorted := slices.Clone(perm)
+	slices.Sort(sorted)
+	valid := len(perm) == len(t.shape)
+	for i, dim := range sorted {
+		valid = valid && dim == i
+	}
+	if !valid {
+		panic(fmt.Sprintf("tensor: %v isn't a permutation of the dimensions of %v", perm, t.shape))
+	}
+
+	view := &Tensor{shape: make([]int, len(perm)), strides: make([]int, len(perm)), offset: t.offset, data: t.data}
+	for i, dim := range perm {
+		view.shape[i], view.strides[i] = t.shape[dim], t.strides[dim]
+	}
+	return view
+}
+
+// Broadcast returns the view of t repeated to shape, following NumPy's
+// rules: dimensions are matched from the end, and t's dimensions have to
+// be 1 or equal to shape's. Repeated elements share storage, so the view
+// shouldn't be written to.
+func (t *Tensor) Broadcast(shape ...int) (*Tensor, error) {
+	if len(shape) < len(t.shape) {
+		return nil, fmt.Errorf("tensor: can't broadcast %v to %v", t.shape, shape)
+	}
+	view := &Tensor{shape: slices.Clone(shape), strides: make([]int, len(shape)), offset: t.offset, data: t.data}
+	extra := len(shape) - len(t.shape)
+	for i := range t.shape {
+		switch t.shape[i] {
+		case shape[extra+i]:
+			view.strides[extra+i] = t.strides[i]
+		case 1:
+			// stride 0 repeats the element
+		default:
+			return nil, fmt.Errorf("tensor: can't broadcast %v to %v", t.shape, shape)
+		}
+	}
+	return view, nil
+}
+
+// BroadcastShape returns the shape two tensors are broadcast to when
+// combined.
+func BroadcastShape(a, b []int) ([]int, error) {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	shape := slices.Clone(a)
+	extra := len(a) - len(b)
+	for i, dim := range b {
+		switch {
+		case dim == shape[extra+i] || dim == 1:
+		case shape[extra+i] == 1:
+			shape[extra+i] = dim
+		default:
+			return nil, fmt.Errorf("tensor: shapes %v and %v can't be broadcast together", a, b)
+		}
+	}
+	return shape, nil
+}
+
+// Concat joins tensors along dimension dim into a new tensor. Their other
+// dimensions have to match.
+func Concat(dim int, tensors ...*Tensor) (*Tensor, error) {
+	if len(tensors) == 0 {
+		return nil, fmt.Errorf("tensor: nothing to concatenate")
+	}
+	first := tensors[0]
+	if len(first.shape) == 0 {
+		return nil, fmt.Errorf("tensor: can't concatenate scalars")
+	}
+	dim = first.axis(dim)
+	shape := slices.Clone(first.shape)
+	shape[dim] = 0
+	for _, t := range tensors {
+		other := slices.Clone(t.shape)
+		if len(other) == len(shape) {
+			other[dim] = 0
+		}
+		if !slices.Equal(other, shape) {
+			return nil, fmt.Errorf("tensor: can't concatenate shape %v with %v along dimension %d", t.shape, first.shape, dim)
+		}
+	}
+	for _, t := range tensors {
+		shape[dim] += t.shape[dim]
+	}
+
+	result := New(shape...)
+	offset := 0
+	for _, t := range tensors {
+		result.Slice(dim, offset, offset+t.shape[dim]).Copy(t)
+		offset += t.shape[dim]
+	}
+	return result, nil
+}
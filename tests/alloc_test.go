@@ -0,0 +1,174 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// allocNetwork is a 64-128-10 network with a batch of 32 samples for it.
+func allocNetwork() (*network.Network, *mat.Dense, *mat.Dense) {
+	model := &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(64, 128), layer.Tanh(128),
+			layer.Sequential(layer.Dense(128, 10), layer.Tanh(10)),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	return model, randomDense(32, 64, 1), randomDense(32, 10, 2)
+}
+
+func TestSteadyStateAllocs(t *testing.T) {
+	model, inputs, targets := allocNetwork()
+	out_grad := mat.NewDense(32, 10, nil)
+	step := func() {
+		result, err := model.Forward(inputs)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		out_grad.Sub(result, targets)
+		model.BackProp(out_grad, 0.01)
+	}
+	step()
+	if allocs := testing.AllocsPerRun(10, step); allocs != 0 {
+		t.Fatalf("expected a training step to reuse its buffers, got %v allocations", allocs)
+	}
+
+	// the scratch matrices are pooled, only the result is allocated. The
+	// race detector makes sync.Pool drop items on purpose.
+	if raceEnabled {
+		return
+	}
+	model.Predict(inputs)
+	if allocs := testing.AllocsPerRun(10, func() { model.Predict(inputs) }); allocs > 2 {
+		t.Fatalf("expected at most 2 allocations per Predict, got %v", allocs)
+	}
+}
+
+func TestLayerSteadyStateAllocs(t *testing.T) {
+	pruned := layer.Dense(64, 32)
+	pruned.Weights = randomDense(64, 32, 1)
+	pruned.Weights.Apply(func(i, j int, v float64) float64 {
+		if (i+j)%4 != 0 {
+			return 0
+		}
+		return v
+	}, pruned.Weights)
+	inputs, out_grad := randomDense(32, 64, 2), randomDense(32, 32, 3)
+
+	for name, l := range map[string]layer.RowLayer{
+		"Dense":          layer.Dense(64, 32),
+		"SparseDense":    layer.ToSparse(pruned),
+		"QuantizedDense": layer.QuantizeDense(layer.Dense(64, 32), layer.QuantRange(-1, 1), true),
+		"Tanh":           layer.Tanh(64),
+	} {
+		grad := out_grad
+		if name == "Tanh" {
+			grad = inputs
+		}
+		step := func() {
+			if _, err := l.ForwardRows(inputs); err != nil {
+				t.Fatalf("%s: expected no error, got %v", name, err)
+			}
+			l.BackwardRows(grad, 0.01)
+		}
+		step()
+		if allocs := testing.AllocsPerRun(10, step); allocs != 0 {
+			t.Errorf("expected %s to reuse its buffers, got %v allocations", name, allocs)
+		}
+	}
+}
+
+func TestBufferedResults(t *testing.T) {
+	model, inputs, _ := allocNetwork()
+	small := inputs.Slice(0, 4, 0, 64).(*mat.Dense)
+
+	// Predict results are the caller's
+	first := model.Predict(inputs)
+	expected := mat.DenseCopyOf(first)
+	model.Predict(small)
+	if !mat.Equal(first, expected) {
+		t.Fatalf("Predict changed the result of an earlier call")
+	}
+
+	// buffers follow the batch size
+	result, _ := model.Forward(small)
+	if rows, _ := result.Dims(); rows != 4 || !mat.EqualApprox(result, model.Predict(small), 1e-14) {
+		t.Fatalf("Forward of a smaller batch doesn't match Predict")
+	}
+	result, _ = model.Forward(inputs)
+	if !mat.EqualApprox(result, expected, 1e-14) {
+		t.Fatalf("Forward of a larger batch doesn't match Predict")
+	}
+
+	// Predict through Forward, for a matrix layer that can't infer, copies
+	// the result
+	identity := layer.Dense(10, 10)
+	identity.Weights.Zero()
+	identity.Biases.Zero()
+	for i := 0; i < 10; i++ {
+		identity.Weights.Set(i, i, 1)
+	}
+	model.Layers = append(model.Layers, layer.FromMatrix(forwardOnly{identity}))
+	first = model.Predict(inputs)
+	model.Predict(small)
+	if !mat.EqualApprox(first, expected, 1e-14) {
+		t.Fatalf("Predict through Forward changed the result of an earlier call")
+	}
+}
+
+// forwardOnly is a matrix layer without Infer, returning the buffers of
+// the layer it runs.
+type forwardOnly struct{ rows layer.RowLayer }
+
+func (l forwardOnly) Forward(input *mat.Dense) (*mat.Dense, error) {
+	return l.rows.ForwardRows(input)
+}
+
+func (l forwardOnly) Backward(out_grad *mat.Dense, rate float64) *mat.Dense {
+	return l.rows.BackwardRows(out_grad, rate)
+}
+
+func BenchmarkTrainStep(b *testing.B) {
+	model, inputs, targets := allocNetwork()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		result, _ := model.Forward(inputs)
+		model.BackProp(model.LossPrime(targets, result), 0.01)
+	}
+}
+
+// BenchmarkTrainLoader trains one epoch of 16 batches per op.
+func BenchmarkTrainLoader(b *testing.B) {
+	model, _, _ := allocNetwork()
+	inputs, targets := randomDense(512, 64, 3), randomDense(512, 10, 4)
+	samples, expected := make([]*mat.Dense, 512), make([]*mat.Dense, 512)
+	for i := range samples {
+		samples[i] = mat.DenseCopyOf(inputs.Slice(i, i+1, 0, 64))
+		expected[i] = mat.DenseCopyOf(targets.Slice(i, i+1, 0, 10))
+	}
+	dataset, _ := data.FromSlices(samples, expected)
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 32}
+	model.State = network.TrainState{Rate: 0.01}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		model.State.Epochs++
+		if err := model.ResumeLoader(loader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPredict(b *testing.B) {
+	model, inputs, _ := allocNetwork()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		model.Predict(inputs)
+	}
+}
@@ -0,0 +1,202 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+func binaryTestNetwork() *network.Network {
+	return &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(4, 16),
+			layer.Tanh(16),
+			layer.Sequential(layer.Dense(16, 8), layer.Tanh(8)),
+			layer.Dense(8, 2),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	model := binaryTestNetwork()
+	dir := t.TempDir()
+
+	json_path := filepath.Join(dir, "model.json")
+	binary_path := filepath.Join(dir, "model.gonn")
+	float32_path := filepath.Join(dir, "model32.gonn")
+	for fpath, opts := range map[string][]network.SaveOption{
+		json_path:    nil,
+		binary_path:  {network.Binary()},
+		float32_path: {network.Float32()},
+	} {
+		if err := model.Save(fpath, opts...); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	json_info, _ := os.Stat(json_path)
+	binary_info, _ := os.Stat(binary_path)
+	float32_info, _ := os.Stat(float32_path)
+	if binary_info.Size() >= json_info.Size() || float32_info.Size() >= binary_info.Size() {
+		t.Fatalf(
+			"expected json > binary > float32 binary, got %d, %d and %d bytes",
+			json_info.Size(), binary_info.Size(), float32_info.Size(),
+		)
+	}
+
+	input := mat.NewDense(1, 4, []float64{0.1, -0.2, 0.3, 0.9})
+	expected_output := model.Predict(input)
+
+	for _, opts := range [][]network.LoadOption{nil, {network.MemoryMap()}} {
+		var loaded network.Network
+		if err := loaded.Load(binary_path, opts...); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if result := loaded.Predict(input); !mat.Equal(expected_output, result) {
+			t.Fatalf(
+				"Binary model output didn't match\nExpected = %v\nGot = %v\n",
+				mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+				mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+			)
+		}
+
+		var loaded32 network.Network
+		if err := loaded32.Load(float32_path, opts...); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if result := loaded32.Predict(input); !mat.EqualApprox(expected_output, result, 1e-5) {
+			t.Fatalf(
+				"float32 model output too far off\nExpected = %v\nGot = %v\n",
+				mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+				mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+			)
+		}
+
+		// Load detects the JSON format on its own too
+		var loaded_json network.Network
+		if err := loaded_json.Load(json_path, opts...); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+}
+
+func TestMemoryMappedModelCanTrain(t *testing.T) {
+	model := binaryTestNetwork()
+	fpath := filepath.Join(t.TempDir(), "model.gonn")
+	if err := model.Save(fpath, network.Binary()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	before, _ := os.ReadFile(fpath)
+
+	var loaded network.Network
+	if err := loaded.Load(fpath, network.MemoryMap()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	inputs := []*mat.Dense{mat.NewDense(1, 4, []float64{1, 2, 3, 4})}
+	outputs := []*mat.Dense{mat.NewDense(1, 2, []float64{0.5, -0.5})}
+	loaded.Train(inputs, outputs, 5, 0.1)
+
+	after, _ := os.ReadFile(fpath)
+	if !bytes.Equal(before, after) {
+		t.Fatalf("training a memory-mapped model changed the file on disk")
+	}
+}
+
+// mappings counts the mappings of fpath in /proc/self/maps, or returns -1
+// where there is no such file.
+func mappings(fpath string) int {
+	maps, err := os.ReadFile("/proc/self/maps")
+	if err != nil {
+		return -1
+	}
+	return bytes.Count(maps, []byte(fpath))
+}
+
+func TestMemoryMappedModelClose(t *testing.T) {
+	fpath := filepath.Join(t.TempDir(), "model.gonn")
+	if err := binaryTestNetwork().Save(fpath, network.Binary()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mappings(fpath) < 0 {
+		t.Skip("can't list the mappings of the process")
+	}
+
+	var loaded network.Network
+	for i := 0; i < 3; i++ {
+		if err := loaded.Load(fpath, network.MemoryMap()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if got := mappings(fpath); got != 1 {
+		t.Fatalf("expected loading again to release the previous mapping, got %d mappings", got)
+	}
+
+	input := mat.NewDense(1, 4, []float64{1, 2, 3, 4})
+	expected := loaded.Predict(input)
+	if err := loaded.Close(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := mappings(fpath); got != 0 {
+		t.Fatalf("expected Close to unmap the file, got %d mappings", got)
+	}
+	// the weights were copied out of the mapping
+	if got := loaded.Predict(input); !mat.Equal(expected, got) {
+		t.Fatalf("network changed after Close")
+	}
+	if err := loaded.Close(); err != nil {
+		t.Fatalf("expected closing twice to do nothing, got %v", err)
+	}
+}
+
+func TestBinaryRejectsBadFiles(t *testing.T) {
+	var buf bytes.Buffer
+	if err := binaryTestNetwork().Encode(&buf, network.Binary()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data := buf.Bytes()
+
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-3] ^= 0xff
+
+	for name, bad := range map[string][]byte{
+		"truncated": data[:len(data)-8],
+		"header":    data[:40],
+		"tampered":  tampered,
+	} {
+		var loaded network.Network
+		if _, err := loaded.ReadFrom(bytes.NewReader(bad)); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+
+	var graph network.Graph
+	if _, err := graph.ReadFrom(bytes.NewReader(data)); err == nil {
+		t.Errorf("expected an error reading a network as a graph, got none")
+	}
+}
+
+func TestBinaryGraph(t *testing.T) {
+	graph := residualGraph(t)
+
+	var buf bytes.Buffer
+	if err := graph.Encode(&buf, network.Binary()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var loaded network.Graph
+	if _, err := loaded.ReadFrom(&buf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	input := mat.NewDense(1, 2, []float64{1, 2})
+	if !mat.Equal(graph.Predict(input)[0], loaded.Predict(input)[0]) {
+		t.Fatalf("binary graph output didn't match")
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+func xorData() ([]*mat.Dense, []*mat.Dense) {
+	dataset := datasets.XOR()
+	return dataset.Inputs, dataset.Targets
+}
+
+func halveEvery2(epoch int, rate float64) float64 {
+	return rate / float64(int(1)<<(epoch/2))
+}
+
+func TestCheckpointResume(t *testing.T) {
+	inputs, outputs := xorData()
+	dir := t.TempDir()
+
+	initial := filepath.Join(dir, "initial.json")
+	model := network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), layer.Dense(3, 1), layer.Tanh(1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	if err := model.Save(initial); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// uninterrupted run
+	var expected network.Network
+	if err := expected.Load(initial); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected.Schedule = halveEvery2
+	expected.Train(inputs, outputs, 6, 0.1)
+
+	// checkpointed run
+	var checkpointed network.Network
+	if err := checkpointed.Load(initial); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	checkpoints := &network.Checkpointer{Dir: filepath.Join(dir, "checkpoints"), Every: 2, KeepLast: 2}
+	checkpointed.Schedule = halveEvery2
+	checkpointed.Checkpoints = checkpoints
+	checkpointed.Train(inputs, outputs, 6, 0.1)
+
+	paths, err := filepath.Glob(filepath.Join(checkpoints.Dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[0]) != "checkpoint-00000004.json" || filepath.Base(paths[1]) != "checkpoint-00000006.json" {
+		t.Fatalf("expected the checkpoints of epoch 4 and 6 to be kept, got %v", paths)
+	}
+	latest, err := checkpoints.Latest()
+	if err != nil || latest != paths[1] {
+		t.Fatalf("expected latest checkpoint %s, got %s (%v)", paths[1], latest, err)
+	}
+
+	// a fresh process picks up at epoch 4
+	resumed := network.Network{Schedule: halveEvery2}
+	if err := resumed.LoadCheckpoint(paths[0]); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resumed.State.Epoch != 4 || resumed.State.Epochs != 6 || resumed.State.Rate != 0.1 || resumed.State.RNG == nil {
+		t.Fatalf("unexpected train state %+v", resumed.State)
+	}
+	if err := resumed.Resume(inputs, outputs); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for i, input := range inputs {
+		expected_output := expected.Predict(input)
+		result := resumed.Predict(input)
+		if !mat.Equal(expected_output, result) {
+			t.Fatalf(
+				"sample %d: resumed run didn't match the uninterrupted one\nExpected = %v\nGot = %v\n", i,
+				mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+				mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+			)
+		}
+	}
+}
+
+func TestCheckpointErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := (&network.Checkpointer{Dir: dir}).Latest(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist for an empty directory, got %v", err)
+	}
+
+	model := network.Network{Layers: []layer.Layer{layer.Dense(2, 1)}}
+	fpath := filepath.Join(dir, "model.json")
+	if err := model.Save(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var loaded network.Network
+	if err := loaded.LoadCheckpoint(fpath); err == nil {
+		t.Fatalf("expected error loading a model file as a checkpoint, got none")
+	}
+}
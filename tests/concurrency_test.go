@@ -0,0 +1,128 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/preprocess"
+	"gonum.org/v1/gonum/mat"
+)
+
+/*
+	These tests call Predict from many goroutines on one model. Run them
+	with the race detector to check that Predict doesn't write to shared
+	state:
+
+	go test -race ./tests -run Concurrent
+*/
+
+const goroutines, calls = 8, 50
+
+// sampleInput is a different input for every goroutine and call.
+func sampleInput(g, i, size int) *mat.Dense {
+	values := make([]float64, size)
+	for j := range values {
+		values[j] = float64((g*calls+i)%17-8) / float64(j+3)
+	}
+	return mat.NewDense(1, size, values)
+}
+
+// predictConcurrently checks that every concurrent call gives the same
+// result as a sequential one.
+func predictConcurrently(t *testing.T, size int, predict func(*mat.Dense) *mat.Dense) {
+	expected := make([][]*mat.Dense, goroutines)
+	for g := range expected {
+		expected[g] = make([]*mat.Dense, calls)
+		for i := range expected[g] {
+			expected[g][i] = predict(sampleInput(g, i, size))
+		}
+	}
+
+	errs := make(chan error, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				if got := predict(sampleInput(g, i, size)); got == nil || !mat.Equal(got, expected[g][i]) {
+					errs <- fmt.Errorf("goroutine %d call %d: expected %v, got %v", g, i, expected[g][i], got)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentNetworkPredict(t *testing.T) {
+	samples := mat.NewDense(3, 4, []float64{1, 2, 3, 4, 2, 0, 1, 1, -1, 5, 2, 0})
+	pipeline := preprocess.NewPipeline(preprocess.Standard())
+	if err := pipeline.Fit(samples); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	model := &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(4, 8), layer.Tanh(8),
+			layer.Sequential(layer.Dense(8, 8), layer.Tanh(8)),
+			layer.Dense(8, 2),
+		},
+		Preprocess: pipeline,
+	}
+	loaded := cloneNetwork(t, model)
+	predictConcurrently(t, 4, loaded.Predict)
+
+	// Predict leaves what Forward stored for BackProp alone
+	if _, err := loaded.Forward(sampleInput(0, 0, 4)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	dense := loaded.Layers[0].(*layer.DenseLayer)
+	stored := dense.Input
+	loaded.Predict(sampleInput(1, 1, 4))
+	if dense.Input != stored {
+		t.Fatalf("Predict overwrote the input stored by Forward")
+	}
+}
+
+func TestConcurrentPredictFloat32(t *testing.T) {
+	model := float32Network()
+	model.InferFloat32 = true
+	predictConcurrently(t, 32, model.Predict)
+}
+
+func TestConcurrentGraphPredict(t *testing.T) {
+	input := network.Input()
+	left := network.Apply(layer.Tanh(3), network.Apply(layer.Dense(3, 3), input))
+	right := network.Apply(layer.Dense(3, 3), input)
+	sum := network.Merge(layer.Add(), left, right)
+	product := network.Merge(layer.Multiply(), sum, left)
+	output := network.Merge(layer.Concat(), product, right)
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{output})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	predictConcurrently(t, 3, func(x *mat.Dense) *mat.Dense {
+		outputs := graph.Predict(x)
+		if outputs == nil {
+			return nil
+		}
+		return outputs[0]
+	})
+}
+
+func TestConcurrentPredictWithoutInfer(t *testing.T) {
+	// scaleLayer only has Forward, so Predict runs one call at a time
+	model := &network.Network{
+		Layers: []layer.Layer{layer.Dense(2, 2), layer.FromMatrix(&scaleLayer{Factor: 2, Shift: mat.NewDense(1, 2, []float64{1, -1})})},
+	}
+	predictConcurrently(t, 2, model.Predict)
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// cancellingDataset cancels a context when sample number at is read.
+type cancellingDataset struct {
+	data.Dataset
+	reads, at int
+	cancel    func()
+}
+
+func (dataset *cancellingDataset) Get(i int) (data.Sample, error) {
+	dataset.reads++
+	if dataset.reads == dataset.at {
+		dataset.cancel()
+	}
+	return dataset.Dataset.Get(i)
+}
+
+func xorNetwork() *network.Network {
+	return &network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), layer.Dense(3, 1), layer.Tanh(1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+}
+
+func TestTrainContextCancel(t *testing.T) {
+	inputs, outputs := xorData()
+	xor, err := data.FromSlices(inputs, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 4 samples per epoch, cancelled halfway through the third epoch
+	dataset := &cancellingDataset{Dataset: xor, at: 10, cancel: cancel}
+	loader := &data.DataLoader{Dataset: dataset, Shuffle: true}
+
+	model := xorNetwork()
+	model.Checkpoints = &network.Checkpointer{Dir: t.TempDir(), OnCancel: true}
+	model.State = network.TrainState{Epochs: 5, Rate: 0.1, RNG: rand.NewPCG(1, 2)}
+
+	err = model.ResumeLoaderContext(ctx, loader)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if model.State.Epoch != 2 {
+		t.Fatalf("expected 2 finished epochs, got %d", model.State.Epoch)
+	}
+
+	// the random state is back at the start of the third epoch
+	rng := rand.NewPCG(1, 2)
+	for i := 0; i < 2; i++ {
+		batches, _ := (&data.DataLoader{Dataset: xor, Shuffle: true}).Epoch(rng)
+		batches.Close()
+	}
+	expected, _ := rng.MarshalBinary()
+	got, _ := model.State.RNG.MarshalBinary()
+	if !bytes.Equal(expected, got) {
+		t.Fatalf("expected the random state of the third epoch's start")
+	}
+
+	// the final checkpoint resumes the run
+	latest, err := model.Checkpoints.Latest()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var resumed network.Network
+	if err := resumed.LoadCheckpoint(latest); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resumed.State.Epoch != 2 {
+		t.Fatalf("expected the checkpoint at epoch 2, got %d", resumed.State.Epoch)
+	}
+	if err := resumed.ResumeLoaderContext(context.Background(), &data.DataLoader{Dataset: xor, Shuffle: true}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resumed.State.Epoch != 5 {
+		t.Fatalf("expected 5 epochs to be done, got %d", resumed.State.Epoch)
+	}
+}
+
+func TestTrainContextDeadline(t *testing.T) {
+	inputs, outputs := xorData()
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	model := xorNetwork()
+	if err := model.TrainContext(ctx, inputs, outputs, 100, 0.1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if model.State.Epoch != 0 {
+		t.Fatalf("expected no finished epoch, got %d", model.State.Epoch)
+	}
+
+	model.Workers, model.Hogwild = 2, true
+	if err := model.TrainContext(ctx, inputs, outputs, 100, 0.1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("hogwild: expected context.DeadlineExceeded, got %v", err)
+	}
+
+	graph := chainGraph(t, xorNetwork())
+	graph_inputs := [][]*mat.Dense{{inputs[0]}}
+	graph_outputs := [][]*mat.Dense{{outputs[0]}}
+	if err := graph.TrainContext(ctx, graph_inputs, graph_outputs, 100, 0.1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("graph: expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestPredictContext(t *testing.T) {
+	model := xorNetwork()
+	graph := chainGraph(t, model)
+	input := mat.NewDense(1, 2, []float64{1, 0})
+
+	result, err := model.PredictContext(context.Background(), input)
+	if err != nil || !mat.Equal(result, model.Predict(input)) {
+		t.Fatalf("expected the Predict result, got %v (%v)", result, err)
+	}
+	results, err := graph.PredictContext(context.Background(), input)
+	if err != nil || !mat.Equal(results[0], result) {
+		t.Fatalf("expected the Predict result, got %v (%v)", results, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := model.PredictContext(ctx, input); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := graph.PredictContext(ctx, input); !errors.Is(err, context.Canceled) {
+		t.Fatalf("graph: expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+const testCSV = `age,color,score,label
+10,red,1.5,yes
+20,blue,,no
+,green,2.5,yes
+40,red,3.5,no
+`
+
+func rows(matrices []*mat.Dense) [][]float64 {
+	result := make([][]float64, len(matrices))
+	for i, m := range matrices {
+		result[i] = mat.Row(nil, 0, m)
+	}
+	return result
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   data.CSVConfig
+		inputs   [][]float64
+		targets  [][]float64
+		features []string
+	}{
+		{
+			"drop missing",
+			data.CSVConfig{
+				Features:    []data.Column{data.Col("age"), data.Col("color")},
+				Targets:     []data.Column{data.Col("label")},
+				Categorical: []data.Column{data.Col("color"), data.Col("label")},
+			},
+			[][]float64{{10, 0, 0, 1}, {20, 1, 0, 0}, {40, 0, 0, 1}},
+			[][]float64{{0, 1}, {1, 0}, {1, 0}},
+			[]string{"age", "color=blue", "color=green", "color=red"},
+		},
+		{
+			"fill mean, features by index",
+			data.CSVConfig{
+				Features: []data.Column{data.ColAt(0)},
+				Targets:  []data.Column{data.Col("score")},
+				Missing:  data.FillMean,
+			},
+			[][]float64{{10}, {20}, {70.0 / 3}, {40}},
+			[][]float64{{1.5}, {2.5}, {2.5}, {3.5}},
+			[]string{"age"},
+		},
+		{
+			"fill constant, every other column is a feature",
+			data.CSVConfig{
+				Targets:     []data.Column{data.Col("score")},
+				Categorical: []data.Column{data.Col("color"), data.Col("label")},
+				Missing:     data.FillConstant,
+				FillValue:   -1,
+			},
+			[][]float64{{10, 0, 0, 1, 0, 1}, {20, 1, 0, 0, 1, 0}, {-1, 0, 1, 0, 0, 1}, {40, 0, 0, 1, 1, 0}},
+			[][]float64{{1.5}, {-1}, {2.5}, {3.5}},
+			[]string{"age", "color=blue", "color=green", "color=red", "label=no", "label=yes"},
+		},
+	}
+
+	for _, test := range tests {
+		dataset, err := data.ReadCSV(strings.NewReader(testCSV), test.config)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", test.name, err)
+		}
+		if !equalBatches(rows(dataset.Inputs), test.inputs) || !equalBatches(rows(dataset.Targets), test.targets) {
+			t.Fatalf("%s: expected %v -> %v, got %v -> %v", test.name,
+				test.inputs, test.targets, rows(dataset.Inputs), rows(dataset.Targets))
+		}
+		if got := strings.Join(dataset.FeatureNames(), " "); got != strings.Join(test.features, " ") {
+			t.Fatalf("%s: expected features %v, got %s", test.name, test.features, got)
+		}
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	configs := []data.CSVConfig{
+		{},
+		{Targets: []data.Column{data.Col("nope")}},
+		{Targets: []data.Column{data.ColAt(9)}},
+		{Targets: []data.Column{data.Col("label")}, Features: []data.Column{data.Col("label")}},
+		// color isn't numeric
+		{Targets: []data.Column{data.Col("label")}, Categorical: []data.Column{data.Col("label")}},
+		{NoHeader: true, Targets: []data.Column{data.Col("label")}},
+	}
+	for i, config := range configs {
+		if _, err := data.ReadCSV(strings.NewReader(testCSV), config); err == nil {
+			t.Fatalf("config %d: expected error, got none", i)
+		}
+	}
+}
+
+func TestCSVStream(t *testing.T) {
+	fpath := filepath.Join(t.TempDir(), "data.csv")
+	// no header, semicolon separated
+	content := strings.ReplaceAll(strings.SplitN(testCSV, "\n", 2)[1], ",", ";")
+	if err := os.WriteFile(fpath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := data.CSVConfig{
+		NoHeader:    true,
+		Comma:       ';',
+		Features:    []data.Column{data.ColAt(0), data.ColAt(1)},
+		Targets:     []data.Column{data.ColAt(2)},
+		Categorical: []data.Column{data.ColAt(1)},
+		Missing:     data.FillMean,
+	}
+	in_memory, err := data.LoadCSV(fpath, config)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stream, err := data.OpenCSV(fpath, config)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// two epochs over the stream give the same rows as the in-memory dataset
+	for epoch := 0; epoch < 2; epoch++ {
+		iterator, err := stream.Open()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		i := 0
+		for ; ; i++ {
+			sample, err := iterator.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !mat.Equal(sample.Input, in_memory.Inputs[i]) || !mat.Equal(sample.Target, in_memory.Targets[i]) {
+				t.Fatalf("row %d of the stream didn't match", i)
+			}
+		}
+		iterator.Close()
+		if i != in_memory.Len() {
+			t.Fatalf("expected %d rows, got %d", in_memory.Len(), i)
+		}
+	}
+}
+
+func TestTrainOnCSV(t *testing.T) {
+	dataset, err := data.ReadCSV(strings.NewReader("x,y,xor\n0,0,0\n0,1,1\n1,0,1\n1,1,0\n"), data.CSVConfig{
+		Targets: []data.Column{data.Col("xor")},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	model := network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), layer.Dense(3, 1), layer.Tanh(1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	model.Train(dataset.Inputs, dataset.Targets, 2, 0.1)
+	if model.State.Epoch != 2 {
+		t.Fatalf("expected 2 epochs to be done, got %d", model.State.Epoch)
+	}
+}
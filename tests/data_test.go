@@ -0,0 +1,279 @@
+package test
+
+import (
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// countingDataset has n samples whose input and target are their index.
+func countingDataset(n int) *data.SliceDataset {
+	inputs := make([]*mat.Dense, n)
+	targets := make([]*mat.Dense, n)
+	for i := range inputs {
+		inputs[i] = mat.NewDense(1, 1, []float64{float64(i)})
+		targets[i] = mat.NewDense(1, 1, []float64{float64(i)})
+	}
+	dataset, err := data.FromSlices(inputs, targets)
+	if err != nil {
+		panic(err)
+	}
+	return dataset
+}
+
+// readAll returns the inputs of every batch of one epoch.
+func readAll(t *testing.T, loader *data.DataLoader, rng rand.Source) [][]float64 {
+	batches, err := loader.Epoch(rng)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer batches.Close()
+
+	result := make([][]float64, 0)
+	for {
+		batch, err := batches.Next()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !mat.Equal(batch.Inputs, batch.Targets) {
+			t.Fatalf("inputs and targets of a batch got mixed up")
+		}
+		rows, _ := batch.Inputs.Dims()
+		if rows != batch.Size {
+			t.Fatalf("batch of size %d has %d rows", batch.Size, rows)
+		}
+		result = append(result, mat.Col(nil, 0, batch.Inputs))
+	}
+}
+
+func equalBatches(a, b [][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestFromSlicesValidates(t *testing.T) {
+	inputs := []*mat.Dense{mat.NewDense(1, 2, nil), mat.NewDense(1, 2, nil)}
+	if _, err := data.FromSlices(inputs, []*mat.Dense{mat.NewDense(1, 1, nil)}); err == nil {
+		t.Fatalf("expected error for mismatched lengths, got none")
+	}
+	if _, err := data.FromSlices(inputs, []*mat.Dense{mat.NewDense(1, 1, nil), mat.NewDense(1, 2, nil)}); err == nil {
+		t.Fatalf("expected error for mismatched shapes, got none")
+	}
+}
+
+func TestDataLoaderBatches(t *testing.T) {
+	dataset := countingDataset(10)
+
+	batches := readAll(t, &data.DataLoader{Dataset: dataset, BatchSize: 4}, nil)
+	expected := [][]float64{{0, 1, 2, 3}, {4, 5, 6, 7}, {8, 9}}
+	if !equalBatches(batches, expected) {
+		t.Fatalf("expected %v, got %v", expected, batches)
+	}
+
+	batches = readAll(t, &data.DataLoader{Dataset: dataset, BatchSize: 4, DropLast: true}, nil)
+	if !equalBatches(batches, expected[:2]) {
+		t.Fatalf("expected %v, got %v", expected[:2], batches)
+	}
+
+	prefetched := readAll(t, &data.DataLoader{Dataset: dataset, BatchSize: 3, Prefetch: 4, Workers: 3}, nil)
+	expected = [][]float64{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, {9}}
+	if !equalBatches(prefetched, expected) {
+		t.Fatalf("expected %v, got %v", expected, prefetched)
+	}
+}
+
+func TestDataLoaderShuffle(t *testing.T) {
+	dataset := countingDataset(20)
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 5, Shuffle: true, Prefetch: 2, Workers: 2}
+
+	first := readAll(t, loader, rand.NewPCG(1, 2))
+	again := readAll(t, loader, rand.NewPCG(1, 2))
+	if !equalBatches(first, again) {
+		t.Fatalf("same seed gave different orders: %v and %v", first, again)
+	}
+
+	rng := rand.NewPCG(1, 2)
+	readAll(t, loader, rng)
+	second := readAll(t, loader, rng)
+	if equalBatches(first, second) {
+		t.Fatalf("expected a new order every epoch, got %v twice", first)
+	}
+
+	seen := make(map[float64]bool)
+	for _, batch := range first {
+		for _, v := range batch {
+			seen[v] = true
+		}
+	}
+	if len(seen) != 20 {
+		t.Fatalf("expected every sample once, got %v", first)
+	}
+
+	if _, err := loader.Epoch(nil); err == nil {
+		t.Fatalf("expected error shuffling without a random source, got none")
+	}
+}
+
+func TestDataLoaderStream(t *testing.T) {
+	generator := data.StreamFunc(func() (data.Iterator, error) {
+		i := 0
+		return data.IteratorFunc(func() (data.Sample, error) {
+			if i == 7 {
+				return data.Sample{}, io.EOF
+			}
+			i++
+			value := mat.NewDense(1, 1, []float64{float64(i - 1)})
+			return data.Sample{Input: value, Target: value}, nil
+		}), nil
+	})
+
+	expected := [][]float64{{0, 1, 2}, {3, 4, 5}, {6}}
+	for _, prefetch := range []int{0, 2} {
+		batches := readAll(t, &data.DataLoader{Stream: generator, BatchSize: 3, Prefetch: prefetch}, nil)
+		if !equalBatches(batches, expected) {
+			t.Fatalf("prefetch %d: expected %v, got %v", prefetch, expected, batches)
+		}
+		batches = readAll(t, &data.DataLoader{Stream: generator, BatchSize: 3, Prefetch: prefetch, DropLast: true}, nil)
+		if !equalBatches(batches, expected[:2]) {
+			t.Fatalf("prefetch %d: expected %v, got %v", prefetch, expected[:2], batches)
+		}
+	}
+
+	// closing before the end stops the prefetching goroutine
+	batches, err := (&data.DataLoader{Stream: generator, Prefetch: 1}).Epoch(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	batches.Next()
+	if err := batches.Close(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	samples := make(chan data.Sample, 2)
+	samples <- data.Sample{Input: mat.NewDense(1, 1, nil), Target: mat.NewDense(1, 1, nil)}
+	close(samples)
+	stream := data.ChanStream(samples)
+	if got := readAll(t, &data.DataLoader{Stream: stream}, nil); len(got) != 1 {
+		t.Fatalf("expected 1 batch from the channel, got %d", len(got))
+	}
+	if _, err := (&data.DataLoader{Stream: stream}).Epoch(nil); err == nil {
+		t.Fatalf("expected error reading a channel stream twice, got none")
+	}
+
+	// only one of several concurrent readers gets the channel
+	stream = data.ChanStream(make(chan data.Sample))
+	opened := make(chan bool, 8)
+	for i := 0; i < 8; i++ {
+		go func() {
+			_, err := stream.Open()
+			opened <- err == nil
+		}()
+	}
+	count := 0
+	for i := 0; i < 8; i++ {
+		if <-opened {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected one concurrent Open to succeed, got %d", count)
+	}
+}
+
+func TestDataLoaderChecksRows(t *testing.T) {
+	good := data.Sample{Input: mat.NewDense(2, 1, nil), Target: mat.NewDense(2, 1, nil)}
+	bad := data.Sample{Input: mat.NewDense(2, 1, nil), Target: mat.NewDense(1, 1, nil)}
+	cases := map[string][]data.Sample{
+		"single sample": {bad},
+		"first sample":  {bad, good},
+		"later sample":  {good, bad},
+	}
+	for name, batch := range cases {
+		samples := make(chan data.Sample, len(batch))
+		for _, sample := range batch {
+			samples <- sample
+		}
+		close(samples)
+		batches, err := (&data.DataLoader{Stream: data.ChanStream(samples), BatchSize: len(batch)}).Epoch(nil)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if _, err := batches.Next(); err == nil || err == io.EOF {
+			t.Fatalf("%s: expected an error for a sample with fewer target rows, got %v", name, err)
+		}
+		batches.Close()
+	}
+}
+
+func TestDenseBatch(t *testing.T) {
+	dense := layer.Dense(2, 3)
+	single := layer.Dense(2, 3)
+	single.Weights.Copy(dense.Weights)
+	single.Biases.Copy(dense.Biases)
+
+	batch := mat.NewDense(2, 2, []float64{0.5, -1, 2, 0.25})
+	output, err := dense.ForwardRows(batch)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		row, _ := single.ForwardRows(mat.DenseCopyOf(batch.RowView(i).T()))
+		if !mat.EqualApprox(row, output.RowView(i).T(), 1e-14) {
+			t.Fatalf("row %d of the batch didn't match the single sample output", i)
+		}
+	}
+
+	// the bias gradient is the sum of the per sample gradients
+	out_grad := mat.NewDense(2, 3, []float64{1, 2, 3, 10, 20, 30})
+	biases := mat.DenseCopyOf(dense.Biases)
+	dense.BackwardRows(out_grad, 0.1)
+	expected_biases := mat.NewDense(1, 3, []float64{-1.1, -2.2, -3.3})
+	expected_biases.Add(expected_biases, biases)
+	if !mat.EqualApprox(dense.Biases, expected_biases, 1e-14) {
+		t.Fatalf("expected biases %v, got %v", mat.Formatted(expected_biases), mat.Formatted(dense.Biases))
+	}
+}
+
+func TestTrainLoader(t *testing.T) {
+	inputs, outputs := xorData()
+	dataset, err := data.FromSlices(inputs, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model := network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), layer.Dense(3, 1), layer.Tanh(1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 2, Shuffle: true, Prefetch: 2}
+	model.TrainLoader(loader, 5, 0.1)
+	if model.State.Epoch != 5 {
+		t.Fatalf("expected 5 epochs to be done, got %d", model.State.Epoch)
+	}
+
+	if err := model.Resume(inputs, outputs[:3]); err == nil {
+		t.Fatalf("expected error for mismatched inputs and outputs, got none")
+	}
+}
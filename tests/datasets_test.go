@@ -0,0 +1,143 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
+	"gonum.org/v1/gonum/mat"
+)
+
+func sameDataset(a, b *data.SliceDataset) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for i := range a.Inputs {
+		if !mat.Equal(a.Inputs[i], b.Inputs[i]) || !mat.Equal(a.Targets[i], b.Targets[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDatasetsSeeded(t *testing.T) {
+	generators := map[string]struct {
+		generate            func(seed uint64) *data.SliceDataset
+		input_size, targets int
+	}{
+		"moons":   {func(seed uint64) *data.SliceDataset { return datasets.Moons(50, 0.1, seed) }, 2, 1},
+		"circles": {func(seed uint64) *data.SliceDataset { return datasets.Circles(50, 0.05, 0.5, seed) }, 2, 1},
+		"spirals": {func(seed uint64) *data.SliceDataset { return datasets.Spirals(51, 3, 0.1, seed) }, 2, 3},
+		"sine":    {func(seed uint64) *data.SliceDataset { return datasets.Sine(50, 0.1, seed) }, 1, 1},
+		"dsr":     {func(seed uint64) *data.SliceDataset { return datasets.DSR(50, seed) }, 10, 10},
+		"adding":  {func(seed uint64) *data.SliceDataset { return datasets.Adding(50, 8, seed) }, 16, 1},
+		"copy":    {func(seed uint64) *data.SliceDataset { return datasets.CopyMemory(50, 3, 2, 4, seed) }, 8 * 6, 8 * 6},
+	}
+
+	for name, generator := range generators {
+		first := generator.generate(1)
+		if first.Len() < 50 {
+			t.Fatalf("%s: expected at least 50 samples, got %d", name, first.Len())
+		}
+		if _, err := data.FromSlices(first.Inputs, first.Targets); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if _, cols := first.Inputs[0].Dims(); cols != generator.input_size {
+			t.Fatalf("%s: expected inputs of size %d, got %d", name, generator.input_size, cols)
+		}
+		if _, cols := first.Targets[0].Dims(); cols != generator.targets {
+			t.Fatalf("%s: expected targets of size %d, got %d", name, generator.targets, cols)
+		}
+		if !sameDataset(first, generator.generate(1)) {
+			t.Fatalf("%s: the same seed gave different samples", name)
+		}
+		if sameDataset(first, generator.generate(2)) {
+			t.Fatalf("%s: different seeds gave the same samples", name)
+		}
+	}
+}
+
+func TestClassificationDatasets(t *testing.T) {
+	xor := datasets.XOR()
+	for i := range xor.Inputs {
+		a, b, target := xor.Inputs[i].At(0, 0), xor.Inputs[i].At(0, 1), xor.Targets[i].At(0, 0)
+		if target != math.Abs(a-b) {
+			t.Fatalf("%v xor %v gave %v", a, b, target)
+		}
+	}
+
+	// without noise every point lies on its circle
+	circles := datasets.Circles(20, 0, 0.3, 7)
+	for i, input := range circles.Inputs {
+		radius := math.Hypot(input.At(0, 0), input.At(0, 1))
+		expected := 1.0
+		if circles.Targets[i].At(0, 0) == 1 {
+			expected = 0.3
+		}
+		if math.Abs(radius-expected) > 1e-12 {
+			t.Fatalf("sample %d: expected radius %v, got %v", i, expected, radius)
+		}
+	}
+
+	spirals := datasets.Spirals(9, 3, 0, 7)
+	for i, target := range spirals.Targets {
+		if mat.Sum(target) != 1 || target.At(0, i%3) != 1 {
+			t.Fatalf("sample %d: expected class %d one-hot, got %v", i, i%3, mat.Formatted(target))
+		}
+	}
+}
+
+func TestSequenceTasks(t *testing.T) {
+	dsr := datasets.DSR(20, 3)
+	for i, input := range dsr.Inputs {
+		row := input.RawRowView(0)
+		found := []float64{}
+		for _, value := range row[:8] {
+			if value == datasets.DSRTarget1 || value == datasets.DSRTarget2 {
+				found = append(found, value)
+			}
+		}
+		target := dsr.Targets[i].RawRowView(0)
+		if len(found) != 2 || target[8] != found[0] || target[9] != found[1] || row[8] != datasets.DSRPrompt1 {
+			t.Fatalf("sample %d: input %v doesn't match target %v", i, row, target)
+		}
+	}
+
+	adding := datasets.Adding(20, 6, 3)
+	for i, input := range adding.Inputs {
+		sum, markers := 0.0, 0
+		for step := 0; step < 6; step++ {
+			if input.At(0, 2*step+1) == 1 {
+				sum += input.At(0, 2*step)
+				markers++
+			}
+		}
+		if markers != 2 || sum != adding.Targets[i].At(0, 0) {
+			t.Fatalf("sample %d: expected 2 markers summing to %v, got %d summing to %v",
+				i, adding.Targets[i].At(0, 0), markers, sum)
+		}
+	}
+
+	// 2 symbols, 4 values per step: blank, the symbols and the delimiter
+	length, delay, width := 3, 2, 4
+	copy_memory := datasets.CopyMemory(20, length, delay, 2, 3)
+	for i, input := range copy_memory.Inputs {
+		target := copy_memory.Targets[i]
+		step := func(m *mat.Dense, s int) []float64 { return m.RawRowView(0)[s*width : (s+1)*width] }
+		for s := 0; s < length; s++ {
+			if !equalBatches([][]float64{step(input, s)}, [][]float64{step(target, s+length+delay)}) {
+				t.Fatalf("sample %d: step %d isn't copied", i, s)
+			}
+			if step(input, s)[0] == 1 || step(target, s)[0] != 1 {
+				t.Fatalf("sample %d: step %d should hold a symbol and have a blank target", i, s)
+			}
+		}
+		if step(input, length+delay)[width-1] != 1 {
+			t.Fatalf("sample %d: expected the delimiter at step %d", i, length+delay)
+		}
+		if mat.Sum(input) != float64(2*length+delay) || mat.Sum(target) != float64(2*length+delay) {
+			t.Fatalf("sample %d: expected exactly one value per step", i)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/f32"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// randomDense has values uniform in [-1, 1).
+func randomDense(rows, cols int, seed uint64) *mat.Dense {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	values := make([]float64, rows*cols)
+	for i := range values {
+		values[i] = 2*rng.Float64() - 1
+	}
+	return mat.NewDense(rows, cols, values)
+}
+
+// maxDiff is the largest absolute difference between a and b.
+func maxDiff(a, b mat.Matrix) float64 {
+	var diff mat.Dense
+	diff.Sub(a, b)
+	return maxAbs(&diff)
+}
+
+func maxAbs(m mat.Matrix) float64 {
+	return math.Max(mat.Max(m), -mat.Min(m))
+}
+
+func TestF32Conversion(t *testing.T) {
+	dense := mat.NewDense(2, 3, []float64{1, -2.5, 0.125, 3, 1e6, -0.75})
+	converted := f32.FromDense(dense)
+	if converted.Rows != 2 || converted.Cols != 3 || converted.At(1, 1) != 1e6 {
+		t.Fatalf("conversion didn't keep the layout, got %v", converted)
+	}
+	if !mat.Equal(dense, converted.Dense()) {
+		t.Fatalf("round trip changed the values, got %v", mat.Formatted(converted.Dense()))
+	}
+	if got := f32.FromDense(mat.NewDense(1, 1, []float64{0.1})).At(0, 0); got != float32(0.1) {
+		t.Fatalf("expected 0.1 rounded to float32, got %v", got)
+	}
+}
+
+func TestF32Mul(t *testing.T) {
+	// sizes below, at and across the tile size
+	for _, size := range [][3]int{{1, 1, 1}, {3, 70, 65}, {64, 64, 64}, {130, 129, 67}} {
+		m, k, n := size[0], size[1], size[2]
+		a, b := randomDense(m, k, 1), randomDense(k, n, 2)
+		a32, b32 := f32.FromDense(a), f32.FromDense(b)
+
+		// the exact product of the float32 inputs
+		var expected mat.Dense
+		expected.Mul(a32.Dense(), b32.Dense())
+
+		got := f32.Mul(a32, b32)
+		if diff := maxDiff(&expected, got.Dense()); diff > 1e-6*float64(k) {
+			t.Fatalf("%dx%d * %dx%d: product is off by %v", m, k, k, n, diff)
+		}
+
+		f32.MulAdd(got, a32, b32)
+		expected.Scale(2, &expected)
+		if diff := maxDiff(&expected, got.Dense()); diff > 2e-6*float64(k) {
+			t.Fatalf("%dx%d * %dx%d: MulAdd is off by %v", m, k, k, n, diff)
+		}
+	}
+}
+
+func float32Network() *network.Network {
+	return &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(32, 64), layer.Tanh(64),
+			layer.Sequential(layer.Dense(64, 16), layer.Tanh(16)),
+			layer.Dense(16, 10),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+}
+
+func TestInferFloat32Accuracy(t *testing.T) {
+	model := float32Network()
+	inputs := randomDense(100, 32, 3)
+
+	expected := model.Predict(inputs)
+	model.InferFloat32 = true
+	got := model.Predict(inputs)
+	if got == nil {
+		t.Fatalf("float32 Predict failed")
+	}
+	// the outputs of random weights reach the tens, so the error is
+	// relative to the largest
+	if diff := maxDiff(expected, got); diff > 1e-5*maxAbs(expected) || diff == 0 {
+		t.Fatalf("expected float32 results within 1e-5 of float64, relatively, off by %v", diff)
+	}
+
+	// training changes the weights, the float32 copy has to follow
+	model.Train([]*mat.Dense{inputs}, []*mat.Dense{randomDense(100, 10, 4)}, 1, 0.01)
+	model.InferFloat32 = false
+	expected = model.Predict(inputs)
+	model.InferFloat32 = true
+	if diff := maxDiff(expected, model.Predict(inputs)); diff > 1e-5*maxAbs(expected) {
+		t.Fatalf("float32 results are off by %v after training", diff)
+	}
+
+	// so do weights changed by hand, once reset
+	model.Layers[3].(*layer.DenseLayer).Biases.Set(0, 0, 100)
+	model.ResetFloat32()
+	if got := model.Predict(inputs).At(0, 0); math.Abs(got-expected.At(0, 0)) < 50 {
+		t.Fatalf("expected the new bias to be used, got %v", got)
+	}
+}
+
+func TestInferFloat32UnsupportedLayer(t *testing.T) {
+	model := &network.Network{
+		Layers:       []layer.Layer{layer.Dense(2, 2), layer.FromMatrix(&scaleLayer{Factor: 2, Shift: mat.NewDense(1, 2, nil)})},
+		InferFloat32: true,
+	}
+	if _, err := model.PredictContext(context.Background(), mat.NewDense(1, 2, nil)); err == nil {
+		t.Fatalf("expected an error for a layer without float32 support, got none")
+	}
+}
+
+func BenchmarkPredictFloat32(b *testing.B) {
+	model := &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(784, 256), layer.Tanh(256),
+			layer.Dense(256, 10),
+		},
+	}
+	inputs := randomDense(64, 784, 1)
+
+	for _, use_float32 := range []bool{false, true} {
+		b.Run(fmt.Sprintf("float32=%v", use_float32), func(b *testing.B) {
+			model.InferFloat32 = use_float32
+			model.Predict(inputs)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				model.Predict(inputs)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+func savedModel(t *testing.T) (string, []byte) {
+	model := network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(2, 3),
+			layer.Tanh(3),
+			layer.Dense(3, 1),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	fpath := filepath.Join(t.TempDir(), "model.json")
+	if err := model.Save(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return fpath, data
+}
+
+func TestModelFileHeader(t *testing.T) {
+	_, data := savedModel(t)
+
+	var file network.JSONModelFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if file.FormatVersion != 1 || file.GoNNVersion != network.Version || file.Kind != "network" {
+		t.Fatalf("unexpected header %+v", file)
+	}
+	if !strings.HasPrefix(file.Checksum, "sha256:") {
+		t.Fatalf("expected sha256 checksum, got %q", file.Checksum)
+	}
+
+	var model network.JSONNewtork
+	json.Unmarshal(file.Model, &model)
+	if got := model.Layers[0].InputShape; len(got) != 1 || got[0] != 2 {
+		t.Fatalf("expected input shape [2], got %v", got)
+	}
+	if got := model.Layers[1].OutputShape; len(got) != 1 || got[0] != 3 {
+		t.Fatalf("expected output shape [3], got %v", got)
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	fpath, data := savedModel(t)
+	dir := filepath.Dir(fpath)
+
+	corrupt := func(name string, data []byte) string {
+		fpath := filepath.Join(dir, name)
+		os.WriteFile(fpath, data, 0o644)
+		return fpath
+	}
+
+	// change a single weight byte without touching the checksum
+	var file network.JSONModelFile
+	json.Unmarshal(data, &file)
+	var model network.JSONNewtork
+	json.Unmarshal(file.Model, &model)
+	weights := bytes.Clone(model.Layers[0].Params["weights"])
+	if weights[40] == 'A' {
+		weights[40] = 'B'
+	} else {
+		weights[40] = 'A'
+	}
+	model.Layers[0].Params["weights"] = weights
+	file.Model, _ = json.Marshal(model)
+	tampered, _ := json.Marshal(file)
+
+	// Dense(2, 3) followed by Dense(1, 1): shapes don't connect
+	bad_chain := network.Network{Layers: []layer.Layer{layer.Dense(2, 3), layer.Dense(1, 1)}}
+	bad_chain_path := filepath.Join(dir, "bad_chain.json")
+	if err := bad_chain.Save(bad_chain_path); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// a validly signed file that refers to a layer type nobody registered
+	json.Unmarshal(data, &file)
+	json.Unmarshal(file.Model, &model)
+	model.Layers[1].Type = "NoSuchLayer"
+	file.Model, _ = json.Marshal(model)
+	sum := sha256.Sum256(file.Model)
+	file.Checksum = "sha256:" + hex.EncodeToString(sum[:])
+	unknown, _ := json.Marshal(file)
+
+	newer := bytes.Replace(data, []byte(`"format_version": 1`), []byte(`"format_version": 99`), 1)
+
+	cases := map[string]string{
+		"truncated":      corrupt("truncated.json", data[:len(data)/2]),
+		"tampered":       corrupt("tampered.json", tampered),
+		"newer version":  corrupt("newer.json", newer),
+		"unversioned":    corrupt("unversioned.json", []byte(`{"Loss": "MSE"}`)),
+		"legacy graph":   corrupt("legacy_graph.json", []byte(`{"Nodes": [], "Loss": "MSE"}`)),
+		"legacy layer":   corrupt("legacy_layer.json", []byte(`{"Layers": [{"type": "Sigmoid"}], "Loss": "MSE"}`)),
+		"legacy weights": corrupt("legacy_weights.json", []byte(`{"Layers": [{"type": "Dense", "weights": "AQAA"}]}`)),
+		"missing file":   filepath.Join(dir, "does-not-exist.json"),
+		"incompatible":   bad_chain_path,
+		"graph as model": corrupt("graph.json", bytes.Replace(data, []byte(`"kind": "network"`), []byte(`"kind": "graph"`), 1)),
+		"unknown type":   corrupt("unknown.json", unknown),
+	}
+
+	for name, fpath := range cases {
+		var loaded network.Network
+		err := loaded.Load(fpath)
+		if err == nil {
+			t.Errorf("%s: expected Load to fail, got no error", name)
+		}
+		if name == "tampered" && !strings.Contains(err.Error(), "checksum") {
+			t.Errorf("tampered: expected checksum error, got %v", err)
+		}
+	}
+}
+
+func TestLoadLegacyModel(t *testing.T) {
+	// written by goNN before model files had format_version
+	fpath := filepath.Join("testdata", "xor_legacy.json")
+	var legacy struct{ Layers []map[string]string }
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(data, &legacy)
+
+	var xor network.Network
+	if err := xor.Load(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(xor.Layers) != 4 || xor.Loss == nil {
+		t.Fatalf("expected 4 layers and a loss, got %d layers", len(xor.Layers))
+	}
+	for _, i := range []int{0, 2} {
+		var expected mat.Dense
+		weights_bin, _ := base64.StdEncoding.DecodeString(legacy.Layers[i]["weights"])
+		expected.UnmarshalBinary(weights_bin)
+		dense, ok := xor.Layers[i].(*layer.DenseLayer)
+		if !ok || !mat.Equal(dense.Weights, &expected) {
+			t.Fatalf("layer %d: expected Dense weights %v, got %v", i, mat.Formatted(&expected), xor.Layers[i])
+		}
+	}
+
+	inputs := mat.NewDense(4, 2, []float64{0, 0, 0, 1, 1, 0, 1, 1})
+	result := xor.Predict(inputs)
+	for i, expected := range []float64{0, 1, 1, 0} {
+		if math.Abs(result.At(i, 0)-expected) > 0.5 {
+			t.Fatalf("expected the trained xor network to output ~%v for %v, got %v", expected, inputs.RawRowView(i), result.At(i, 0))
+		}
+	}
+
+	// saving writes the current format
+	resaved := filepath.Join(t.TempDir(), "xor.json")
+	if err := xor.Save(resaved); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var reloaded network.Network
+	if err := reloaded.Load(resaved); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !mat.Equal(reloaded.Predict(inputs), result) {
+		t.Fatalf("resaved legacy model predicts differently")
+	}
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	model := network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+
+	var buf bytes.Buffer
+	written, err := model.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if written != int64(buf.Len()) {
+		t.Fatalf("WriteTo reported %d bytes, wrote %d", written, buf.Len())
+	}
+
+	var loaded network.Network
+	read, err := loaded.ReadFrom(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if read != written {
+		t.Fatalf("ReadFrom reported %d bytes, expected %d", read, written)
+	}
+
+	input := mat.NewDense(1, 2, []float64{0.2, 0.4})
+	if !mat.Equal(model.Predict(input), loaded.Predict(input)) {
+		t.Fatalf("network read from a buffer doesn't match the original")
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	_, data := savedModel(t)
+	fsys := fstest.MapFS{"models/model.json": &fstest.MapFile{Data: data}}
+
+	var loaded network.Network
+	if err := loaded.LoadFS(fsys, "models/model.json"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(loaded.Layers) != 3 {
+		t.Fatalf("expected 3 layers, got %d", len(loaded.Layers))
+	}
+	if err := loaded.LoadFS(fsys, "models/missing.json"); err == nil {
+		t.Fatalf("expected error for a missing file, got none")
+	}
+}
+
+func TestReadableWeights(t *testing.T) {
+	model := network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), layer.Dense(3, 1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	dense := model.Layers[0].(*layer.DenseLayer)
+
+	fpath := filepath.Join(t.TempDir(), "readable.json")
+	if err := model.Save(fpath, network.ReadableWeights()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var loaded network.Network
+	if err := loaded.Load(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !mat.Equal(dense.Weights, loaded.Layers[0].(*layer.DenseLayer).Weights) {
+		t.Fatalf("readable weights didn't round trip exactly")
+	}
+
+	// weights are plain numbers in the file
+	data, _ := os.ReadFile(fpath)
+	var file network.JSONModelFile
+	json.Unmarshal(data, &file)
+	var json_network network.JSONNewtork
+	json.Unmarshal(file.Model, &json_network)
+	var weights network.JSONWeights
+	if err := json.Unmarshal(json_network.Layers[0].Params["weights"], &weights); err != nil {
+		t.Fatalf("expected readable weights, got %s", json_network.Layers[0].Params["weights"])
+	}
+	if !equalInts(weights.Shape, []int{2, 3}) || weights.Data[1][2] != dense.Weights.At(1, 2) {
+		t.Fatalf("unexpected readable weights %+v", weights)
+	}
+
+	// hand-edit one weight
+	weights.Data[1][2] = 42
+	json_network.Layers[0].Params["weights"], _ = json.Marshal(weights)
+	file.Model, _ = json.Marshal(json_network)
+	edited, _ := json.MarshalIndent(file, "", "    ")
+	os.WriteFile(fpath, edited, 0o644)
+
+	if err := loaded.Load(fpath); err == nil {
+		t.Fatalf("expected checksum error for an edited file, got none")
+	}
+	if err := loaded.Load(fpath, network.SkipChecksum()); err != nil {
+		t.Fatalf("expected no error with SkipChecksum, got %v", err)
+	}
+	if got := loaded.Layers[0].(*layer.DenseLayer).Weights.At(1, 2); got != 42 {
+		t.Fatalf("expected edited weight 42, got %v", got)
+	}
+
+	// a row with the wrong number of values is still rejected
+	weights.Data[1] = weights.Data[1][:2]
+	json_network.Layers[0].Params["weights"], _ = json.Marshal(weights)
+	file.Model, _ = json.Marshal(json_network)
+	edited, _ = json.MarshalIndent(file, "", "    ")
+	os.WriteFile(fpath, edited, 0o644)
+	if err := loaded.Load(fpath, network.SkipChecksum()); err == nil {
+		t.Fatalf("expected error for a short row, got none")
+	}
+
+	if err := model.Save(fpath, network.ReadableWeights(), network.Binary()); err == nil {
+		t.Fatalf("expected error combining ReadableWeights with Binary, got none")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// signed re-encodes a model file after edit changed its network, with a
+// checksum that matches, as someone crafting a file would.
+func signed(t *testing.T, data []byte, edit func(model *network.JSONNewtork)) []byte {
+	var file network.JSONModelFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var model network.JSONNewtork
+	json.Unmarshal(file.Model, &model)
+	edit(&model)
+	file.Model, _ = json.Marshal(model)
+	sum := sha256.Sum256(file.Model)
+	file.Checksum = "sha256:" + hex.EncodeToString(sum[:])
+	result, _ := json.Marshal(file)
+	return result
+}
+
+func TestLoadRejectsHugeConfigs(t *testing.T) {
+	_, data := savedModel(t)
+
+	var buf bytes.Buffer
+	if err := binaryTestNetwork().Encode(&buf, network.Binary()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	binary_data := buf.Bytes()
+
+	// the binary header is followed by the layer table and the tensors
+	table_len := binary.LittleEndian.Uint64(binary_data[16:])
+	tensor_offset := binary.LittleEndian.Uint64(binary_data[24:])
+	resignBinary := func(config string) []byte {
+		table := signed(t, binary_data[72:72+table_len], func(model *network.JSONNewtork) {
+			model.Layers[0].Config = json.RawMessage(config)
+		})
+		offset := (72 + len(table) + 63) / 64 * 64
+		result := make([]byte, offset, offset+len(binary_data)-int(tensor_offset))
+		copy(result, binary_data[:72])
+		binary.LittleEndian.PutUint64(result[16:], uint64(len(table)))
+		binary.LittleEndian.PutUint64(result[24:], uint64(offset))
+		copy(result[72:], table)
+		return append(result, binary_data[tensor_offset:]...)
+	}
+
+	cases := map[string][]byte{
+		// would allocate 8 TiB
+		"json too large": signed(t, data, func(model *network.JSONNewtork) {
+			model.Layers[0].Config = json.RawMessage(`{"insize":1099511627776,"outsize":1}`)
+		}),
+		"json overflow": signed(t, data, func(model *network.JSONNewtork) {
+			model.Layers[0].Config = json.RawMessage(`{"insize":4611686018427387904,"outsize":4}`)
+		}),
+		// within MaxParamSize, but far more weights than the file holds
+		"json larger than file": signed(t, data, func(model *network.JSONNewtork) {
+			model.Layers[0].Config = json.RawMessage(`{"insize":16384,"outsize":16384}`)
+		}),
+		"binary too large":        resignBinary(`{"insize":1099511627776,"outsize":1}`),
+		"binary larger than file": resignBinary(`{"insize":16384,"outsize":16384}`),
+	}
+	for name, file := range cases {
+		var loaded network.Network
+		if _, err := loaded.ReadFrom(bytes.NewReader(file)); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+
+	// the resigned binary file is otherwise valid
+	var loaded network.Network
+	if _, err := loaded.ReadFrom(bytes.NewReader(resignBinary(`{"insize":4,"outsize":16}`))); err != nil {
+		t.Fatalf("expected no error reading a resigned binary file, got %v", err)
+	}
+
+	for _, spec := range []layer.Spec{
+		{Type: "Dense", Config: json.RawMessage(`{"insize":1099511627776,"outsize":1}`)},
+		{Type: "QuantizedDense", Config: json.RawMessage(`{"insize":1099511627776,"outsize":1,"input_scale":1}`)},
+		{Type: "SparseDense", Config: json.RawMessage(`{"insize":1,"outsize":1099511627776,"row_starts":[0,0],"columns":[]}`)},
+		{Type: "Sequential", Config: json.RawMessage(`[{"type":"Dense","config":{"insize":1099511627776,"outsize":1}}]`)},
+		{Type: "preprocess.StandardScaler", Config: json.RawMessage(`{"features":1099511627776}`)},
+		{Type: "preprocess.MinMaxScaler", Config: json.RawMessage(`{"features":1099511627776,"min":0,"max":1}`)},
+		{Type: "preprocess.RobustScaler", Config: json.RawMessage(`{"features":1099511627776}`)},
+		{Type: "preprocess.PCA", Config: json.RawMessage(`{"features":1099511627776,"components":2}`)},
+	} {
+		if _, err := layer.ParamSize(spec); err == nil {
+			t.Errorf("%s: expected ParamSize to fail, got no error", spec.Type)
+		}
+		if _, err := layer.Build(spec); err == nil {
+			t.Errorf("%s: expected Build to fail, got no error", spec.Type)
+		}
+	}
+}
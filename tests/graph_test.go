@@ -0,0 +1,292 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestMergeLayers(t *testing.T) {
+	a := tensor.FromData([]float64{1, 2, 3}, 1, 3)
+	b := tensor.FromData([]float64{0.5, -1, 2}, 1, 3)
+	out_grad := tensor.FromData([]float64{0.1, 0.2, 0.3}, 1, 3)
+
+	add := layer.Add()
+	result, err := add.Forward([]*tensor.Tensor{a, b})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !tensor.EqualApprox(tensor.FromData([]float64{1.5, 1, 5}, 1, 3), result, 1e-14) {
+		t.Fatalf("Add output didn't match, got %v", result)
+	}
+	for _, grad := range add.Backward(out_grad, 0.1) {
+		if !tensor.EqualApprox(out_grad, grad, 1e-14) {
+			t.Fatalf("Add gradient didn't match, got %v", grad)
+		}
+	}
+
+	multiply := layer.Multiply()
+	result, _ = multiply.Forward([]*tensor.Tensor{a, b})
+	if !tensor.EqualApprox(tensor.FromData([]float64{0.5, -2, 6}, 1, 3), result, 1e-14) {
+		t.Fatalf("Multiply output didn't match, got %v", result)
+	}
+	grads := multiply.Backward(out_grad, 0.1)
+	if !tensor.EqualApprox(tensor.FromData([]float64{0.05, -0.2, 0.6}, 1, 3), grads[0], 1e-14) ||
+		!tensor.EqualApprox(tensor.FromData([]float64{0.1, 0.4, 0.9}, 1, 3), grads[1], 1e-14) {
+		t.Fatalf("Multiply gradients didn't match, got %v and %v", grads[0], grads[1])
+	}
+
+	concat := layer.Concat()
+	c := tensor.FromData([]float64{7}, 1, 1)
+	result, _ = concat.Forward([]*tensor.Tensor{a, c})
+	if !tensor.Equal(tensor.FromData([]float64{1, 2, 3, 7}, 1, 4), result) {
+		t.Fatalf("Concat output didn't match, got %v", result)
+	}
+	grads = concat.Backward(tensor.FromData([]float64{0.1, 0.2, 0.3, 0.4}, 1, 4), 0.1)
+	if !tensor.Equal(tensor.FromData([]float64{0.1, 0.2, 0.3}, 1, 3), grads[0]) ||
+		!tensor.Equal(tensor.FromData([]float64{0.4}, 1, 1), grads[1]) {
+		t.Fatalf("Concat gradients didn't match, got %v and %v", grads[0], grads[1])
+	}
+
+	if _, err := add.Forward([]*tensor.Tensor{a, c}); err == nil {
+		t.Fatalf("expected dimension error, got none")
+	}
+	if _, err := concat.Forward([]*tensor.Tensor{a, tensor.New(2, 1)}); err == nil {
+		t.Fatalf("expected dimension error, got none")
+	}
+}
+
+func residualGraph(t *testing.T) *network.Graph {
+	dense := layer.Dense(2, 2)
+	dense.Weights = mat.NewDense(2, 2, []float64{0.1, 0.2, 0.3, 0.4})
+	dense.Biases = mat.NewDense(1, 2, []float64{0.1, -0.1})
+
+	input := network.Input()
+	hidden := network.Apply(layer.Tanh(2), network.Apply(dense, input))
+	output := network.Merge(layer.Add(), hidden, input)
+
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{output})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	graph.Loss = loss.MSE
+	graph.LossPrime = loss.MSE_Prime
+	return graph
+}
+
+func TestGraphResidualForward(t *testing.T) {
+	graph := residualGraph(t)
+
+	input := mat.NewDense(1, 2, []float64{1, 2})
+	// tanh([1 2] x W + b) + [1 2]
+	var hidden mat.Dense
+	hidden.Mul(input, mat.NewDense(2, 2, []float64{0.1, 0.2, 0.3, 0.4}))
+	hidden.Add(&hidden, mat.NewDense(1, 2, []float64{0.1, -0.1}))
+	var tanh layer.TanhLayer
+	expected_output, _ := tanh.ForwardRows(&hidden)
+	expected_output.Add(expected_output, input)
+
+	result := graph.Predict(input)
+	if !mat.EqualApprox(expected_output, result[0], 1e-14) {
+		t.Fatalf(
+			"Output didn't match\nExpected = %v\nGot = %v\n",
+			mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+			mat.Formatted(result[0], mat.Prefix("  "), mat.Squeeze()),
+		)
+	}
+}
+
+func TestGraphOnSequences(t *testing.T) {
+	graph := residualGraph(t)
+	sequences := counting(3, 4, 2).Scale(0.1)
+
+	outputs, err := graph.ForwardTensor(sequences)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(outputs[0].Shape(), []int{3, 4, 2}) {
+		t.Fatalf("expected shape [3 4 2], got %v", outputs[0].Shape())
+	}
+	// every step is computed like a row
+	rows, _ := sequences.Rows()
+	expected, _ := graph.PredictContext(context.Background(), rows)
+	if !tensor.EqualApprox(tensor.FromRows(expected[0], []int{3, 4, 2}), outputs[0], 1e-14) {
+		t.Fatalf("sequence output doesn't match the rows output")
+	}
+	if !tensor.EqualApprox(graph.PredictTensor(sequences)[0], outputs[0], 1e-14) {
+		t.Fatalf("PredictTensor doesn't match ForwardTensor")
+	}
+	graph.BackPropTensor([]*tensor.Tensor{tensor.New(3, 4, 2)}, 0.1)
+}
+
+func TestGraphBackPropAccumulatesGradients(t *testing.T) {
+	/*
+		out = x * x (through a Multiply merge with the same node twice)
+		dL/dx = 2x * dL/dout, so a Dense(1, 1) with weight w feeding it
+		receives 2 * w * input * dL/dout as the gradient of w.
+	*/
+	dense := layer.Dense(1, 1)
+	dense.Weights = mat.NewDense(1, 1, []float64{0.5})
+	dense.Biases = mat.NewDense(1, 1, []float64{0})
+
+	input := network.Input()
+	hidden := network.Apply(dense, input)
+	output := network.Merge(layer.Multiply(), hidden, hidden)
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{output})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := graph.Forward(mat.NewDense(1, 1, []float64{2})); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	graph.BackProp([]*mat.Dense{mat.NewDense(1, 1, []float64{1})}, 0.1)
+
+	// hidden = 1, dL/dhidden = 2, dL/dw = 2 * 2 = 4, w = 0.5 - 0.1 * 4
+	if !mat.EqualApprox(mat.NewDense(1, 1, []float64{0.1}), dense.Weights, 1e-14) {
+		t.Fatalf("Weights didn't update correctly, got %v", mat.Formatted(dense.Weights))
+	}
+}
+
+func TestGraphValidation(t *testing.T) {
+	input := network.Input()
+	other := network.Input()
+	dense := layer.Dense(2, 2)
+
+	output := network.Merge(layer.Add(), network.Apply(dense, input), other)
+	if _, err := network.NewGraph([]*network.Node{input}, []*network.Node{output}); err == nil {
+		t.Fatalf("expected error for unlisted input, got none")
+	}
+
+	shared := network.Merge(layer.Add(), network.Apply(dense, input), network.Apply(dense, input))
+	if _, err := network.NewGraph([]*network.Node{input}, []*network.Node{shared}); err == nil {
+		t.Fatalf("expected error for layer used twice, got none")
+	}
+}
+
+func TestGraphTrainAndSaveLoad(t *testing.T) {
+	inputs := [][]*mat.Dense{
+		{mat.NewDense(1, 2, []float64{0, 0})},
+		{mat.NewDense(1, 2, []float64{0, 1})},
+		{mat.NewDense(1, 2, []float64{1, 0})},
+		{mat.NewDense(1, 2, []float64{1, 1})},
+	}
+	outputs := [][]*mat.Dense{
+		{mat.NewDense(1, 1, []float64{0})},
+		{mat.NewDense(1, 1, []float64{1})},
+		{mat.NewDense(1, 1, []float64{1})},
+		{mat.NewDense(1, 1, []float64{0})},
+	}
+
+	input := network.Input()
+	left := network.Apply(layer.Tanh(3), network.Apply(layer.Dense(2, 3), input))
+	right := network.Apply(layer.Tanh(3), network.Apply(layer.Dense(2, 3), input))
+	merged := network.Merge(layer.Concat(), left, right)
+	output := network.Apply(layer.Tanh(1), network.Apply(layer.Dense(6, 1), merged))
+
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{output})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	graph.Loss = loss.MSE
+	graph.LossPrime = loss.MSE_Prime
+
+	graph.Train(inputs, outputs, 200, 0.1)
+
+	fpath := filepath.Join(t.TempDir(), "graph.json")
+	if err := graph.Save(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var loaded network.Graph
+	if err := loaded.Load(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, input := range inputs {
+		expected_output := graph.Predict(input...)[0]
+		result := loaded.Predict(input...)[0]
+		if !mat.Equal(expected_output, result) {
+			t.Fatalf(
+				"Loaded graph output didn't match\nExpected = %v\nGot = %v\n",
+				mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+				mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+			)
+		}
+	}
+}
+
+func TestGraphTrainChecksSamples(t *testing.T) {
+	input := network.Input()
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{network.Apply(layer.Dense(2, 1), input)})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	graph.Loss, graph.LossPrime = loss.MSE, loss.MSE_Prime
+
+	sample := []*mat.Dense{mat.NewDense(1, 2, nil)}
+	target := []*mat.Dense{mat.NewDense(1, 1, nil)}
+	cases := map[string][2][][]*mat.Dense{
+		"fewer outputs":   {{sample, sample}, {target}},
+		"missing target":  {{sample, sample}, {target, {}}},
+		"extra target":    {{sample}, {{target[0], target[0]}}},
+		"missing input":   {{sample, {}}, {target, target}},
+		"too many inputs": {{{sample[0], sample[0]}}, {target}},
+	}
+	for name, data := range cases {
+		if err := graph.TrainContext(context.Background(), data[0], data[1], 1, 0.1); err == nil {
+			t.Fatalf("%s: expected error, got none", name)
+		}
+	}
+}
+
+// chainGraph builds a graph running the layers of model one after another.
+func chainGraph(t *testing.T, model *network.Network) *network.Graph {
+	input := network.Input()
+	node := input
+	for _, l := range model.Layers {
+		node = network.Apply(l, node)
+	}
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{node})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	graph.Loss, graph.LossPrime = model.Loss, model.LossPrime
+	return graph
+}
+
+func TestGraphTrainMatchesNetwork(t *testing.T) {
+	inputs, outputs := xorData()
+	model := &network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), layer.Dense(3, 1), layer.Tanh(1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+
+	// a chain graph over a copy of the same layers
+	graph := chainGraph(t, cloneNetwork(t, model))
+
+	graph_inputs := make([][]*mat.Dense, len(inputs))
+	graph_outputs := make([][]*mat.Dense, len(outputs))
+	for i := range inputs {
+		graph_inputs[i], graph_outputs[i] = []*mat.Dense{inputs[i]}, []*mat.Dense{outputs[i]}
+	}
+
+	model.Train(inputs, outputs, 5, 0.1)
+	graph.Train(graph_inputs, graph_outputs, 5, 0.1)
+
+	expected, got := model.Params(), graph.Params()
+	for i := range expected {
+		if !mat.Equal(expected[i].Value, got[i].Value) {
+			t.Fatalf("%s differs after training:\n%v\n%v", expected[i].Name,
+				mat.Formatted(expected[i].Value), mat.Formatted(got[i].Value))
+		}
+	}
+}
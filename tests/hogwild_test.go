@@ -0,0 +1,82 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// meanLoss is the loss of the network over the whole dataset.
+func meanLoss(model *network.Network, dataset *data.SliceDataset) float64 {
+	total := 0.0
+	for i := range dataset.Inputs {
+		total += model.Loss(dataset.Targets[i], model.Predict(dataset.Inputs[i]))
+	}
+	return total / float64(dataset.Len())
+}
+
+func TestHogwildTraining(t *testing.T) {
+	if raceEnabled {
+		t.Skip("Hogwild updates the weights without synchronization on purpose")
+	}
+
+	dataset := datasets.Sine(200, 0, 1)
+	model := &network.Network{
+		Layers:    []layer.Layer{layer.Dense(1, 16), layer.Tanh(16), layer.Dense(16, 1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+		Workers:   4,
+		Hogwild:   true,
+	}
+	before := meanLoss(model, dataset)
+	first := mat.DenseCopyOf(model.Layers[0].(*layer.DenseLayer).Weights)
+
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 4, Shuffle: true}
+	model.TrainLoader(loader, 30, 0.01)
+	if model.State.Epoch != 30 {
+		t.Fatalf("expected 30 epochs to be done, got %d", model.State.Epoch)
+	}
+	if mat.Equal(first, model.Layers[0].(*layer.DenseLayer).Weights) {
+		t.Fatalf("the workers' updates didn't reach the network's weights")
+	}
+	if after := meanLoss(model, dataset); after >= before/2 {
+		t.Fatalf("expected the loss to at least halve, went from %v to %v", before, after)
+	}
+}
+
+func BenchmarkHogwild(b *testing.B) {
+	dataset := datasets.Spirals(1024, 4, 0.1, 1)
+	base := &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(2, 128), layer.Tanh(128),
+			layer.Dense(128, 4), layer.Tanh(4),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	loader := &data.DataLoader{Dataset: dataset}
+
+	for _, mode := range []struct {
+		workers int
+		hogwild bool
+	}{{1, false}, {2, true}, {4, true}} {
+		b.Run(fmt.Sprintf("workers=%d/hogwild=%t", mode.workers, mode.hogwild), func(b *testing.B) {
+			model := cloneNetwork(b, base)
+			model.Workers, model.Hogwild = mode.workers, mode.hogwild
+			model.State = network.TrainState{Rate: 0.01}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				model.State.Epochs++
+				if err := model.ResumeLoader(loader); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"fmt"
 	"math"
 	"testing"
 
@@ -16,7 +17,7 @@ func TestDenseLayerForwardPropgation(t *testing.T) {
 
 	input := mat.NewDense(1, 2, []float64{1, 2})
 	expected_output := mat.NewDense(1, 3, []float64{1, 1.4, 1.8})
-	result, err := layer.Forward(input)
+	result, err := layer.ForwardRows(input)
 
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
@@ -38,7 +39,7 @@ func TestDenseLayerForwardPropgation(t *testing.T) {
 
 	// Checking with wrong dimension input
 	input = mat.NewDense(1, 3, nil)
-	_, err = layer.Forward(input)
+	_, err = layer.ForwardRows(input)
 	if err == nil {
 		t.Fatalf("expected dimension error, got none")
 	}
@@ -52,7 +53,7 @@ func TestDenseLayerBackwardPropgation(t *testing.T) {
 	layer.Input = mat.NewDense(1, 2, []float64{1, 2})
 
 	out_grad := mat.NewDense(1, 3, []float64{0.2, 0.4, 0.6})
-	result_inputgrad := layer.Backward(out_grad, 0.1)
+	result_inputgrad := layer.BackwardRows(out_grad, 0.1)
 
 	expected_weights := mat.NewDense(2, 3, []float64{0.08, 0.16, 0.24, 0.36, 0.42, 0.48})
 	expected_biases := mat.NewDense(1, 3, []float64{0.08, 0.16, 0.24})
@@ -104,7 +105,7 @@ func TestTanhLayerForwardPropagation(t *testing.T) {
 		input_matrix := mat.NewDense(1, len(input), input)
 		expected_output := mat.NewDense(1, len(input), outputs[i])
 
-		result, err := layer.Forward(input_matrix)
+		result, err := layer.ForwardRows(input_matrix)
 
 		if err != nil {
 			t.Errorf("expected no error, got %v", err)
@@ -150,7 +151,7 @@ func TestTanhLayerBackwardPropagation(t *testing.T) {
 		expected_output := mat.NewDense(1, len(input), outputs[i])
 
 		layer.Input = input_matrix
-		result := layer.Backward(grad_matrix, 0.1)
+		result := layer.BackwardRows(grad_matrix, 0.1)
 
 		if !mat.EqualApprox(expected_output, result, 1e-14) {
 			t.Fatalf(
@@ -161,3 +162,56 @@ func TestTanhLayerBackwardPropagation(t *testing.T) {
 		}
 	}
 }
+
+func TestTanhBackwardAfterForward(t *testing.T) {
+	tanh := layer.Tanh(3)
+	input := mat.NewDense(2, 3, []float64{1, -0.5, 0, 2, 0.25, -3})
+	out_grad := mat.NewDense(2, 3, []float64{1, 2, 3, -1, 0.5, 0})
+	expected := mat.NewDense(2, 3, nil)
+	expected.Apply(func(i, j int, v float64) float64 {
+		return out_grad.At(i, j) * (1 - math.Pow(math.Tanh(v), 2))
+	}, input)
+
+	// Backward uses the output kept by Forward
+	tanh.ForwardRows(input)
+	if result := tanh.BackwardRows(out_grad, 0.1); !mat.EqualApprox(expected, result, 1e-14) {
+		t.Fatalf("expected %v, got %v", mat.Formatted(expected), mat.Formatted(result))
+	}
+
+	// and recomputes it for an Input set by hand, even one reusing the
+	// matrix passed to Forward
+	input.Zero()
+	tanh.Input = input
+	if result := tanh.BackwardRows(out_grad, 0.1); !mat.Equal(out_grad, result) {
+		t.Fatalf("expected the gradient unchanged at 0, got %v", mat.Formatted(result))
+	}
+}
+
+// BenchmarkDenseBackward shows the cost of the weight update on its own
+// with batches of 1, where it is as large as the products.
+func BenchmarkDenseBackward(b *testing.B) {
+	for _, batch := range []int{1, 32} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			dense := layer.Dense(256, 256)
+			dense.ForwardRows(randomDense(batch, 256, 1))
+			out_grad := randomDense(batch, 256, 2)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				dense.BackwardRows(out_grad, 1e-6)
+			}
+		})
+	}
+}
+
+func BenchmarkTanhBackward(b *testing.B) {
+	tanh := layer.Tanh(256)
+	input, out_grad := randomDense(32, 256, 1), randomDense(32, 256, 2)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// every Backward needs its own Forward to use the saved output
+		b.StopTimer()
+		tanh.ForwardRows(input)
+		b.StartTimer()
+		tanh.BackwardRows(out_grad, 0.1)
+	}
+}
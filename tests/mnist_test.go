@@ -0,0 +1,126 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
+	"gonum.org/v1/gonum/mat"
+)
+
+// idxFile builds an IDX file of unsigned bytes.
+func idxFile(dims []int, values []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, 0x08, byte(len(dims))})
+	for _, dim := range dims {
+		binary.Write(&buf, binary.BigEndian, uint32(dim))
+	}
+	buf.Write(values)
+	return buf.Bytes()
+}
+
+func gzipped(content []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(content)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestLoadMNIST(t *testing.T) {
+	// three 2x2 images
+	images := idxFile([]int{3, 2, 2}, []byte{
+		0, 255, 51, 102,
+		255, 255, 0, 0,
+		0, 0, 0, 0,
+	})
+	labels := idxFile([]int{3}, []byte{7, 0, 9})
+
+	dir := t.TempDir()
+	images_path := filepath.Join(dir, "images-idx3-ubyte.gz")
+	labels_path := filepath.Join(dir, "labels-idx1-ubyte")
+	if err := os.WriteFile(images_path, gzipped(images), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(labels_path, labels, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dataset, err := datasets.LoadMNIST(images_path, labels_path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if dataset.Len() != 3 {
+		t.Fatalf("expected 3 samples, got %d", dataset.Len())
+	}
+	expected := mat.NewDense(1, 4, []float64{0, 1, 0.2, 0.4})
+	if !mat.EqualApprox(dataset.Inputs[0], expected, 1e-15) {
+		t.Fatalf("expected %v, got %v", mat.Formatted(expected), mat.Formatted(dataset.Inputs[0]))
+	}
+	for i, label := range []int{7, 0, 9} {
+		if dataset.Targets[i].At(0, label) != 1 || mat.Sum(dataset.Targets[i]) != 1 {
+			t.Fatalf("sample %d: expected one-hot label %d, got %v", i, label, mat.Formatted(dataset.Targets[i]))
+		}
+	}
+}
+
+func TestReadIDXTypes(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, 0x0B, 1})
+	binary.Write(&buf, binary.BigEndian, uint32(3))
+	binary.Write(&buf, binary.BigEndian, []int16{-2, 0, 300})
+	idx, err := datasets.ReadIDX(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !equalBatches([][]float64{idx.Data}, [][]float64{{-2, 0, 300}}) {
+		t.Fatalf("expected [-2 0 300], got %v", idx.Data)
+	}
+
+	buf.Reset()
+	buf.Write([]byte{0, 0, 0x0D, 2})
+	binary.Write(&buf, binary.BigEndian, []uint32{1, 2})
+	binary.Write(&buf, binary.BigEndian, []float32{0.5, -1.25})
+	if idx, err = datasets.ReadIDX(&buf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(idx.Dims) != 2 || idx.Dims[1] != 2 || idx.Data[1] != -1.25 {
+		t.Fatalf("unexpected idx %+v", idx)
+	}
+}
+
+func TestReadIDXErrors(t *testing.T) {
+	images := idxFile([]int{2, 2, 2}, make([]byte, 8))
+	cases := map[string][]byte{
+		"not idx":      []byte("PK\x03\x04...."),
+		"unknown type": {0, 0, 0x42, 1, 0, 0, 0, 0},
+		"truncated":    images[:len(images)-3],
+		// claims 16 GiB of float64s
+		"huge": {0, 0, 0x0E, 1, 0x7F, 0xFF, 0xFF, 0xFF},
+	}
+	for name, content := range cases {
+		if _, err := datasets.ReadIDX(bytes.NewReader(content)); err == nil {
+			t.Fatalf("%s: expected error, got none", name)
+		}
+		fpath := filepath.Join(t.TempDir(), "bad.idx")
+		if err := os.WriteFile(fpath, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := datasets.LoadIDX(fpath); err == nil {
+			t.Fatalf("%s: expected error loading the file, got none", name)
+		}
+	}
+
+	image_idx, _ := datasets.ReadIDX(bytes.NewReader(images))
+	wrong_count, _ := datasets.ReadIDX(bytes.NewReader(idxFile([]int{3}, []byte{1, 2, 3})))
+	bad_label, _ := datasets.ReadIDX(bytes.NewReader(idxFile([]int{2}, []byte{1, 10})))
+	for name, labels := range map[string]*datasets.IDX{"label count": wrong_count, "label value": bad_label} {
+		if _, err := datasets.MNIST(image_idx, labels); err == nil {
+			t.Fatalf("%s: expected error, got none", name)
+		}
+	}
+}
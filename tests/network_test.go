@@ -0,0 +1,130 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestSequentialLayerMatchesFlatLayers(t *testing.T) {
+	first := layer.Dense(2, 3)
+	second := layer.Dense(3, 1)
+
+	flat := network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(2, 3),
+			layer.Tanh(3),
+			layer.Dense(3, 1),
+		},
+	}
+	flat.Layers[0].(*layer.DenseLayer).Weights = mat.DenseCopyOf(first.Weights)
+	flat.Layers[0].(*layer.DenseLayer).Biases = mat.DenseCopyOf(first.Biases)
+	flat.Layers[2].(*layer.DenseLayer).Weights = mat.DenseCopyOf(second.Weights)
+	flat.Layers[2].(*layer.DenseLayer).Biases = mat.DenseCopyOf(second.Biases)
+
+	nested := network.Network{
+		Layers: []layer.Layer{
+			layer.Sequential(first, layer.Tanh(3)),
+			second,
+		},
+	}
+
+	input := mat.NewDense(1, 2, []float64{0.5, -1})
+	out_grad := mat.NewDense(1, 1, []float64{0.3})
+	for i := 0; i < 3; i++ {
+		expected_output, _ := flat.Forward(input)
+		result, _ := nested.Forward(input)
+		if !mat.EqualApprox(expected_output, result, 1e-14) {
+			t.Fatalf(
+				"Output didn't match\nExpected = %v\nGot = %v\n",
+				mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+				mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+			)
+		}
+		flat.BackProp(out_grad, 0.1)
+		nested.BackProp(out_grad, 0.1)
+	}
+}
+
+func TestBackPropNeedsForward(t *testing.T) {
+	model := network.Network{Layers: []layer.Layer{layer.Dense(2, 1)}}
+	input := mat.NewDense(1, 2, []float64{0.5, -1})
+	out_grad := mat.NewDense(1, 1, []float64{0.3})
+	backProp := func() (panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		model.BackProp(out_grad, 0.1)
+		return false
+	}
+
+	model.Predict(input)
+	if !backProp() {
+		t.Fatalf("expected BackProp after Predict to panic")
+	}
+	model.Forward(input)
+	if backProp() {
+		t.Fatalf("expected BackProp after Forward not to panic")
+	}
+	if !backProp() {
+		t.Fatalf("expected a second BackProp for one Forward to panic")
+	}
+}
+
+func TestNestedSequentialSaveLoad(t *testing.T) {
+	block := layer.Sequential(layer.Dense(2, 4), layer.Tanh(4))
+	model := network.Network{
+		Layers: []layer.Layer{
+			layer.Sequential(block, layer.Dense(4, 2)),
+			layer.Tanh(2),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+
+	fpath := filepath.Join(t.TempDir(), "nested.json")
+	if err := model.Save(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var loaded network.Network
+	if err := loaded.Load(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	outer, ok := loaded.Layers[0].(*layer.SequentialLayer)
+	if !ok {
+		t.Fatalf("expected *layer.SequentialLayer, got %T", loaded.Layers[0])
+	}
+	if _, ok := outer.Layers[0].(*layer.SequentialLayer); !ok {
+		t.Fatalf("expected nested *layer.SequentialLayer, got %T", outer.Layers[0])
+	}
+
+	input := mat.NewDense(1, 2, []float64{0.1, 0.7})
+	expected_output := model.Predict(input)
+	result := loaded.Predict(input)
+	if !mat.Equal(expected_output, result) {
+		t.Fatalf(
+			"Loaded network output didn't match\nExpected = %v\nGot = %v\n",
+			mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+			mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+		)
+	}
+}
+
+func TestLoadExampleModel(t *testing.T) {
+	var xor network.Network
+	if err := xor.Load("../examples/xor/xor_trained.json"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(xor.Layers) != 4 {
+		t.Fatalf("expected 4 layers, got %d", len(xor.Layers))
+	}
+	result := xor.Predict(mat.NewDense(1, 2, []float64{1, 0}))
+	if result == nil || result.At(0, 0) < 0.5 {
+		t.Fatalf("expected trained xor network to output ~1 for (1, 0), got %v", result)
+	}
+}
@@ -0,0 +1,323 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/npy"
+	"github.com/kapilpokhrel/goNN/pkg/prune"
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+// npyFile builds an .npy file the way NumPy writes it.
+func npyFile(header string, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)+1))
+	buf.WriteString(header + "\n")
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestReadNPY(t *testing.T) {
+	float32s := make([]byte, 0)
+	for _, v := range []float32{1, 2, 3, 4, 5, 6} {
+		float32s = binary.LittleEndian.AppendUint32(float32s, math.Float32bits(v))
+	}
+	int64s := make([]byte, 0)
+	for _, v := range []int64{-1, 0, 7} {
+		int64s = binary.BigEndian.AppendUint64(int64s, uint64(v))
+	}
+
+	tests := []struct {
+		name     string
+		file     []byte
+		expected *mat.Dense
+	}{
+		{
+			"row major float32",
+			npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }", float32s),
+			mat.NewDense(2, 3, []float64{1, 2, 3, 4, 5, 6}),
+		},
+		{
+			"column major float32",
+			npyFile("{'descr': '<f4', 'fortran_order': True, 'shape': (2, 3), }", float32s),
+			mat.NewDense(2, 3, []float64{1, 3, 5, 2, 4, 6}),
+		},
+		{
+			"big endian int64 vector",
+			npyFile("{'descr': '>i8', 'fortran_order': False, 'shape': (3,), }", int64s),
+			mat.NewDense(1, 3, []float64{-1, 0, 7}),
+		},
+	}
+
+	for _, test := range tests {
+		result, err := npy.Read(bytes.NewReader(test.file))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", test.name, err)
+		}
+		if !mat.Equal(test.expected, result) {
+			t.Fatalf(
+				"%s: Output didn't match\nExpected = %v\nGot = %v\n", test.name,
+				mat.Formatted(test.expected, mat.Prefix("  "), mat.Squeeze()),
+				mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+			)
+		}
+	}
+
+	bad := [][]byte{
+		[]byte("not numpy"),
+		npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }", float32s[:8]),
+		npyFile("{'descr': '<c16', 'fortran_order': False, 'shape': (1,), }", make([]byte, 16)),
+		npyFile("{'descr': '<f8', 'fortran_order': False, 'shape': (1, 1, 1), }", make([]byte, 8)),
+		// the shape is checked against the file before allocating for it
+		npyFile("{'descr': '<f8', 'fortran_order': False, 'shape': (1099511627776, 1), }", make([]byte, 8)),
+		npyFile("{'descr': '<f8', 'fortran_order': False, 'shape': (4611686018427387904, 4), }", make([]byte, 8)),
+	}
+	for i, file := range bad {
+		if _, err := npy.Read(bytes.NewReader(file)); err == nil {
+			t.Fatalf("bad file %d: expected error, got none", i)
+		}
+		// files are measured with Seek, readers of unknown size are read in
+		// bounded chunks
+		fpath := filepath.Join(t.TempDir(), "bad.npy")
+		if err := os.WriteFile(fpath, file, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(fpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = npy.Read(f)
+		f.Close()
+		if err == nil {
+			t.Fatalf("bad file %d: expected error from *os.File, got none", i)
+		}
+		if _, err := npy.Read(io.MultiReader(bytes.NewReader(file))); err == nil {
+			t.Fatalf("bad file %d: expected error from a plain reader, got none", i)
+		}
+	}
+
+	// a good file read from disk
+	fpath := filepath.Join(t.TempDir(), "good.npy")
+	if err := os.WriteFile(fpath, tests[0].file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	result, err := npy.Read(f)
+	if err != nil {
+		t.Fatalf("expected no error reading %s, got %v", fpath, err)
+	}
+	if !mat.Equal(tests[0].expected, result) {
+		t.Fatalf("Output didn't match\nExpected = %v\nGot = %v\n", tests[0].expected, result)
+	}
+}
+
+func TestWriteNPYRoundTrip(t *testing.T) {
+	m := mat.NewDense(2, 2, []float64{0.1, -2, math.Pi, 1e-300})
+
+	var buf bytes.Buffer
+	if err := npy.Write(&buf, m); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	header_len := int(binary.LittleEndian.Uint16(buf.Bytes()[8:]))
+	if (10+header_len)%64 != 0 {
+		t.Fatalf("expected data to start at a multiple of 64 bytes, starts at %d", 10+header_len)
+	}
+
+	result, err := npy.Read(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !mat.Equal(m, result) {
+		t.Fatalf("array didn't round trip, got %v", mat.Formatted(result))
+	}
+}
+
+func TestNPYTensors(t *testing.T) {
+	float32s := make([]byte, 0)
+	for _, v := range []float32{1, 2, 3, 4, 5, 6} {
+		float32s = binary.LittleEndian.AppendUint32(float32s, math.Float32bits(v))
+	}
+	result, err := npy.ReadTensor(bytes.NewReader(npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (3, 1, 2), }", float32s)))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !tensor.Equal(tensor.FromData([]float64{1, 2, 3, 4, 5, 6}, 3, 1, 2), result) {
+		t.Fatalf("row major array didn't match, got %v", result)
+	}
+	result, err = npy.ReadTensor(bytes.NewReader(npyFile("{'descr': '<f4', 'fortran_order': True, 'shape': (1, 2, 3), }", float32s)))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !tensor.Equal(tensor.FromData([]float64{1, 3, 5, 2, 4, 6}, 1, 2, 3), result) {
+		t.Fatalf("column major array didn't match, got %v", result)
+	}
+
+	for _, original := range []*tensor.Tensor{counting(2, 3, 4), counting(3), tensor.FromData([]float64{7})} {
+		var buf bytes.Buffer
+		if err := npy.WriteTensor(&buf, original); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		result, err := npy.ReadTensor(&buf)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !tensor.Equal(original, result) {
+			t.Fatalf("tensor of shape %v didn't round trip, got %v", original.Shape(), result)
+		}
+	}
+}
+
+func TestNetworkNPZRoundTrip(t *testing.T) {
+	model := network.Network{
+		Layers: []layer.Layer{
+			layer.Sequential(layer.Dense(2, 3), layer.Tanh(3)),
+			layer.Dense(3, 1),
+		},
+	}
+	fpath := filepath.Join(t.TempDir(), "weights.npz")
+	f, err := os.Create(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := npy.ExportNetwork(f, &model); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	f.Close()
+
+	arrays, err := npy.LoadNPZ(fpath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, name := range []string{"0.0.weights", "0.0.biases", "1.weights", "1.biases"} {
+		if arrays[name] == nil {
+			t.Fatalf("expected array %q in the archive", name)
+		}
+	}
+
+	other := network.Network{
+		Layers: []layer.Layer{
+			layer.Sequential(layer.Dense(2, 3), layer.Tanh(3)),
+			layer.Dense(3, 1),
+		},
+	}
+	if err := npy.LoadWeights(&other, arrays, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	input := mat.NewDense(1, 2, []float64{0.6, -0.2})
+	if !mat.Equal(model.Predict(input), other.Predict(input)) {
+		t.Fatalf("network with loaded weights didn't match the exported one")
+	}
+}
+
+// Weights as a PyTorch model would hand them over with
+// numpy.savez_compressed(path, **{k: v.numpy() for k, v in state_dict.items()})
+func TestLoadWeightsWithMapping(t *testing.T) {
+	float64s := func(values ...float64) []byte {
+		data := make([]byte, 0)
+		for _, v := range values {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		}
+		return data
+	}
+	files := map[string][]byte{
+		// Linear(2, 3) stores its weight as (out, in)
+		"fc.weight.npy": npyFile("{'descr': '<f8', 'fortran_order': False, 'shape': (3, 2), }", float64s(1, 2, 3, 4, 5, 6)),
+		"fc.bias.npy":   npyFile("{'descr': '<f8', 'fortran_order': False, 'shape': (3,), }", float64s(0.1, 0.2, 0.3)),
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	archive.Close()
+
+	arrays, err := npy.ReadNPZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	model := network.Network{Layers: []layer.Layer{layer.Dense(2, 3), layer.Tanh(3)}}
+	mapping := map[string]string{"0.weights": "fc.weight.T", "0.biases": "fc.bias"}
+	if err := npy.LoadWeights(&model, arrays, mapping); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	dense := model.Layers[0].(*layer.DenseLayer)
+	if !mat.Equal(dense.Weights, mat.NewDense(2, 3, []float64{1, 3, 5, 2, 4, 6})) {
+		t.Fatalf("expected transposed weights, got %v", mat.Formatted(dense.Weights))
+	}
+	if !mat.Equal(dense.Biases, mat.NewDense(1, 3, []float64{0.1, 0.2, 0.3})) {
+		t.Fatalf("expected biases to be loaded, got %v", mat.Formatted(dense.Biases))
+	}
+
+	// Without the transpose the shapes don't match, and nothing is changed.
+	dense.Biases.Zero()
+	before := mat.DenseCopyOf(dense.Biases)
+	if err := npy.LoadWeights(&model, arrays, map[string]string{"0.weights": "fc.weight", "0.biases": "fc.bias"}); err == nil {
+		t.Fatalf("expected shape mismatch error, got none")
+	}
+	if err := npy.LoadWeights(&model, arrays, nil); err == nil {
+		t.Fatalf("expected missing array error, got none")
+	}
+	if !mat.Equal(before, dense.Biases) {
+		t.Fatalf("failed LoadWeights changed the network")
+	}
+
+	// an empty name skips a parameter
+	if err := npy.LoadWeights(&model, arrays, map[string]string{"0.weights": "fc.weight.T", "0.biases": ""}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !mat.Equal(before, dense.Biases) {
+		t.Fatalf("expected skipped biases to be left alone, got %v", mat.Formatted(dense.Biases))
+	}
+}
+
+func TestLoadWeightsKeepsMasks(t *testing.T) {
+	source := network.Network{Layers: []layer.Layer{layer.Dense(4, 3)}}
+	var buf bytes.Buffer
+	if err := npy.ExportNetwork(&buf, &source); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	arrays, err := npy.ReadNPZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the archive has no mask for the pruned layer
+	pruned := network.Network{Layers: []layer.Layer{layer.Dense(4, 3)}}
+	if err := prune.PerLayer(&pruned, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	mask := mat.DenseCopyOf(pruned.Layers[0].(*layer.DenseLayer).Mask)
+	if err := npy.LoadWeights(&pruned, arrays, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	dense := pruned.Layers[0].(*layer.DenseLayer)
+	if !mat.Equal(mask, dense.Mask) {
+		t.Fatalf("expected the mask to be kept")
+	}
+	if !mat.Equal(dense.Weights, source.Layers[0].(*layer.DenseLayer).Weights) {
+		t.Fatalf("expected the weights to be loaded")
+	}
+}
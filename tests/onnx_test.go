@@ -0,0 +1,270 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/onnx"
+	"github.com/kapilpokhrel/goNN/pkg/preprocess"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestONNXNetworkRoundTrip(t *testing.T) {
+	model := network.Network{
+		Layers: []layer.Layer{
+			layer.Sequential(layer.Dense(3, 4), layer.Tanh(4)),
+			layer.Dense(4, 2),
+			layer.Tanh(2),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := onnx.Export(&buf, &model); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var exported onnx.ModelProto
+	if err := exported.Unmarshal(buf.Bytes()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	ops := make([]string, len(exported.Graph.Node))
+	for i, node := range exported.Graph.Node {
+		ops[i] = node.OpType
+	}
+	if got := strings.Join(ops, " "); got != "Gemm Tanh Gemm Tanh" {
+		t.Fatalf("expected ops Gemm Tanh Gemm Tanh, got %s", got)
+	}
+
+	imported, err := onnx.Import(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(imported.Layers) != 4 {
+		t.Fatalf("expected 4 layers, got %d", len(imported.Layers))
+	}
+
+	input := mat.NewDense(1, 3, []float64{0.2, -0.7, 1.1})
+	expected_output := model.Predict(input)
+	result := imported.Predict(input)
+	if !mat.Equal(expected_output, result) {
+		t.Fatalf(
+			"Imported network output didn't match\nExpected = %v\nGot = %v\n",
+			mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+			mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+		)
+	}
+}
+
+func TestONNXGraphRoundTrip(t *testing.T) {
+	input := network.Input()
+	hidden := network.Apply(layer.Tanh(3), network.Apply(layer.Dense(2, 3), input))
+	gate := network.Apply(layer.Tanh(3), network.Apply(layer.Dense(2, 3), input))
+	gated := network.Merge(layer.Multiply(), hidden, gate)
+	merged := network.Merge(layer.Concat(), gated, network.Merge(layer.Add(), hidden, gate, gated))
+	output := network.Apply(layer.Dense(6, 1), merged)
+	graph, err := network.NewGraph([]*network.Node{input}, []*network.Node{output, hidden})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := onnx.ExportGraph(&buf, graph); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	imported, err := onnx.ImportGraph(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	x := mat.NewDense(1, 2, []float64{0.4, -0.3})
+	expected_outputs := graph.Predict(x)
+	results := imported.Predict(x)
+	if len(results) != len(expected_outputs) {
+		t.Fatalf("expected %d outputs, got %d", len(expected_outputs), len(results))
+	}
+	for i := range results {
+		if !mat.Equal(expected_outputs[i], results[i]) {
+			t.Fatalf(
+				"Imported graph output %d didn't match\nExpected = %v\nGot = %v\n", i,
+				mat.Formatted(expected_outputs[i], mat.Prefix("  "), mat.Squeeze()),
+				mat.Formatted(results[i], mat.Prefix("  "), mat.Squeeze()),
+			)
+		}
+	}
+
+	if _, err := onnx.Import(bytes.NewReader(nil)); err == nil {
+		t.Fatalf("expected error importing an empty model, got none")
+	}
+}
+
+func TestONNXQuantizedRoundTrip(t *testing.T) {
+	first, second := layer.Dense(4, 8), layer.Dense(8, 2)
+	first.Weights, second.Weights = randomDense(4, 8, 1), randomDense(8, 2, 2)
+	input_quant := layer.QuantRange(-1, 1)
+	model := network.Network{
+		Layers: []layer.Layer{
+			layer.QuantizeDense(first, input_quant, true),
+			layer.Tanh(8),
+			layer.QuantizeDense(second, input_quant, false),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := onnx.Export(&buf, &model); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	imported, err := onnx.Import(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(imported.Layers) != 3 {
+		t.Fatalf("expected 3 layers, got %d", len(imported.Layers))
+	}
+	quantized := model.Layers[0].(*layer.QuantizedDenseLayer)
+	if dense, ok := imported.Layers[0].(*layer.DenseLayer); !ok || !mat.Equal(dense.Weights, quantized.Dequantized()) {
+		t.Fatalf("expected a Dense layer with the dequantized weights")
+	}
+
+	// inputs the input quantization represents exactly go through the first
+	// layer unchanged, so only the input of the last one is rounded
+	inputs := randomDense(16, 4, 3)
+	inputs.Apply(func(i, j int, v float64) float64 {
+		return input_quant.Dequantize(input_quant.Quantize(v))
+	}, inputs)
+	expected_hidden, _ := model.Layers[0].(layer.RowInferer).InferRows(inputs)
+	hidden, _ := imported.Layers[0].(layer.RowInferer).InferRows(inputs)
+	if diff := maxDiff(expected_hidden, hidden); diff > 1e-12 {
+		t.Fatalf("first layer is off by %v", diff)
+	}
+	expected_output := model.Predict(inputs)
+	result := imported.Predict(inputs)
+	// 8 inputs rounded by at most half a step, with weights in [-1, 1)
+	if diff := maxDiff(expected_output, result); diff > input_quant.Scale*4 {
+		t.Fatalf("imported network is off by %v", diff)
+	}
+}
+
+func TestONNXFloat32Export(t *testing.T) {
+	model := network.Network{Layers: []layer.Layer{layer.Dense(2, 2), layer.Tanh(2)}}
+
+	var buf bytes.Buffer
+	if err := onnx.Export(&buf, &model, onnx.Float32()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	imported, err := onnx.Import(&buf)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	input := mat.NewDense(1, 2, []float64{0.5, 0.25})
+	if !mat.EqualApprox(model.Predict(input), imported.Predict(input), 1e-6) {
+		t.Fatalf("float32 export changed the output by more than float32 precision")
+	}
+}
+
+// Gemm as exported by other frameworks: transposed float weights in raw_data
+func TestONNXImportGemm(t *testing.T) {
+	raw := func(values ...float32) []byte {
+		data := make([]byte, 0, 4*len(values))
+		for _, v := range values {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+		return data
+	}
+
+	model := onnx.ModelProto{
+		IRVersion:   7,
+		OpsetImport: []onnx.OperatorSetID{{Version: 13}},
+		Graph: onnx.GraphProto{
+			Node: []onnx.NodeProto{
+				{
+					OpType: "Gemm",
+					Input:  []string{"x", "w", "b"},
+					Output: []string{"h"},
+					Attribute: []onnx.AttributeProto{
+						{Name: "transB", Type: onnx.AttributeInt, I: 1},
+						{Name: "alpha", Type: onnx.AttributeFloat, F: 2},
+					},
+				},
+				{OpType: "Tanh", Input: []string{"h"}, Output: []string{"y"}},
+			},
+			Initializer: []onnx.TensorProto{
+				// 3 outputs x 2 inputs
+				{Name: "w", Dims: []int64{3, 2}, DataType: onnx.TensorFloat, RawData: raw(1, 2, 3, 4, 5, 6)},
+				{Name: "b", Dims: []int64{3}, DataType: onnx.TensorFloat, FloatData: []float32{0.5, -0.5, 0}},
+			},
+			Input:  []onnx.ValueInfoProto{{Name: "x", ElemType: onnx.TensorFloat, Shape: []onnx.Dimension{{Param: "N"}, {Value: 2}}}},
+			Output: []onnx.ValueInfoProto{{Name: "y", ElemType: onnx.TensorFloat}},
+		},
+	}
+
+	imported, err := onnx.Import(bytes.NewReader(model.Marshal()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	result := imported.Predict(mat.NewDense(1, 2, []float64{0.1, -0.1}))
+	// 2 * (x . w_row) + b
+	expected_output := mat.NewDense(1, 3, []float64{
+		math.Tanh(2*(0.1-0.2) + 0.5),
+		math.Tanh(2*(0.3-0.4) - 0.5),
+		math.Tanh(2 * (0.5 - 0.6)),
+	})
+	if !mat.EqualApprox(expected_output, result, 1e-12) {
+		t.Fatalf(
+			"Output didn't match\nExpected = %v\nGot = %v\n",
+			mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+			mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+		)
+	}
+}
+
+func TestONNXUnsupported(t *testing.T) {
+	model := network.Network{
+		Layers: []layer.Layer{layer.FromMatrix(&scaleLayer{Factor: 2, Shift: mat.NewDense(1, 2, nil)})},
+	}
+	if err := onnx.Export(&bytes.Buffer{}, &model); err == nil {
+		t.Fatalf("expected error exporting a layer without ONNX mapping, got none")
+	}
+
+	scaled := network.Network{
+		Layers:     []layer.Layer{layer.Dense(2, 1)},
+		Preprocess: preprocess.NewPipeline(preprocess.Standard()),
+	}
+	if err := onnx.Export(&bytes.Buffer{}, &scaled); err == nil {
+		t.Fatalf("expected error exporting a network with preprocessing, got none")
+	}
+
+	relu := onnx.ModelProto{
+		Graph: onnx.GraphProto{
+			Node:   []onnx.NodeProto{{OpType: "Relu", Input: []string{"x"}, Output: []string{"y"}}},
+			Input:  []onnx.ValueInfoProto{{Name: "x", ElemType: onnx.TensorFloat}},
+			Output: []onnx.ValueInfoProto{{Name: "y", ElemType: onnx.TensorFloat}},
+		},
+	}
+	if _, err := onnx.Import(bytes.NewReader(relu.Marshal())); err == nil || !strings.Contains(err.Error(), "Relu") {
+		t.Fatalf("expected unsupported op error, got %v", err)
+	}
+
+	empty := onnx.ModelProto{
+		Graph: onnx.GraphProto{
+			Node:        []onnx.NodeProto{{OpType: "MatMul", Input: []string{"x", "w"}, Output: []string{"y"}}},
+			Initializer: []onnx.TensorProto{{Name: "w", Dims: []int64{0, 3}, DataType: onnx.TensorFloat}},
+			Input:       []onnx.ValueInfoProto{{Name: "x", ElemType: onnx.TensorFloat, Shape: []onnx.Dimension{{Param: "N"}, {Value: 0}}}},
+			Output:      []onnx.ValueInfoProto{{Name: "y", ElemType: onnx.TensorFloat}},
+		},
+	}
+	if _, err := onnx.Import(bytes.NewReader(empty.Marshal())); err == nil || !strings.Contains(err.Error(), "zero dimension") {
+		t.Fatalf("expected zero dimension error, got %v", err)
+	}
+
+	huge := onnx.TensorProto{Name: "w", Dims: []int64{1 << 40, 1 << 40}, DataType: onnx.TensorFloat}
+	if _, err := huge.Values(); err == nil {
+		t.Fatalf("expected error for an overflowing shape, got none")
+	}
+}
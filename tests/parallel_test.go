@@ -0,0 +1,133 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/mat"
+)
+
+// cloneNetwork returns a network with the same layers and weights.
+func cloneNetwork(t testing.TB, model *network.Network) *network.Network {
+	var buf bytes.Buffer
+	if _, err := model.WriteTo(&buf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var clone network.Network
+	if _, err := clone.ReadFrom(&buf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return &clone
+}
+
+func TestParallelTrainingMatchesSequential(t *testing.T) {
+	dataset := datasets.Moons(100, 0.1, 1)
+	sequential := &network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 8), layer.Tanh(8), layer.Sequential(layer.Dense(8, 1), layer.Tanh(1))},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+
+	for _, workers := range []int{2, 3, 8} {
+		model := cloneNetwork(t, sequential)
+		model.Workers = workers
+		reference := cloneNetwork(t, sequential)
+
+		// 100 samples in batches of 16 leave a last batch of 4, smaller than
+		// some of the worker counts
+		loader := &data.DataLoader{Dataset: dataset, BatchSize: 16}
+		reference.TrainLoader(loader, 3, 0.1)
+		model.TrainLoader(loader, 3, 0.1)
+
+		expected, got := reference.Params(), model.Params()
+		for i := range expected {
+			if !mat.EqualApprox(expected[i].Value, got[i].Value, 1e-12) {
+				t.Fatalf("%d workers: %s differs from single-threaded training:\n%v\n%v", workers, expected[i].Name,
+					mat.Formatted(expected[i].Value), mat.Formatted(got[i].Value))
+			}
+		}
+	}
+
+	model := &network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 1), &unregisteredLayer{*layer.Tanh(1)}},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+		Workers:   2,
+	}
+	if err := model.ResumeLoader(&data.DataLoader{Dataset: dataset, BatchSize: 16}); err != nil {
+		t.Fatalf("expected no error without epochs to run, got %v", err)
+	}
+	model.State = network.TrainState{Epochs: 1, Rate: 0.1}
+	if err := model.ResumeLoader(&data.DataLoader{Dataset: dataset, BatchSize: 16}); err == nil {
+		t.Fatalf("expected error replicating an unregistered layer, got none")
+	}
+}
+
+func TestParallelTrainingWithMatrixLayers(t *testing.T) {
+	dataset := datasets.Moons(100, 0.1, 1)
+	base := &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(2, 4), layer.FromMatrix(&scaleLayer{Factor: 0.5, Shift: mat.NewDense(1, 4, []float64{0.1, 0, -0.1, 0.2})}),
+			layer.Tanh(4), layer.Dense(4, 1),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+
+	for _, hogwild := range []bool{false, true} {
+		model := cloneNetwork(t, base)
+		model.Workers, model.Hogwild = 2, hogwild
+		reference := cloneNetwork(t, base)
+
+		loader := &data.DataLoader{Dataset: dataset, BatchSize: 16}
+		if err := model.TrainLoaderContext(context.Background(), loader, 2, 0.1); err != nil {
+			t.Fatalf("hogwild %v: expected no error, got %v", hogwild, err)
+		}
+		if hogwild {
+			continue
+		}
+		reference.TrainLoader(loader, 2, 0.1)
+		expected, got := reference.Params(), model.Params()
+		for i := range expected {
+			if !mat.EqualApprox(expected[i].Value, got[i].Value, 1e-12) {
+				t.Fatalf("%s differs from single-threaded training", expected[i].Name)
+			}
+		}
+	}
+}
+
+func BenchmarkTrainParallel(b *testing.B) {
+	dataset := datasets.Spirals(1024, 4, 0.1, 1)
+	base := &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(2, 256), layer.Tanh(256),
+			layer.Dense(256, 256), layer.Tanh(256),
+			layer.Dense(256, 4), layer.Tanh(4),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 256}
+
+	for _, workers := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			model := cloneNetwork(b, base)
+			model.Workers = workers
+			model.State = network.TrainState{Rate: 0.01}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				model.State.Epochs++
+				if err := model.ResumeLoader(loader); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
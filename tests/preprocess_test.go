@@ -0,0 +1,235 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/preprocess"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestScalers(t *testing.T) {
+	x := mat.NewDense(4, 2, []float64{
+		1, 5,
+		2, 5,
+		3, 5,
+		10, 5,
+	})
+
+	tests := []struct {
+		name        string
+		transformer preprocess.Transformer
+		expected    []float64
+	}{
+		{
+			"standard",
+			preprocess.Standard(),
+			// mean 4, population std sqrt(12.5), the constant column becomes 0
+			[]float64{-3 / math.Sqrt(12.5), 0, -2 / math.Sqrt(12.5), 0, -1 / math.Sqrt(12.5), 0, 6 / math.Sqrt(12.5), 0},
+		},
+		{
+			"min max",
+			preprocess.MinMax(-1, 1),
+			[]float64{-1, -1, -1 + 2.0/9, -1, -1 + 4.0/9, -1, 1, -1},
+		},
+		{
+			"robust",
+			// median 2.5, quartiles 1.75 and 4.75
+			preprocess.Robust(),
+			[]float64{-0.5, 0, -0.5 / 3, 0, 0.5 / 3, 0, 2.5, 0},
+		},
+	}
+
+	for _, test := range tests {
+		if _, err := test.transformer.Transform(x); err == nil {
+			t.Fatalf("%s: expected error transforming before fitting, got none", test.name)
+		}
+		if err := test.transformer.Fit(x); err != nil {
+			t.Fatalf("%s: expected no error, got %v", test.name, err)
+		}
+		result, err := test.transformer.Transform(x)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", test.name, err)
+		}
+		expected := mat.NewDense(4, 2, test.expected)
+		if !mat.EqualApprox(result, expected, 1e-12) {
+			t.Fatalf("%s: expected\n%v\ngot\n%v", test.name, mat.Formatted(expected), mat.Formatted(result))
+		}
+		if _, err := test.transformer.Transform(mat.NewDense(1, 3, nil)); err == nil {
+			t.Fatalf("%s: expected error for the wrong number of features, got none", test.name)
+		}
+	}
+}
+
+func TestOneHotEncoder(t *testing.T) {
+	x := mat.NewDense(3, 3, []float64{
+		0.5, 2, 7,
+		1.5, 0, 7,
+		2.5, 2, 9,
+	})
+	encoder := preprocess.OneHot(1, 2)
+	if err := encoder.Fit(x); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	result, err := encoder.Transform(x)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := mat.NewDense(3, 5, []float64{
+		0.5, 0, 1, 1, 0,
+		1.5, 1, 0, 1, 0,
+		2.5, 0, 1, 0, 1,
+	})
+	if !mat.Equal(result, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", mat.Formatted(expected), mat.Formatted(result))
+	}
+
+	if _, err := encoder.Transform(mat.NewDense(1, 3, []float64{0, 1, 7})); err == nil {
+		t.Fatalf("expected error for an unknown category, got none")
+	}
+	if err := preprocess.OneHot(3).Fit(x); err == nil {
+		t.Fatalf("expected error for a column that doesn't exist, got none")
+	}
+}
+
+func TestLabelEncoder(t *testing.T) {
+	var encoder preprocess.LabelEncoder
+	encoder.Fit([]string{"dog", "cat", "dog", "bird"})
+
+	codes, err := encoder.Transform([]string{"cat", "bird", "dog"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !equalBatches([][]float64{codes}, [][]float64{{1, 0, 2}}) {
+		t.Fatalf("expected codes [1 0 2], got %v", codes)
+	}
+	labels, err := encoder.Inverse(codes)
+	if err != nil || labels[0] != "cat" || labels[1] != "bird" || labels[2] != "dog" {
+		t.Fatalf("expected [cat bird dog], got %v (%v)", labels, err)
+	}
+
+	if _, err := encoder.Transform([]string{"fish"}); err == nil {
+		t.Fatalf("expected error for an unknown label, got none")
+	}
+	if _, err := encoder.Inverse([]float64{3}); err == nil {
+		t.Fatalf("expected error for an unknown code, got none")
+	}
+}
+
+func TestPCA(t *testing.T) {
+	// points on the line y = 2x, plus a little noise off the line
+	x := mat.NewDense(5, 2, []float64{
+		-2, -4.1,
+		-1, -1.9,
+		0, 0,
+		1, 2.1,
+		2, 3.9,
+	})
+	pca := preprocess.NewPCA(1)
+	if err := pca.Fit(x); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the first component points along the line
+	direction := mat.NewVecDense(2, []float64{1 / math.Sqrt(5), 2 / math.Sqrt(5)})
+	if !mat.EqualApprox(pca.Vectors.ColView(0), direction, 1e-2) {
+		t.Fatalf("expected component %v, got %v", mat.Formatted(direction.T()), mat.Formatted(pca.Vectors.T()))
+	}
+
+	result, err := pca.Transform(x)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rows, cols := result.Dims(); rows != 5 || cols != 1 {
+		t.Fatalf("expected 5x1 projection, got %dx%d", rows, cols)
+	}
+	// the variance along the component is the variance of the projection
+	variance := mat.Dot(result.ColView(0), result.ColView(0)) / 5
+	if math.Abs(variance-pca.Variance.At(0, 0)) > 1e-12 {
+		t.Fatalf("expected variance %v, got %v", variance, pca.Variance.At(0, 0))
+	}
+
+	if err := preprocess.NewPCA(3).Fit(x); err == nil {
+		t.Fatalf("expected error keeping more components than features, got none")
+	}
+}
+
+func TestPipelineSavedWithNetwork(t *testing.T) {
+	raw := []*mat.Dense{
+		mat.NewDense(1, 3, []float64{100, 0, 1}),
+		mat.NewDense(1, 3, []float64{300, 1, 0}),
+		mat.NewDense(1, 3, []float64{200, 2, 1}),
+		mat.NewDense(1, 3, []float64{400, 1, 1}),
+	}
+	samples, err := preprocess.Stack(raw)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pipeline := preprocess.NewPipeline(preprocess.OneHot(1), preprocess.Standard(), preprocess.NewPCA(3))
+	if err := pipeline.Fit(samples); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	model := network.Network{
+		Layers:     []layer.Layer{layer.Dense(3, 2), layer.Tanh(2)},
+		Loss:       loss.MSE,
+		LossPrime:  loss.MSE_Prime,
+		Preprocess: pipeline,
+	}
+	// Predict applies the pipeline on its own
+	transformed, err := pipeline.Transform(raw[0])
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := (&network.Network{Layers: model.Layers}).Predict(transformed)
+	if got := model.Predict(raw[0]); !mat.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", mat.Formatted(expected), mat.Formatted(got))
+	}
+
+	dir := t.TempDir()
+	for name, opts := range map[string][]network.SaveOption{
+		"json":   nil,
+		"binary": {network.Binary()},
+	} {
+		fpath := filepath.Join(dir, name)
+		if err := model.Save(fpath, opts...); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		var loaded network.Network
+		if err := loaded.Load(fpath); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if loaded.Preprocess == nil || len(loaded.Preprocess.Steps) != 3 {
+			t.Fatalf("%s: expected the pipeline to be loaded", name)
+		}
+		for _, sample := range raw {
+			if got, expected := loaded.Predict(sample), model.Predict(sample); !mat.Equal(got, expected) {
+				t.Fatalf("%s: expected %v, got %v", name, mat.Formatted(expected), mat.Formatted(got))
+			}
+		}
+	}
+
+	// only files with preprocessing need the new format version
+	data, err := os.ReadFile(filepath.Join(dir, "json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file network.JSONModelFile
+	json.Unmarshal(data, &file)
+	if file.FormatVersion != 2 {
+		t.Fatalf("expected format version 2, got %d", file.FormatVersion)
+	}
+
+	// an unfitted transformer can't be saved
+	model.Preprocess = preprocess.NewPipeline(preprocess.Standard())
+	if err := model.Encode(&bytes.Buffer{}); err == nil {
+		t.Fatalf("expected error saving an unfitted pipeline, got none")
+	}
+}
@@ -0,0 +1,244 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/onnx"
+	"github.com/kapilpokhrel/goNN/pkg/prune"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestPruneLayer(t *testing.T) {
+	dense := layer.Dense(3, 3)
+	dense.Weights = mat.NewDense(3, 3, []float64{
+		0.5, -0.1, 2,
+		-3, 0.05, 1,
+		0.2, -0.7, 4,
+	})
+	if err := prune.Layer(dense, 1.0/3); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := mat.NewDense(3, 3, []float64{
+		0.5, 0, 2,
+		-3, 0, 1,
+		0, -0.7, 4,
+	})
+	if !mat.Equal(expected, dense.Weights) {
+		t.Fatalf("expected the 3 smallest weights to be pruned, got %v", mat.Formatted(dense.Weights))
+	}
+
+	// training leaves pruned weights at 0
+	dense.ForwardRows(mat.NewDense(1, 3, []float64{1, 2, 3}))
+	dense.BackwardRows(mat.NewDense(1, 3, []float64{1, 1, 1}), 0.1)
+	for _, index := range [][2]int{{0, 1}, {1, 1}, {2, 0}} {
+		if v := dense.Weights.At(index[0], index[1]); v != 0 {
+			t.Fatalf("pruned weight %v changed to %v", index, v)
+		}
+	}
+	if dense.Weights.At(0, 0) == 0.5 {
+		t.Fatalf("expected the other weights to be trained")
+	}
+
+	// pruning less doesn't bring weights back
+	if err := prune.Layer(dense, 0); err != nil || mat.Sum(dense.Mask) != 6 {
+		t.Fatalf("expected the mask to be kept, got %v", mat.Formatted(dense.Mask))
+	}
+	if err := prune.Layer(dense, 1.5); err == nil {
+		t.Fatalf("expected an error for sparsity 1.5, got none")
+	}
+}
+
+func TestPruneGlobalAndPerLayer(t *testing.T) {
+	build := func() (*network.Network, *layer.DenseLayer, *layer.DenseLayer) {
+		small, large := layer.Dense(4, 4), layer.Dense(4, 4)
+		small.Weights = randomDense(4, 4, 1)
+		small.Weights.Scale(0.01, small.Weights)
+		large.Weights = randomDense(4, 4, 2)
+		return &network.Network{Layers: []layer.Layer{small, layer.Tanh(4), layer.Sequential(large)}}, small, large
+	}
+
+	model, small, large := build()
+	if err := prune.Global(model, 0.5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mat.Sum(small.Mask) != 0 || mat.Sum(large.Mask) != 16 {
+		t.Fatalf("expected the global threshold to prune the small layer only, masks sum to %v and %v",
+			mat.Sum(small.Mask), mat.Sum(large.Mask))
+	}
+
+	model, small, large = build()
+	if err := prune.PerLayer(model, 0.5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mat.Sum(small.Mask) != 8 || mat.Sum(large.Mask) != 8 {
+		t.Fatalf("expected every layer to be half pruned, masks sum to %v and %v",
+			mat.Sum(small.Mask), mat.Sum(large.Mask))
+	}
+	if got := prune.Sparsity(model); got != 0.5 {
+		t.Fatalf("expected sparsity 0.5, got %v", got)
+	}
+}
+
+func TestPruneSchedule(t *testing.T) {
+	schedule := prune.Schedule{Final: 0.8, Start: 2, End: 6}
+	for epoch, expected := range map[int]float64{1: 0, 2: 0, 4: 0.7, 6: 0.8, 9: 0.8} {
+		if got := schedule.At(epoch); math.Abs(got-expected) > 1e-12 {
+			t.Fatalf("epoch %d: expected sparsity %v, got %v", epoch, expected, got)
+		}
+	}
+
+	dataset := datasets.Moons(200, 0.1, 1)
+	model := &network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 32), layer.Tanh(32), layer.Dense(32, 1), layer.Tanh(1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+		OnEpoch:   schedule.OnEpoch,
+	}
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 8}
+	model.TrainLoader(loader, 8, 0.05)
+	if got := prune.Sparsity(model); math.Abs(got-0.8) > 0.01 {
+		t.Fatalf("expected sparsity 0.8 after training, got %v", got)
+	}
+
+	// masks are saved, so training after loading keeps the weights pruned
+	var buffer bytes.Buffer
+	if _, err := model.WriteTo(&buffer); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var loaded network.Network
+	if _, err := loaded.ReadFrom(&buffer); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	loaded.TrainLoader(loader, 2, 0.05)
+	if got := prune.Sparsity(&loaded); math.Abs(got-0.8) > 0.01 {
+		t.Fatalf("expected sparsity 0.8 after training the loaded network, got %v", got)
+	}
+}
+
+// prunedDense is a 64x32 Dense layer with 90% of its weights pruned.
+func prunedDense(t testing.TB) *layer.DenseLayer {
+	dense := layer.Dense(64, 32)
+	dense.Weights = randomDense(64, 32, 1)
+	dense.Biases = randomDense(1, 32, 2)
+	if err := prune.Layer(dense, 0.9); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return dense
+}
+
+func TestSparseDense(t *testing.T) {
+	dense := prunedDense(t)
+	sparse := layer.ToSparse(dense)
+	if sparse.NonZero() != 205 {
+		t.Fatalf("expected 205 weights to be stored, got %d", sparse.NonZero())
+	}
+	if !mat.Equal(dense.Weights, sparse.Dense().Weights) {
+		t.Fatalf("converting back didn't give the same weights")
+	}
+
+	inputs := randomDense(16, 64, 3)
+	inputs.Set(0, 0, 0)
+	expected, _ := dense.ForwardRows(inputs)
+	got, err := sparse.ForwardRows(inputs)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !mat.EqualApprox(expected, got, 1e-12) {
+		t.Fatalf("sparse output is off by %v", maxDiff(expected, got))
+	}
+
+	// training matches the masked dense layer
+	out_grad := randomDense(16, 32, 4)
+	expected_grad := dense.BackwardRows(out_grad, 0.1)
+	got_grad := sparse.BackwardRows(out_grad, 0.1)
+	if !mat.EqualApprox(expected_grad, got_grad, 1e-12) {
+		t.Fatalf("sparse input gradient is off by %v", maxDiff(expected_grad, got_grad))
+	}
+	if !mat.EqualApprox(dense.Weights, sparse.Dense().Weights, 1e-12) || !mat.EqualApprox(dense.Biases, sparse.Biases, 1e-12) {
+		t.Fatalf("sparse weights were updated differently")
+	}
+
+	if _, err := sparse.InferRows(mat.NewDense(1, 3, nil)); err == nil {
+		t.Fatalf("expected dimension error, got none")
+	}
+}
+
+func TestNetworkToSparse(t *testing.T) {
+	empty := layer.Dense(4, 2)
+	empty.Weights.Zero()
+	model := &network.Network{
+		Layers: []layer.Layer{
+			prunedDense(t), layer.Tanh(32),
+			layer.Sequential(layer.Dense(32, 4), layer.Tanh(4)),
+			empty,
+		},
+	}
+	inputs := randomDense(8, 64, 5)
+	expected := model.Predict(inputs)
+
+	if replaced := prune.ToSparse(model, 0.5); replaced != 2 {
+		t.Fatalf("expected the 2 sparse layers to be replaced, got %d", replaced)
+	}
+	if _, ok := model.Layers[2].(*layer.SequentialLayer).Layers[0].(*layer.DenseLayer); !ok {
+		t.Fatalf("expected the dense layer to stay dense")
+	}
+	if got := model.Predict(inputs); !mat.EqualApprox(expected, got, 1e-12) {
+		t.Fatalf("sparse network output is off by %v", maxDiff(expected, got))
+	}
+
+	// only the non-zero weights are saved
+	loaded := cloneNetwork(t, model)
+	if got := loaded.Predict(inputs); !mat.Equal(model.Predict(inputs), got) {
+		t.Fatalf("loaded sparse network gives different results")
+	}
+	if sparse := loaded.Layers[0].(*layer.SparseDenseLayer); sparse.NonZero() != 205 {
+		t.Fatalf("expected 205 weights after loading, got %d", sparse.NonZero())
+	}
+	if sparse := loaded.Layers[3].(*layer.SparseDenseLayer); sparse.NonZero() != 0 {
+		t.Fatalf("expected no weights after loading, got %d", sparse.NonZero())
+	}
+
+	// ONNX gets dense weights
+	var buffer bytes.Buffer
+	if err := onnx.Export(&buffer, model); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	imported, err := onnx.Import(&buffer)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := imported.Predict(inputs); !mat.EqualApprox(expected, got, 1e-12) {
+		t.Fatalf("imported network output is off by %v", maxDiff(expected, got))
+	}
+}
+
+func BenchmarkSparseDense(b *testing.B) {
+	dense := layer.Dense(784, 256)
+	dense.Weights = randomDense(784, 256, 1)
+	inputs := randomDense(64, 784, 2)
+
+	for _, sparsity := range []float64{0.5, 0.9, 0.99} {
+		if err := prune.Layer(dense, sparsity); err != nil {
+			b.Fatalf("expected no error, got %v", err)
+		}
+		sparse := layer.ToSparse(dense)
+		b.Run(fmt.Sprintf("dense/%v", sparsity), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dense.InferRows(inputs)
+			}
+		})
+		b.Run(fmt.Sprintf("sparse/%v", sparsity), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sparse.InferRows(inputs)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/data"
+	"github.com/kapilpokhrel/goNN/pkg/datasets"
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/npy"
+	"github.com/kapilpokhrel/goNN/pkg/quantize"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestQuantParams(t *testing.T) {
+	params := layer.QuantRange(-1, 2)
+	if params.Scale != 3.0/255 || params.ZeroPoint != -43 {
+		t.Fatalf("expected scale 3/255 and zero point -43, got %+v", params)
+	}
+	if params.Quantize(0) != -43 || params.Dequantize(-43) != 0 {
+		t.Fatalf("expected 0 to be exact, got %v", params.Quantize(0))
+	}
+	for _, v := range []float64{-1, -0.37, 0.5, 1.999, 2} {
+		if got := params.Dequantize(params.Quantize(v)); math.Abs(got-v) > params.Scale/2+1e-12 {
+			t.Fatalf("%v came back as %v", v, got)
+		}
+	}
+	if params.Quantize(100) != 127 || params.Quantize(-100) != -128 {
+		t.Fatalf("expected values out of range to be clamped")
+	}
+
+	// the range always includes 0
+	if params := layer.QuantRange(1, 3); params.ZeroPoint != -128 || params.Scale != 3.0/255 {
+		t.Fatalf("expected [0, 3], got %+v", params)
+	}
+}
+
+func TestQuantizedDense(t *testing.T) {
+	dense := layer.Dense(16, 8)
+	dense.Weights = randomDense(16, 8, 1)
+	dense.Biases = randomDense(1, 8, 2)
+	// one column with a much larger range than the others
+	column := dense.Weights.Slice(0, 16, 0, 1).(*mat.Dense)
+	column.Scale(50, column)
+
+	inputs := randomDense(32, 16, 3)
+	expected, _ := dense.InferRows(inputs)
+
+	input_quant := layer.QuantRange(-1, 1)
+	per_tensor := layer.QuantizeDense(dense, input_quant, false)
+	per_channel := layer.QuantizeDense(dense, input_quant, true)
+
+	tensor_result, err := per_tensor.InferRows(inputs)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	channel_result, _ := per_channel.InferRows(inputs)
+
+	// without the large column, per channel quantization is much closer
+	tensor_err := maxDiff(expected.Slice(0, 32, 1, 8), tensor_result.Slice(0, 32, 1, 8))
+	channel_err := maxDiff(expected.Slice(0, 32, 1, 8), channel_result.Slice(0, 32, 1, 8))
+	if channel_err > 0.05 || channel_err*5 > tensor_err {
+		t.Fatalf("expected per channel error %v to be small and well below per tensor error %v", channel_err, tensor_err)
+	}
+
+	// the integer path computes the float product of the quantized values,
+	// with biases rounded to the scale of the accumulator
+	var quantized_inputs, reference mat.Dense
+	quantized_inputs.Apply(func(i, j int, v float64) float64 {
+		return input_quant.Dequantize(input_quant.Quantize(v))
+	}, inputs)
+	reference.Mul(&quantized_inputs, per_channel.Dequantized())
+	reference.Add(&reference, repeatRows(per_channel.Biases, 32))
+	bias_rounding := input_quant.Scale * mat.Max(per_channel.Scales) / 2
+	if diff := maxDiff(&reference, channel_result); diff > bias_rounding+1e-12 {
+		t.Fatalf("integer result is off by %v from the dequantized product", diff)
+	}
+
+	// gradients go through, the weights stay the same
+	weights := slices.Clone(per_channel.Weights.Data)
+	per_channel.ForwardRows(inputs)
+	input_grad := per_channel.BackwardRows(mat.NewDense(32, 8, nil), 0.1)
+	if r, c := input_grad.Dims(); r != 32 || c != 16 || !slices.Equal(weights, per_channel.Weights.Data) {
+		t.Fatalf("expected a 32x16 input gradient and unchanged weights")
+	}
+}
+
+// heapGrowth returns how much more memory is live after f than before.
+func heapGrowth(f func()) int64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	return int64(after.HeapAlloc) - int64(before.HeapAlloc)
+}
+
+// Only training keeps dequantized weights, and only until it returns.
+func TestQuantizedDenseMemory(t *testing.T) {
+	dense := layer.Dense(512, 256)
+	dense.Weights = randomDense(512, 256, 1)
+	quantized := layer.QuantizeDense(dense, layer.QuantRange(-1, 1), true)
+	dequantized := int64(8 * 512 * 256)
+
+	inputs := randomDense(4, 512, 2)
+	if growth := heapGrowth(func() { quantized.InferRows(inputs) }); growth > dequantized/2 {
+		t.Fatalf("inference kept %d bytes, dequantized weights are %d", growth, dequantized)
+	}
+
+	quantized.ForwardRows(inputs)
+	expected := mat.DenseCopyOf(quantized.BackwardRows(randomDense(4, 256, 3), 0.1))
+	quantized.ReleaseTraining()
+	quantized.ForwardRows(inputs)
+	if !mat.Equal(expected, quantized.BackwardRows(randomDense(4, 256, 3), 0.1)) {
+		t.Fatalf("gradient changed after ReleaseTraining")
+	}
+	quantized.ReleaseTraining()
+
+	model := network.Network{Layers: []layer.Layer{quantized}, Loss: loss.MSE, LossPrime: loss.MSE_Prime}
+	samples := []*mat.Dense{randomDense(1, 512, 4), randomDense(1, 512, 5)}
+	targets := []*mat.Dense{randomDense(1, 256, 6), randomDense(1, 256, 7)}
+	growth := heapGrowth(func() {
+		if err := model.TrainContext(context.Background(), samples, targets, 1, 0.1); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+	if growth > dequantized/2 {
+		t.Fatalf("training kept %d bytes after it returned, dequantized weights are %d", growth, dequantized)
+	}
+	runtime.KeepAlive(quantized)
+}
+
+func repeatRows(row *mat.Dense, n int) *mat.Dense {
+	_, c := row.Dims()
+	result := mat.NewDense(n, c, nil)
+	for i := 0; i < n; i++ {
+		result.SetRow(i, row.RawRowView(0))
+	}
+	return result
+}
+
+func TestQuantizeNetwork(t *testing.T) {
+	dataset := datasets.Moons(400, 0.1, 1)
+	// seeded, as some random initializations don't learn in 40 epochs
+	dense := func(insize, outsize int, seed uint64) *layer.DenseLayer {
+		l := layer.Dense(insize, outsize)
+		l.Weights = randomDense(insize, outsize, seed)
+		l.Biases.Zero()
+		return l
+	}
+	float := &network.Network{
+		Layers: []layer.Layer{
+			dense(2, 16, 1), layer.Tanh(16),
+			layer.Sequential(dense(16, 16, 2), layer.Tanh(16)),
+			dense(16, 1, 3), layer.Tanh(1),
+		},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	float.State = network.TrainState{Epochs: 40, Rate: 0.05, RNG: rand.NewPCG(1, 2)}
+	if err := float.ResumeLoader(&data.DataLoader{Dataset: dataset, BatchSize: 8}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, per_channel := range []bool{false, true} {
+		quantized, err := quantize.Network(float, dataset, quantize.Options{PerChannel: per_channel})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, ok := quantized.Layers[2].(*layer.SequentialLayer).Layers[0].(*layer.QuantizedDenseLayer); !ok {
+			t.Fatalf("expected Dense layers inside blocks to be quantized too")
+		}
+		if _, ok := float.Layers[0].(*layer.DenseLayer); !ok {
+			t.Fatalf("quantizing changed the float network")
+		}
+
+		report, err := quantize.Compare(float, quantized, dataset)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		t.Log(report)
+		if report.Samples != 400 || report.FloatAccuracy < 0.8 {
+			t.Fatalf("expected the float network to have learned, got %v", report)
+		}
+		if math.Abs(report.AccuracyDelta()) > 0.02 || report.MeanError > 0.05 || report.MaxError > 0.25 {
+			t.Fatalf("quantization lost too much: %v", report)
+		}
+
+		// quantized models save and load like any other
+		fpath := filepath.Join(t.TempDir(), "quantized.json")
+		if err := quantized.Save(fpath); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		var loaded network.Network
+		if err := loaded.Load(fpath); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for i := 0; i < dataset.Len(); i += 50 {
+			if !mat.Equal(quantized.Predict(dataset.Inputs[i]), loaded.Predict(dataset.Inputs[i])) {
+				t.Fatalf("loaded quantized network gives different results for sample %d", i)
+			}
+		}
+	}
+
+	unregistered := &network.Network{Layers: []layer.Layer{layer.Dense(2, 2), &unregisteredLayer{}}}
+	if _, err := quantize.Network(unregistered, dataset, quantize.Options{}); err == nil {
+		t.Fatalf("expected an error for an unregistered layer, got none")
+	}
+}
+
+func TestQuantizeMatrixLayers(t *testing.T) {
+	dataset := datasets.Moons(50, 0.1, 1)
+	float := &network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(2, 3),
+			layer.FromMatrix(&scaleLayer{Factor: 0.5, Shift: mat.NewDense(1, 3, []float64{0.1, 0.2, 0.3})}),
+			layer.Dense(3, 1),
+		},
+	}
+	quantized, err := quantize.Network(float, dataset, quantize.Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	adapter, ok := quantized.Layers[1].(*layer.MatrixAdapter)
+	if !ok {
+		t.Fatalf("expected *layer.MatrixAdapter, got %T", quantized.Layers[1])
+	}
+	original := float.Layers[1].(*layer.MatrixAdapter).Layer.(*scaleLayer)
+	if scale := adapter.Layer.(*scaleLayer); scale == original || scale.Factor != 0.5 || !mat.Equal(scale.Shift, original.Shift) {
+		t.Fatalf("expected a copy of the matrix layer, got %+v", scale)
+	}
+	if _, ok := quantized.Layers[2].(*layer.QuantizedDenseLayer); !ok {
+		t.Fatalf("expected the Dense layer after the matrix layer to be quantized")
+	}
+}
+
+// int8 weights take a byte each, in memory and in every file format.
+func TestQuantizedModelFiles(t *testing.T) {
+	dense := layer.Dense(64, 64)
+	dense.Weights = randomDense(64, 64, 1)
+	float := &network.Network{Layers: []layer.Layer{dense, layer.Tanh(64)}}
+	weights := layer.QuantizeDense(dense, layer.QuantRange(-1, 1), true)
+	quantized := &network.Network{Layers: []layer.Layer{layer.Sequential(weights, layer.Tanh(64))}}
+	inputs := randomDense(4, 64, 2)
+	expected := quantized.Predict(inputs)
+
+	for name, opts := range map[string][]network.SaveOption{
+		"json":     nil,
+		"binary":   {network.Binary()},
+		"float32":  {network.Float32()},
+		"readable": {network.ReadableWeights()},
+	} {
+		var float_file, quantized_file bytes.Buffer
+		if err := float.Encode(&float_file, opts...); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if err := quantized.Encode(&quantized_file, opts...); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		// float32 files have 4 bytes per weight, readable ones a number
+		if name != "readable" && quantized_file.Len() > float_file.Len()/3 {
+			t.Errorf("%s: quantized file has %d bytes, the float one %d", name, quantized_file.Len(), float_file.Len())
+		}
+
+		var loaded network.Network
+		if _, err := loaded.ReadFrom(&quantized_file); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		loaded_weights := loaded.Layers[0].(*layer.SequentialLayer).Layers[0].(*layer.QuantizedDenseLayer).Weights
+		if !slices.Equal(weights.Weights.Data, loaded_weights.Data) {
+			t.Fatalf("%s: int8 weights didn't round trip", name)
+		}
+		// float32 files round the scales and biases
+		if diff := maxDiff(expected, loaded.Predict(inputs)); diff > 0 && (name != "float32" || diff > 1e-5) {
+			t.Fatalf("%s: loaded quantized network is off by %v", name, diff)
+		}
+	}
+
+	var buf bytes.Buffer
+	quantized.Encode(&buf)
+	data := buf.Bytes()
+	var file network.JSONModelFile
+	json.Unmarshal(data, &file)
+	if file.FormatVersion != 3 {
+		t.Fatalf("expected int8 weights to need format 3, got %d", file.FormatVersion)
+	}
+
+	// files before format 3 stored int8 weights as float64 matrices
+	legacy := signed(t, data, func(model *network.JSONNewtork) {
+		blob, _ := weights.Weights.Dense().MarshalBinary()
+		model.Layers[0].Params["0.weights"], _ = json.Marshal(base64.StdEncoding.EncodeToString(blob))
+	})
+	json.Unmarshal(legacy, &file)
+	file.FormatVersion = 1
+	legacy, _ = json.Marshal(file)
+	var loaded network.Network
+	if _, err := loaded.ReadFrom(bytes.NewReader(legacy)); err != nil {
+		t.Fatalf("expected a format 1 quantized model to load, got %v", err)
+	}
+	if !mat.Equal(expected, loaded.Predict(inputs)) {
+		t.Fatalf("format 1 quantized network gives different results")
+	}
+
+	for _, value := range []float64{1.5, 300} {
+		bad := signed(t, data, func(model *network.JSONNewtork) {
+			values := weights.Weights.Dense()
+			values.Set(3, 4, value)
+			blob, _ := values.MarshalBinary()
+			model.Layers[0].Params["0.weights"], _ = json.Marshal(base64.StdEncoding.EncodeToString(blob))
+		})
+		if _, err := loaded.ReadFrom(bytes.NewReader(bad)); err == nil {
+			t.Fatalf("expected an error for int8 weight %v, got none", value)
+		}
+	}
+}
+
+// Layers around int8 weights can be trained in parallel, and the weights
+// go through .npz archives as arrays of their values.
+func TestQuantizedNetworkTraining(t *testing.T) {
+	dense := layer.Dense(8, 8)
+	dense.Weights = randomDense(8, 8, 1)
+	weights := layer.QuantizeDense(dense, layer.QuantRange(-1, 1), false)
+	original := slices.Clone(weights.Weights.Data)
+	dataset := datasets.Moons(64, 0.1, 1)
+
+	for _, hogwild := range []bool{false, true} {
+		model := &network.Network{
+			Layers:    []layer.Layer{layer.Dense(2, 8), layer.Tanh(8), weights, layer.Dense(8, 1)},
+			Loss:      loss.MSE,
+			LossPrime: loss.MSE_Prime,
+			Workers:   2,
+			Hogwild:   hogwild,
+		}
+		model.State = network.TrainState{Epochs: 2, Rate: 0.05, RNG: rand.NewPCG(1, 2)}
+		if err := model.ResumeLoader(&data.DataLoader{Dataset: dataset, BatchSize: 8}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !slices.Equal(original, weights.Weights.Data) {
+			t.Fatalf("training changed the int8 weights (hogwild %v)", hogwild)
+		}
+
+		var buf bytes.Buffer
+		if err := npy.ExportNetwork(&buf, model); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		arrays, err := npy.ReadNPZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		weights.Weights.Data[0]++
+		if err := npy.LoadWeights(model, arrays, nil); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !slices.Equal(original, weights.Weights.Data) {
+			t.Fatalf("int8 weights didn't go through the .npz archive")
+		}
+		arrays["2.weights"].Set(0, 0, 0.5)
+		if err := npy.LoadWeights(model, arrays, nil); err == nil {
+			t.Fatalf("expected an error loading 0.5 into int8 weights, got none")
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package test
+
+// raceEnabled skips tests that race on purpose, like Hogwild training.
+const raceEnabled = true
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// scaleLayer is a layer defined outside of goNN: y = factor * x + shift
+type scaleLayer struct {
+	Factor float64
+	Shift  *mat.Dense
+}
+
+func (l *scaleLayer) Forward(input *mat.Dense) (*mat.Dense, error) {
+	var output mat.Dense
+	output.Scale(l.Factor, input)
+	rows, _ := output.Dims()
+	for i := 0; i < rows; i++ {
+		floats.Add(output.RawRowView(i), l.Shift.RawRowView(0))
+	}
+	return &output, nil
+}
+
+func (l *scaleLayer) Backward(output_grad *mat.Dense, rate float64) *mat.Dense {
+	var input_grad mat.Dense
+	input_grad.Scale(l.Factor, output_grad)
+	return &input_grad
+}
+
+type scaleConfig struct {
+	Factor float64 `json:"factor"`
+	Size   int     `json:"size"`
+}
+
+func (l *scaleLayer) MarshalConfig() ([]byte, error) {
+	_, size := l.Shift.Dims()
+	return json.Marshal(scaleConfig{Factor: l.Factor, Size: size})
+}
+
+func (l *scaleLayer) UnmarshalConfig(config []byte) error {
+	var c scaleConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+	l.Factor = c.Factor
+	l.Shift = mat.NewDense(1, c.Size, nil)
+	return nil
+}
+
+func (l *scaleLayer) Params() []layer.Param {
+	return []layer.Param{{Name: "shift", Value: l.Shift}}
+}
+
+type unregisteredLayer struct{ layer.TanhLayer }
+
+func init() {
+	layer.Register("test.Scale", func() layer.Serializable { return &scaleLayer{} })
+}
+
+func TestCustomLayerRoundTrip(t *testing.T) {
+	model := network.Network{
+		Layers: []layer.Layer{
+			layer.Dense(2, 3),
+			layer.FromMatrix(&scaleLayer{Factor: 0.5, Shift: mat.NewDense(1, 3, []float64{1, 2, 3})}),
+			layer.Sequential(layer.Tanh(3), layer.FromMatrix(&scaleLayer{Factor: -2, Shift: mat.NewDense(1, 3, []float64{0.1, 0.2, 0.3})})),
+		},
+	}
+
+	fpath := filepath.Join(t.TempDir(), "custom.json")
+	if err := model.Save(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var loaded network.Network
+	if err := loaded.Load(fpath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	adapter, ok := loaded.Layers[1].(*layer.MatrixAdapter)
+	if !ok {
+		t.Fatalf("expected *layer.MatrixAdapter, got %T", loaded.Layers[1])
+	}
+	scale, ok := adapter.Layer.(*scaleLayer)
+	if !ok {
+		t.Fatalf("expected *scaleLayer, got %T", adapter.Layer)
+	}
+	if scale.Factor != 0.5 || !mat.Equal(scale.Shift, mat.NewDense(1, 3, []float64{1, 2, 3})) {
+		t.Fatalf("custom layer didn't round trip, got factor %v and shift %v", scale.Factor, mat.Formatted(scale.Shift))
+	}
+
+	input := mat.NewDense(1, 2, []float64{0.3, -0.4})
+	expected_output := model.Predict(input)
+	result := loaded.Predict(input)
+	if !mat.Equal(expected_output, result) {
+		t.Fatalf(
+			"Loaded network output didn't match\nExpected = %v\nGot = %v\n",
+			mat.Formatted(expected_output, mat.Prefix("  "), mat.Squeeze()),
+			mat.Formatted(result, mat.Prefix("  "), mat.Squeeze()),
+		)
+	}
+}
+
+func TestMatrixLayerSpecs(t *testing.T) {
+	// earlier versions saved matrix layers without the adapter
+	spec := layer.Spec{Type: "Sequential", Config: []byte(`[{"type": "test.Scale", "config": {"factor": 2, "size": 3}}]`)}
+	built, err := layer.Build(spec)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	adapter, ok := built.(*layer.SequentialLayer).Layers[0].(*layer.MatrixAdapter)
+	if !ok {
+		t.Fatalf("expected the matrix layer to be wrapped, got %T", built.(*layer.SequentialLayer).Layers[0])
+	}
+	if scale := adapter.Layer.(*scaleLayer); scale.Factor != 2 {
+		t.Fatalf("expected factor 2, got %v", scale.Factor)
+	}
+
+	if _, err := layer.Build(layer.Spec{Type: "Matrix", Config: []byte(`{"type": "Tanh"}`)}); err == nil {
+		t.Fatalf("expected an error wrapping a layer that isn't a matrix layer, got none")
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	if _, err := layer.NameOf(&unregisteredLayer{}); err == nil {
+		t.Fatalf("expected error for unregistered layer, got none")
+	}
+	if name, err := layer.NameOf(layer.Dense(1, 1)); err != nil || name != "Dense" {
+		t.Fatalf("expected Dense, got %q (%v)", name, err)
+	}
+	if _, err := layer.New("NoSuchLayer"); err == nil {
+		t.Fatalf("expected error for unknown layer type, got none")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected Register to panic on a duplicate name")
+			}
+		}()
+		layer.Register("Dense", func() layer.Serializable { return &layer.DenseLayer{} })
+	}()
+
+	model := network.Network{Layers: []layer.Layer{&unregisteredLayer{}}}
+	if err := model.Save(filepath.Join(t.TempDir(), "unregistered.json")); err == nil {
+		t.Fatalf("expected Save to fail for an unregistered layer")
+	}
+}
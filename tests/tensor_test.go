@@ -0,0 +1,289 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/kapilpokhrel/goNN/pkg/layer"
+	"github.com/kapilpokhrel/goNN/pkg/loss"
+	"github.com/kapilpokhrel/goNN/pkg/network"
+	"github.com/kapilpokhrel/goNN/pkg/tensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+// counting returns a tensor holding 0, 1, 2, ... in row-major order.
+func counting(shape ...int) *tensor.Tensor {
+	values := tensor.New(shape...).Values()
+	for i := range values {
+		values[i] = float64(i)
+	}
+	return tensor.FromData(values, shape...)
+}
+
+func TestTensorViews(t *testing.T) {
+	x := counting(2, 3, 4)
+	if !slices.Equal(x.Strides(), []int{12, 4, 1}) || x.At(1, 2, 3) != 23 {
+		t.Fatalf("unexpected layout, strides %v", x.Strides())
+	}
+
+	// views share storage
+	slice := x.Slice(1, 1, 3)
+	if !slices.Equal(slice.Shape(), []int{2, 2, 4}) || slice.At(0, 0, 0) != 4 {
+		t.Fatalf("unexpected slice %v", slice)
+	}
+	slice.Set(-1, 1, 1, 2)
+	if x.At(1, 2, 2) != -1 {
+		t.Fatalf("writing to a slice didn't change the tensor")
+	}
+	x.Set(22, 1, 2, 2)
+	if row := x.Index(0, 1).Index(0, 2); !slices.Equal(row.Values(), []float64{20, 21, 22, 23}) {
+		t.Fatalf("unexpected row %v", row)
+	}
+
+	// transpose is a view, reshaping it copies
+	transposed := x.Transpose(2, 0, 1)
+	if !slices.Equal(transposed.Shape(), []int{4, 2, 3}) || transposed.At(3, 1, 2) != 23 || transposed.Contiguous() {
+		t.Fatalf("unexpected transpose %v", transposed)
+	}
+	flat := transposed.Reshape(4, -1)
+	if !slices.Equal(flat.Shape(), []int{4, 6}) || flat.At(1, 0) != 1 || flat.At(1, 1) != 5 {
+		t.Fatalf("unexpected reshape %v", flat)
+	}
+	flat.Set(100, 0, 0)
+	if x.At(0, 0, 0) != 0 {
+		t.Fatalf("reshaping a transposed view should copy")
+	}
+	reshaped := x.Reshape(6, 4)
+	reshaped.Set(100, 0, 0)
+	if x.At(0, 0, 0) != 100 {
+		t.Fatalf("reshaping a contiguous tensor should share storage")
+	}
+
+	// a matrix transposed twice is the matrix
+	m := counting(2, 3)
+	if !tensor.Equal(m, m.Transpose().Transpose()) || m.Transpose().At(2, 1) != 5 {
+		t.Fatalf("unexpected transpose of a matrix")
+	}
+}
+
+func TestTensorOps(t *testing.T) {
+	x := counting(2, 3)
+	bias := tensor.FromData([]float64{10, 20, 30}, 3)
+
+	sum, err := tensor.Add(x, bias)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !tensor.Equal(sum, tensor.FromData([]float64{10, 21, 32, 13, 24, 35}, 2, 3)) {
+		t.Fatalf("unexpected broadcast sum %v", sum)
+	}
+	column := tensor.FromData([]float64{1, 2}, 2, 1)
+	product, _ := tensor.Mul(x, column)
+	if !tensor.Equal(product, tensor.FromData([]float64{0, 1, 2, 6, 8, 10}, 2, 3)) {
+		t.Fatalf("unexpected broadcast product %v", product)
+	}
+	if _, err := tensor.Add(x, tensor.New(2)); err == nil {
+		t.Fatalf("expected a broadcast error, got none")
+	}
+	if shape, _ := tensor.BroadcastShape([]int{4, 1, 3}, []int{2, 1}); !slices.Equal(shape, []int{4, 2, 3}) {
+		t.Fatalf("expected shape [4 2 3], got %v", shape)
+	}
+
+	if got := x.Map(func(v float64) float64 { return v * v }); !slices.Equal(got.Values(), []float64{0, 1, 4, 9, 16, 25}) {
+		t.Fatalf("unexpected map %v", got)
+	}
+
+	y := counting(2, 3, 4)
+	if got := y.Sum(); got.Rank() != 0 || got.Item() != 276 {
+		t.Fatalf("expected a total of 276, got %v", got)
+	}
+	if got := y.Sum(1); !tensor.Equal(got, tensor.FromData([]float64{12, 15, 18, 21, 48, 51, 54, 57}, 2, 4)) {
+		t.Fatalf("unexpected sum over dimension 1 %v", got)
+	}
+	if got := y.Max(0, -1); !tensor.Equal(got, tensor.FromData([]float64{15, 19, 23}, 3)) {
+		t.Fatalf("unexpected max %v", got)
+	}
+	if got := y.Transpose().Min(0); got.At(0, 0) != 0 || got.At(2, 1) != 20 {
+		t.Fatalf("unexpected min of a view %v", got)
+	}
+	if got := y.Mean(2); got.At(1, 2) != 21.5 {
+		t.Fatalf("unexpected mean %v", got)
+	}
+}
+
+func TestTensorDense(t *testing.T) {
+	m := mat.NewDense(3, 4, nil)
+	x := tensor.FromDense(m)
+	x.Set(5, 2, 1)
+	if m.At(2, 1) != 5 {
+		t.Fatalf("FromDense should share storage")
+	}
+
+	// a slice of rows and columns is still a matrix view
+	view, err := counting(4, 5).Slice(0, 1, 3).Slice(1, 2, 5).Dense()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !mat.Equal(view, mat.NewDense(2, 3, []float64{7, 8, 9, 12, 13, 14})) {
+		t.Fatalf("unexpected matrix %v", mat.Formatted(view))
+	}
+	source := counting(4, 5)
+	shared, _ := source.Slice(0, 1, 3).Dense()
+	shared.Set(0, 0, -1)
+	if source.At(1, 0) != -1 {
+		t.Fatalf("Dense should share the storage of a row slice")
+	}
+
+	transposed, _ := counting(2, 3).Transpose().Dense()
+	if !mat.Equal(transposed, mat.NewDense(3, 2, []float64{0, 3, 1, 4, 2, 5})) {
+		t.Fatalf("unexpected transposed matrix %v", mat.Formatted(transposed))
+	}
+	if _, err := counting(2, 3, 4).Dense(); err == nil {
+		t.Fatalf("expected an error for a rank 3 tensor, got none")
+	}
+}
+
+func TestLayersOnSequences(t *testing.T) {
+	dense := layer.Dense(4, 2)
+	sequence := counting(2, 3, 4).Scale(0.1)
+
+	// Dense applied to every step of every sequence
+	output, err := dense.Forward(sequence)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(output.Shape(), []int{2, 3, 2}) {
+		t.Fatalf("expected shape [2 3 2], got %v", output.Shape())
+	}
+	step, _ := sequence.Index(0, 1).Index(0, 2).Reshape(1, 4).Dense()
+	expected, _ := dense.InferRows(step)
+	if got := output.Index(0, 1).Index(0, 2); !slices.Equal(got.Values(), expected.RawRowView(0)) {
+		t.Fatalf("step output %v doesn't match %v", got, expected.RawRowView(0))
+	}
+	if inferred, _ := dense.Infer(sequence); !tensor.Equal(inferred, output) {
+		t.Fatalf("Infer doesn't match Forward")
+	}
+	if in_grad := dense.Backward(tensor.New(2, 3, 2), 0.1); !slices.Equal(in_grad.Shape(), []int{2, 3, 4}) {
+		t.Fatalf("expected input gradient of shape [2 3 4], got %v", in_grad.Shape())
+	}
+
+	// the output is the layer's, Clone keeps it past the next Forward
+	kept := output.Clone()
+	dense.Forward(sequence.Scale(2))
+	if tensor.Equal(kept, output) {
+		t.Fatalf("expected the next Forward to overwrite the output")
+	}
+}
+
+func TestMatrixAdapter(t *testing.T) {
+	// matrix layers in a network train like the layers they run
+	inputs, outputs := xorData()
+	model := &network.Network{
+		Layers:    []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), layer.Dense(3, 1), layer.Tanh(1)},
+		Loss:      loss.MSE,
+		LossPrime: loss.MSE_Prime,
+	}
+	wrapped := cloneNetwork(t, model)
+	inner := slices.Clone(wrapped.Layers)
+	for i, l := range wrapped.Layers {
+		wrapped.Layers[i] = layer.FromMatrix(forwardOnly{l.(layer.RowLayer)})
+	}
+	model.Train(inputs, outputs, 5, 0.1)
+	wrapped.Train(inputs, outputs, 5, 0.1)
+
+	for i, l := range model.Layers {
+		if dense, ok := l.(*layer.DenseLayer); ok && !mat.Equal(dense.Weights, inner[i].(*layer.DenseLayer).Weights) {
+			t.Fatalf("layer %d trained differently through the adapters", i)
+		}
+	}
+
+	// and see every step of a sequence
+	adapter := layer.FromMatrix(forwardOnly{layer.Dense(4, 2)})
+	output, err := adapter.Forward(counting(2, 3, 4))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(output.Shape(), []int{2, 3, 2}) {
+		t.Fatalf("expected shape [2 3 2], got %v", output.Shape())
+	}
+	if layer.CanInfer(adapter) {
+		t.Fatalf("expected an adapter of a layer without Infer not to infer")
+	}
+	if _, err := adapter.Infer(counting(2, 4)); err == nil {
+		t.Fatalf("expected an error inferring without Infer, got none")
+	}
+}
+
+// meanSteps averages [batch, steps, features] sequences over their steps,
+// a layer that doesn't work on rows.
+type meanSteps struct{ steps int }
+
+func (l *meanSteps) Forward(input *tensor.Tensor) (*tensor.Tensor, error) {
+	if input.Rank() != 3 {
+		return nil, fmt.Errorf("expected [batch, steps, features], got %v", input.Shape())
+	}
+	l.steps = input.Dim(1)
+	return input.Mean(1), nil
+}
+
+func (l *meanSteps) Backward(out_grad *tensor.Tensor, rate float64) *tensor.Tensor {
+	batch, features := out_grad.Dim(0), out_grad.Dim(1)
+	spread, _ := out_grad.Scale(1/float64(l.steps)).Reshape(batch, 1, features).Broadcast(batch, l.steps, features)
+	return spread.Clone()
+}
+
+func TestNetworkOnSequences(t *testing.T) {
+	sequences := counting(4, 3, 2).Scale(0.1)
+	targets := tensor.FromData([]float64{0.1, -0.2, 0.3, -0.4}, 4, 1)
+
+	// a network of layers working on rows sees every step
+	steps := &network.Network{Layers: []layer.Layer{layer.Dense(2, 3), layer.Tanh(3)}}
+	output, err := steps.ForwardTensor(sequences)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(output.Shape(), []int{4, 3, 3}) {
+		t.Fatalf("expected shape [4 3 3], got %v", output.Shape())
+	}
+	if !tensor.EqualApprox(steps.PredictTensor(sequences), output, 1e-14) {
+		t.Fatalf("PredictTensor doesn't match ForwardTensor")
+	}
+	steps.BackPropTensor(tensor.New(4, 3, 3), 0.1)
+
+	// others run on tensors, and train like hand-written gradients say
+	model := &network.Network{
+		Layers: []layer.Layer{layer.Dense(2, 3), layer.Tanh(3), &meanSteps{}, layer.Dense(3, 1)},
+	}
+	before := lossOnSequences(t, model, sequences, targets)
+	for i := 0; i < 50; i++ {
+		output, err := model.ForwardTensor(sequences)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		diff, _ := tensor.Sub(output, targets)
+		model.BackPropTensor(diff.Scale(2/float64(diff.Size())), 0.1)
+	}
+	if after := lossOnSequences(t, model, sequences, targets); after >= before {
+		t.Fatalf("expected training to lower the loss from %v, got %v", before, after)
+	}
+
+	// the matrix API still works when the shapes allow it
+	if _, err := model.PredictContext(context.Background(), mat.NewDense(4, 2, nil)); err == nil {
+		t.Fatalf("expected an error for rows given to a sequence layer, got none")
+	}
+	if result := model.PredictTensor(sequences); !slices.Equal(result.Shape(), []int{4, 1}) {
+		t.Fatalf("expected shape [4 1], got %v", result.Shape())
+	}
+}
+
+func lossOnSequences(t *testing.T, model *network.Network, sequences, targets *tensor.Tensor) float64 {
+	output, err := model.PredictTensorContext(context.Background(), sequences)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	diff, _ := tensor.Sub(output, targets)
+	diff, _ = tensor.Mul(diff, diff)
+	return diff.Mean().Item()
+}